@@ -14,6 +14,8 @@ var myArgs = map[string]func(args ...string) error{
 	"cnf-info": cnfInfo,
 	"cnf-init": cnfInit,
 	"cnf-gen":  cnfGen,
+	"migrate":  migrateCmd,
+	"seed":     seedCmd,
 }
 
 // cnfInfo shows information about configuration fields.
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/uwine4850/foozy/pkg/config"
+	"github.com/uwine4850/foozy/pkg/database/migrate"
+	"github.com/uwine4850/foozy/pkg/utils/fpath"
+)
+
+var migrator *migrate.Migrator
+
+// migrationsLoaded shows whether sql migrations have already been loaded into the migrator,
+// so that the command can be run several times in one process.
+var migrationsLoaded bool
+
+// SetMigrator sets the migrator that is used by the "migrate" command.
+// Sql migrations from the [config.DatabaseConfig.MigrationsDir] directory
+// are loaded automatically on the first run, go migrations must be added to the migrator in advance.
+func SetMigrator(m *migrate.Migrator) {
+	migrator = m
+	migrationsLoaded = false
+}
+
+// migrateCmd manages database migrations.
+// migrate up — applies all new migrations.
+// migrate down [steps] — rolls back the last migrations, one by default.
+// migrate status — shows the status of all migrations.
+// migrate new <name> — creates new sql migration files.
+// migrate unlock — removes the migration lock left after a crash.
+func migrateCmd(args ...string) error {
+	if len(args) < 2 {
+		return errors.New("migrate subcommand not specified")
+	}
+	migrationsDir := config.LoadedConfig().Default.Database.MigrationsDir
+	if args[1] == "new" {
+		if len(args) != 3 {
+			return errors.New("migration name not specified")
+		}
+		upPath, downPath, err := migrate.NewSQLFiles(migrationsDir, args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Println("Created:", upPath)
+		fmt.Println("Created:", downPath)
+		return nil
+	}
+
+	if migrator == nil {
+		return errors.New("migrator is not set, use the cmd.SetMigrator function")
+	}
+	if !migrationsLoaded && migrationsDir != "" && fpath.PathExist(migrationsDir) {
+		if err := migrator.LoadDir(migrationsDir); err != nil {
+			return err
+		}
+		migrationsLoaded = true
+	}
+	switch args[1] {
+	case "up":
+		applied, err := migrator.Up()
+		printMigrations("Applied:", applied)
+		return err
+	case "down":
+		steps := 1
+		if len(args) == 3 {
+			n, err := strconv.Atoi(args[2])
+			if err != nil {
+				return err
+			}
+			steps = n
+		}
+		rolledBack, err := migrator.Down(steps)
+		printMigrations("Rolled back:", rolledBack)
+		return err
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(status); i++ {
+			state := "pending"
+			if status[i].Applied {
+				state = "applied"
+			}
+			fmt.Printf("%v_%s — %s\n", status[i].Migration.Version, status[i].Migration.Name, state)
+		}
+		return nil
+	case "unlock":
+		return migrator.Unlock()
+	default:
+		return fmt.Errorf("unknown migrate subcommand %s", args[1])
+	}
+}
+
+func printMigrations(title string, migrations []migrate.Migration) {
+	for i := 0; i < len(migrations); i++ {
+		fmt.Printf("%s %v_%s\n", title, migrations[i].Version, migrations[i].Name)
+	}
+}
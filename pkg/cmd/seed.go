@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/uwine4850/foozy/pkg/config"
+	"github.com/uwine4850/foozy/pkg/database/seed"
+)
+
+var seedRunner *seed.Runner
+
+// SetSeedRunner sets the runner that is used by the "seed" command.
+// Seeders must be added to the runner in advance.
+func SetSeedRunner(r *seed.Runner) {
+	seedRunner = r
+}
+
+// seedCmd fills the database with data.
+// seed [name...] — runs the seeders with the passed names, all seeders by default.
+// seed list — shows the names of the seeders.
+// seed load [file...] — loads the fixture files, all files from the [config.DatabaseConfig.FixturesDir] directory by default.
+// seed truncate <table...> — deletes all rows from the tables.
+func seedCmd(args ...string) error {
+	if seedRunner == nil {
+		return errors.New("seed runner is not set, use the cmd.SetSeedRunner function")
+	}
+	if len(args) < 2 {
+		return runSeeders()
+	}
+	switch args[1] {
+	case "list":
+		seeders := seedRunner.Seeders()
+		for i := 0; i < len(seeders); i++ {
+			fmt.Println(seeders[i].Name)
+		}
+		return nil
+	case "load":
+		if len(args) > 2 {
+			return seedRunner.Loader().LoadFiles(args[2:]...)
+		}
+		fixtures, err := seed.LoadDir(config.LoadedConfig().Default.Database.FixturesDir)
+		if err != nil {
+			return err
+		}
+		return seedRunner.Loader().Load(fixtures...)
+	case "truncate":
+		if len(args) < 3 {
+			return errors.New("tables not specified")
+		}
+		return seedRunner.Loader().Truncate(args[2:]...)
+	default:
+		return runSeeders(args[1:]...)
+	}
+}
+
+func runSeeders(names ...string) error {
+	done, err := seedRunner.Run(names...)
+	for i := 0; i < len(done); i++ {
+		fmt.Println("Seeded:", done[i])
+	}
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/uwine4850/foozy/pkg/typeopr"
 )
@@ -60,6 +61,10 @@ func Cnf() *Config {
 				},
 				Database: DatabaseConfig{
 					MainConnectionPoolName: "main",
+					MigrationsDir:          "migrations",
+					FixturesDir:            "fixtures",
+					Connections:            map[string]ConnectionConfig{},
+					SlowQueryLogPath:       "slow_query.log",
 				},
 			},
 		}
@@ -85,7 +90,36 @@ type DebugConfig struct {
 }
 
 type DatabaseConfig struct {
-	MainConnectionPoolName string `yaml:"MainConnectionPoolName" i:"The name of the main connection pool"`
+	MainConnectionPoolName string                      `yaml:"MainConnectionPoolName" i:"The name of the main connection pool"`
+	MigrationsDir          string                      `yaml:"MigrationsDir" i:"Directory with sql migration files"`
+	FixturesDir            string                      `yaml:"FixturesDir" i:"Directory with yaml and json fixture files"`
+	Connections            map[string]ConnectionConfig `yaml:"Connections" i:"Named database connections that are created by the InitDatabasePoolFromConfig function"`
+	LogQueries             bool                        `yaml:"LogQueries" i:"Writes each query to the request log in debug mode"`
+	SlowQueryThreshold     time.Duration               `yaml:"SlowQueryThreshold" i:"Queries that take longer are written to the slow query log, 0 disables the log"`
+	SlowQueryLogPath       string                      `yaml:"SlowQueryLogPath" i:"Path to slow query log file"`
+}
+
+// ConnectionConfig settings of one database connection.
+// Durations are written as strings, for example "30s" or "5m".
+type ConnectionConfig struct {
+	Driver          string            `yaml:"Driver" i:"Database driver: mysql or sqlite"`
+	Username        string            `yaml:"Username"`
+	Password        string            `yaml:"Password"`
+	Host            string            `yaml:"Host"`
+	Port            string            `yaml:"Port"`
+	DatabaseName    string            `yaml:"DatabaseName" i:"Database name for mysql or file path for sqlite"`
+	Charset         string            `yaml:"Charset"`
+	ParseTime       bool              `yaml:"ParseTime"`
+	TLS             string            `yaml:"TLS" i:"TLS mode: true, false, skip-verify, preferred or the name of a registered config"`
+	Timeout         time.Duration     `yaml:"Timeout"`
+	ReadTimeout     time.Duration     `yaml:"ReadTimeout"`
+	WriteTimeout    time.Duration     `yaml:"WriteTimeout"`
+	Params          map[string]string `yaml:"Params" i:"Additional DSN parameters"`
+	MaxOpenConns    int               `yaml:"MaxOpenConns"`
+	MaxIdleConns    int               `yaml:"MaxIdleConns"`
+	ConnMaxLifetime time.Duration     `yaml:"ConnMaxLifetime"`
+	ConnMaxIdleTime time.Duration     `yaml:"ConnMaxIdleTime"`
+	StmtCacheSize   int               `yaml:"StmtCacheSize" i:"Capacity of the prepared statement cache, 0 disables the cache"`
 }
 
 // Info displays information about each command.
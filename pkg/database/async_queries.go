@@ -1,11 +1,14 @@
 package database
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/uwine4850/foozy/pkg/database/dbutils"
 	"github.com/uwine4850/foozy/pkg/interfaces"
+	"github.com/uwine4850/foozy/pkg/mapper"
 )
 
 // AsyncQueries asynchronous database queries.
@@ -19,6 +22,16 @@ type AsyncQueries struct {
 	syncQ    interfaces.SyncQ
 	wg       sync.WaitGroup
 	asyncRes sync.Map
+	// limit maximum number of queries that are executed at the same time, 0 means no limit.
+	limit         int
+	cancelOnError bool
+	parentCtx     context.Context
+	once          sync.Once
+	sem           chan struct{}
+	ctx           context.Context
+	cancel        context.CancelFunc
+	mu            sync.Mutex
+	errs          []error
 }
 
 func NewAsyncQueries(syncQ interfaces.SyncQ) *AsyncQueries {
@@ -27,9 +40,12 @@ func NewAsyncQueries(syncQ interfaces.SyncQ) *AsyncQueries {
 	}
 }
 
+// New creates a new instance with the same sync queries, limit and cancellation settings.
 func (q *AsyncQueries) New() (interface{}, error) {
 	return &AsyncQueries{
-		syncQ: q.syncQ,
+		syncQ:         q.syncQ,
+		limit:         q.limit,
+		cancelOnError: q.cancelOnError,
 	}, nil
 }
 
@@ -37,27 +53,101 @@ func (q *AsyncQueries) SetSyncQueries(queries interfaces.SyncQ) {
 	q.syncQ = queries
 }
 
-func (q *AsyncQueries) Query(key string, query string, args ...any) {
+// SetLimit limits the number of queries that are executed at the same time.
+// The other queries wait for a free place. A value less than 1 removes the limit.
+// IMPORTANT: must be called before the first query.
+func (q *AsyncQueries) SetLimit(limit int) *AsyncQueries {
+	q.limit = limit
+	return q
+}
+
+// CancelOnError enables the cancellation of the remaining queries after the first error.
+// Queries that have not yet started are not executed and receive an [ErrAsyncCanceled] error,
+// queries that are already running are completed.
+// IMPORTANT: must be called before the first query.
+func (q *AsyncQueries) CancelOnError() *AsyncQueries {
+	q.cancelOnError = true
+	return q
+}
+
+// WithContext binds the queries to the context. After the context is canceled,
+// queries that have not yet started are not executed.
+// IMPORTANT: must be called before the first query.
+func (q *AsyncQueries) WithContext(ctx context.Context) *AsyncQueries {
+	q.parentCtx = ctx
+	return q
+}
+
+// init creates the context and the limit channel before the first query.
+func (q *AsyncQueries) init() {
+	q.once.Do(func() {
+		parent := q.parentCtx
+		if parent == nil {
+			parent = context.Background()
+		}
+		q.ctx, q.cancel = context.WithCancel(parent)
+		if q.limit > 0 {
+			q.sem = make(chan struct{}, q.limit)
+		}
+	})
+}
+
+// run executes the query in a new goroutine, taking into account the limit and cancellation.
+func (q *AsyncQueries) run(key string, query func() *dbutils.AsyncQueryData) {
+	q.init()
+	ctx := q.ctx
+	sem := q.sem
 	q.wg.Add(1)
 	go func() {
 		defer q.wg.Done()
-		res, err := q.syncQ.Query(query, args...)
-		q.storeAsyncRes(key, &dbutils.AsyncQueryData{Res: res, Error: err})
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				q.storeAsyncRes(key, &dbutils.AsyncQueryData{Error: ErrAsyncCanceled{Key: key, Err: ctx.Err()}})
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			q.storeAsyncRes(key, &dbutils.AsyncQueryData{Error: ErrAsyncCanceled{Key: key, Err: ctx.Err()}})
+			return
+		}
+		q.storeAsyncRes(key, query())
 	}()
 }
 
+func (q *AsyncQueries) Query(key string, query string, args ...any) {
+	q.run(key, func() *dbutils.AsyncQueryData {
+		res, err := q.syncQ.Query(query, args...)
+		return &dbutils.AsyncQueryData{Res: res, Error: err}
+	})
+}
+
 func (q *AsyncQueries) Exec(key string, query string, args ...any) {
-	q.wg.Add(1)
-	go func() {
-		defer q.wg.Done()
+	q.run(key, func() *dbutils.AsyncQueryData {
 		res, err := q.syncQ.Exec(query, args...)
-		q.storeAsyncRes(key, &dbutils.AsyncQueryData{SingleRes: res, Error: err})
-	}()
+		return &dbutils.AsyncQueryData{SingleRes: res, Error: err}
+	})
 }
 
 // storeAsyncRes sets the result of the key command execution.
+// The query error is saved for the [WaitErr] method.
 func (q *AsyncQueries) storeAsyncRes(key string, asyncQueryData *dbutils.AsyncQueryData) {
 	q.asyncRes.Store(key, asyncQueryData)
+	if asyncQueryData.Error == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := asyncQueryData.Error.(ErrAsyncCanceled); ok {
+		q.errs = append(q.errs, asyncQueryData.Error)
+	} else {
+		q.errs = append(q.errs, ErrAsyncQuery{Key: key, Err: asyncQueryData.Error})
+	}
+	if q.cancelOnError {
+		q.cancel()
+	}
 }
 
 // LoadAsyncRes retrieves command execution data by key.
@@ -77,9 +167,53 @@ func (q *AsyncQueries) Wait() {
 	q.wg.Wait()
 }
 
+// WaitErr works like [Wait], but also returns the first error of the queries.
+// Implements the [interfaces.AsyncErrQuery] interface.
+// The error is wrapped in [ErrAsyncQuery] with the query key. All errors can be obtained by the [Errs] method.
+func (q *AsyncQueries) WaitErr() error {
+	q.wg.Wait()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.errs) == 0 {
+		return nil
+	}
+	return q.errs[0]
+}
+
+// Errs returns the errors of all queries in the order in which they occurred.
+// They can be combined with errors.Join.
+func (q *AsyncQueries) Errs() []error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]error{}, q.errs...)
+}
+
 // Clear clears the query results data.
+// After that, the instance can be used for new queries, the cancellation is also reset.
 func (q *AsyncQueries) Clear() {
 	q.asyncRes = sync.Map{}
+	q.mu.Lock()
+	q.errs = nil
+	q.mu.Unlock()
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.once = sync.Once{}
+}
+
+// LoadInto loads the result of the key query and fills the slice of structures with it.
+// The structure fields must have the `db:"<column name>"` tag.
+// IMPORTANT: it must be called after the [AsyncQueries.Wait] method.
+func LoadInto[T any](asyncQ interfaces.AsyncQ, key string, out *[]T) error {
+	res, ok := asyncQ.LoadAsyncRes(key)
+	if !ok {
+		return ErrAsyncKeyNotFound{Key: key}
+	}
+	if res.Error != nil {
+		return ErrAsyncQuery{Key: key, Err: res.Error}
+	}
+	*out = make([]T, len(res.Res))
+	return mapper.FillStructSliceFromDb(out, &res.Res)
 }
 
 // AsyncResError loads the result of several asynchronous key queries and checks for errors.
@@ -95,3 +229,37 @@ func AsyncResError(keys []string, asyncQ interfaces.AsyncQ) error {
 	}
 	return nil
 }
+
+type ErrAsyncQuery struct {
+	Key string
+	Err error
+}
+
+func (e ErrAsyncQuery) Error() string {
+	return fmt.Sprintf("asynchronous query %s: %s", e.Key, e.Err.Error())
+}
+
+func (e ErrAsyncQuery) Unwrap() error {
+	return e.Err
+}
+
+type ErrAsyncCanceled struct {
+	Key string
+	Err error
+}
+
+func (e ErrAsyncCanceled) Error() string {
+	return fmt.Sprintf("asynchronous query %s was canceled: %s", e.Key, e.Err.Error())
+}
+
+func (e ErrAsyncCanceled) Unwrap() error {
+	return e.Err
+}
+
+type ErrAsyncKeyNotFound struct {
+	Key string
+}
+
+func (e ErrAsyncKeyNotFound) Error() string {
+	return fmt.Sprintf("result of the asynchronous query %s not found", e.Key)
+}
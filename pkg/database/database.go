@@ -1,22 +1,91 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/uwine4850/foozy/pkg/config"
 	"github.com/uwine4850/foozy/pkg/database/dbutils"
 	"github.com/uwine4850/foozy/pkg/interfaces"
 )
 
+// DbArgs arguments for connecting to the mysql database.
+// Fields other than the connection data are optional, zero values are not used.
 type DbArgs struct {
 	Username     string
 	Password     string
 	Host         string
 	Port         string
 	DatabaseName string
+	// Charset the character set of the connection, for example "utf8mb4".
+	Charset string
+	// ParseTime converts DATE and DATETIME values to time.Time.
+	ParseTime bool
+	// TLS mode: "true", "false", "skip-verify", "preferred" or the name of
+	// a config registered with mysql.RegisterTLSConfig.
+	TLS          string
+	Timeout      time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// Params additional DSN parameters.
+	Params map[string]string
+	Pool   PoolArgs
+}
+
+// DSN creates a connection string for the mysql driver.
+func (a *DbArgs) DSN() string {
+	cfg := mysql.NewConfig()
+	cfg.User = a.Username
+	cfg.Passwd = a.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(a.Host, a.Port)
+	cfg.DBName = a.DatabaseName
+	cfg.ParseTime = a.ParseTime
+	cfg.TLSConfig = a.TLS
+	cfg.Timeout = a.Timeout
+	cfg.ReadTimeout = a.ReadTimeout
+	cfg.WriteTimeout = a.WriteTimeout
+	if len(a.Params) > 0 || a.Charset != "" {
+		cfg.Params = make(map[string]string, len(a.Params)+1)
+		for key, value := range a.Params {
+			cfg.Params[key] = value
+		}
+		if a.Charset != "" {
+			cfg.Params["charset"] = a.Charset
+		}
+	}
+	return cfg.FormatDSN()
+}
+
+// PoolArgs settings of the connection pool.
+// Zero values are not applied, so the [sql.DB] defaults are used.
+type PoolArgs struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Apply applies the settings to the connection pool.
+func (p *PoolArgs) Apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
 }
 
 // MysqlDatabase structure for accessing the database.
@@ -30,39 +99,81 @@ type MysqlDatabase struct {
 	host     string
 	port     string
 	database string
+	args     DbArgs
 	db       *sql.DB
 	tx       *sql.Tx
 	syncQ    interfaces.SyncQ
 	asyncQ   interfaces.AsyncQ
+	stmtSize int
+	stmts    *StmtCache
 }
 
 func NewMysqlDatabase(args DbArgs, syncQ interfaces.SyncQ, asyncQ interfaces.AsyncQ) *MysqlDatabase {
-	d := MysqlDatabase{username: args.Username, password: args.Password, host: args.Host, port: args.Port, database: args.DatabaseName}
+	d := MysqlDatabase{username: args.Username, password: args.Password, host: args.Host, port: args.Port, database: args.DatabaseName, args: args}
 	d.syncQ = syncQ
 	d.asyncQ = asyncQ
 	return &d
 }
 
 // Open connecting to a mysql database.
+// The DSN options and pool settings are taken from [DbArgs].
 // Also, initialization of synchronous and asynchronous queries.
 func (d *MysqlDatabase) Open() error {
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", d.username, d.password, d.host, d.port, d.database)
-	db, err := sql.Open("mysql", connStr)
+	db, err := sql.Open("mysql", d.args.DSN())
 	if err != nil {
 		return err
 	}
+	d.args.Pool.Apply(db)
 
 	err = db.Ping()
 	if err != nil {
 		return err
 	}
 	d.db = db
-	d.syncQ.SetDB(&DbQuery{DB: db})
+	if d.stmtSize > 0 {
+		d.stmts = NewStmtCache(db, d.stmtSize)
+	}
+	d.syncQ.SetDB(&DbQuery{DB: db, Stmts: d.stmts})
 	return nil
 }
 
+// EnableStmtCache enables caching of prepared statements with the given capacity.
+// Must be called before the Open method.
+func (d *MysqlDatabase) EnableStmtCache(capacity int) {
+	if capacity <= 0 {
+		capacity = DEFAULT_STMT_CACHE_SIZE
+	}
+	d.stmtSize = capacity
+}
+
+// StmtCache returns the cache of prepared statements.
+// If the cache is not enabled, nil is returned.
+func (d *MysqlDatabase) StmtCache() *StmtCache {
+	return d.stmts
+}
+
+// PingContext checks that the connection to the database is alive.
+// If the connection was lost, a new one is established.
+func (d *MysqlDatabase) PingContext(ctx context.Context) error {
+	if d.db == nil {
+		return ErrConnectionNotOpen{}
+	}
+	return d.db.PingContext(ctx)
+}
+
+// Stats returns the statistics of the connection pool.
+func (d *MysqlDatabase) Stats() sql.DBStats {
+	if d.db == nil {
+		return sql.DBStats{}
+	}
+	return d.db.Stats()
+}
+
 // Close closes the connection to the database.
 func (d *MysqlDatabase) Close() error {
+	if d.stmts != nil {
+		d.stmts.Clear()
+	}
 	err := d.db.Close()
 	if err != nil {
 		return err
@@ -78,7 +189,12 @@ func (d *MysqlDatabase) Close() error {
 
 // NewTransaction creates a new transaction instance.
 func (d *MysqlDatabase) NewTransaction() (interfaces.DatabaseTransaction, error) {
-	return NewMysqlTransaction(d.db, d.syncQ, d.asyncQ)
+	tx, err := NewMysqlTransaction(d.db, d.syncQ, d.asyncQ)
+	if err != nil {
+		return nil, err
+	}
+	tx.SetStmtCache(d.stmts)
+	return tx, nil
 }
 
 // SyncQ getting access to synchronous requests.
@@ -99,10 +215,13 @@ func (d *MysqlDatabase) NewAsyncQ() (interfaces.AsyncQ, error) {
 // This object is used only for one transaction, for each
 // next transaction a new instance of the object must be created.
 type MysqlTransaction struct {
-	db     *sql.DB
-	tx     *sql.Tx
-	syncQ  interfaces.SyncQ
-	asyncQ interfaces.AsyncQ
+	db      *sql.DB
+	tx      *sql.Tx
+	txQuery *DbTxQuery
+	depth   int
+	stmts   *StmtCache
+	syncQ   interfaces.SyncQ
+	asyncQ  interfaces.AsyncQ
 }
 
 // NewMysqlTransaction creates a new [MysqlTransaction] escamp.
@@ -124,48 +243,110 @@ func NewMysqlTransaction(db *sql.DB, syncQ interfaces.SyncQ, asyncQ interfaces.A
 	}, nil
 }
 
+// SetStmtCache sets the cache of prepared statements that will be used by the transaction.
+// Must be called before the BeginTransaction method.
+func (t *MysqlTransaction) SetStmtCache(stmts *StmtCache) {
+	t.stmts = stmts
+}
+
 // BeginTransaction starts the transaction.
-// Only one transaction can be started per object instance.
+// If the transaction is already started, a nested transaction is created using
+// the SAVEPOINT command. Each nested transaction must be completed with the
+// CommitTransaction or RollBackTransaction method, which release the savepoint or
+// roll back to it. Thus, functions that each start their own transaction can be composed.
 func (t *MysqlTransaction) BeginTransaction() error {
 	if t.tx != nil {
-		return errors.New("transaction already started")
+		if _, err := t.tx.Exec("SAVEPOINT " + savepointName(t.depth+1)); err != nil {
+			return err
+		}
+		t.depth++
+		return nil
 	}
 	tx, err := t.db.Begin()
 	if err != nil {
 		return err
 	}
-	t.syncQ.SetDB(&DbTxQuery{Tx: tx})
+	t.txQuery = &DbTxQuery{Tx: tx, Stmts: t.stmts}
+	t.syncQ.SetDB(t.txQuery)
 	t.asyncQ.SetSyncQueries(t.syncQ)
 	t.tx = tx
 	return nil
 }
 
 // CommitTransaction writes the transaction to the database.
+// For a nested transaction, the savepoint is released, and the changes
+// are written only together with the outer transaction.
 func (t *MysqlTransaction) CommitTransaction() error {
 	if t.tx == nil {
 		return errors.New("transaction not begin")
 	}
+	if t.depth > 0 {
+		if _, err := t.tx.Exec("RELEASE SAVEPOINT " + savepointName(t.depth)); err != nil {
+			return err
+		}
+		t.depth--
+		return nil
+	}
 	if err := t.tx.Commit(); err != nil {
 		return err
 	}
-	t.tx = nil
+	t.release()
+	t.endTransaction(true)
 	return nil
 }
 
 // RollBackTransaction undoes any changes that were made
 // during the transaction.
 // That is, after executing the [BeginTransaction] method.
+// For a nested transaction, only the changes made after its start are undone.
 func (t *MysqlTransaction) RollBackTransaction() error {
 	if t.tx == nil {
 		return errors.New("transaction not begin")
 	}
+	if t.depth > 0 {
+		name := savepointName(t.depth)
+		if _, err := t.tx.Exec("ROLLBACK TO SAVEPOINT " + name); err != nil {
+			return err
+		}
+		if _, err := t.tx.Exec("RELEASE SAVEPOINT " + name); err != nil {
+			return err
+		}
+		t.depth--
+		return nil
+	}
 	if err := t.tx.Rollback(); err != nil {
 		return err
 	}
-	t.tx = nil
+	t.release()
+	t.endTransaction(false)
 	return nil
 }
 
+// Depth returns the nesting level of the transaction.
+// 0 — the outer transaction or the transaction is not started.
+func (t *MysqlTransaction) Depth() int {
+	return t.depth
+}
+
+func savepointName(depth int) string {
+	return "sp_" + strconv.Itoa(depth)
+}
+
+// release ends the use of the transaction and its statements.
+func (t *MysqlTransaction) release() {
+	if t.stmts != nil {
+		t.txQuery.Release()
+	}
+	t.tx = nil
+}
+
+// endTransaction informs the sync queries about the end of the transaction, it is used by the [QueryCache].
+func (t *MysqlTransaction) endTransaction(committed bool) {
+	if syncQ, ok := t.syncQ.(*SyncQueries); ok {
+		syncQ.endTransaction(committed)
+	}
+}
+
 // SyncQ getting access to synchronous requests.
 func (t *MysqlTransaction) SyncQ() interfaces.SyncQ {
 	return t.syncQ
@@ -182,12 +363,14 @@ func (t *MysqlTransaction) NewAsyncQ() (interfaces.AsyncQ, error) {
 
 // DbQuery standard database queries. They are used *sql.DB.
 // Requests are executed as usual.
+// If Stmts is set, prepared statements are taken from the cache.
 type DbQuery struct {
-	DB *sql.DB
+	DB    *sql.DB
+	Stmts *StmtCache
 }
 
 func (d *DbQuery) Query(query string, args ...any) ([]map[string]interface{}, error) {
-	sqlRows, err := d.DB.Query(query, args...)
+	sqlRows, err := d.QueryRows(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -203,8 +386,35 @@ func (d *DbQuery) Query(query string, args ...any) ([]map[string]interface{}, er
 	return rows, nil
 }
 
+func (d *DbQuery) QueryRows(query string, args ...any) (*sql.Rows, error) {
+	return d.QueryRowsContext(context.Background(), query, args...)
+}
+
+func (d *DbQuery) QueryRowsContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if d.Stmts == nil {
+		return d.DB.QueryContext(ctx, query, args...)
+	}
+	var rows *sql.Rows
+	err := d.Stmts.use(ctx, query, func(stmt *sql.Stmt) error {
+		var err error
+		rows, err = stmt.QueryContext(ctx, args...)
+		return err
+	})
+	return rows, err
+}
+
 func (d *DbQuery) Exec(query string, args ...any) (map[string]interface{}, error) {
-	result, err := d.DB.Exec(query, args...)
+	var result sql.Result
+	var err error
+	if d.Stmts == nil {
+		result, err = d.DB.Exec(query, args...)
+	} else {
+		err = d.Stmts.use(context.Background(), query, func(stmt *sql.Stmt) error {
+			var err error
+			result, err = stmt.Exec(args...)
+			return err
+		})
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -223,12 +433,18 @@ func (d *DbQuery) Exec(query string, args ...any) (map[string]interface{}, error
 // DbTxQuery queries that can be rolled back. Used *sql.Tx.
 // This object will perform queries with the [*sql.Tx]
 // object that is used for transactions.
+// If Stmts is set, the cached statements are bound to the transaction using [sql.Tx.Stmt].
+// In this case, after the end of the transaction, the Release method must be called.
 type DbTxQuery struct {
-	Tx *sql.Tx
+	Tx      *sql.Tx
+	Stmts   *StmtCache
+	mu      sync.Mutex
+	txStmts map[string]*sql.Stmt
+	entries []*stmtEntry
 }
 
 func (d *DbTxQuery) Query(query string, args ...any) ([]map[string]interface{}, error) {
-	sqlRows, err := d.Tx.Query(query, args...)
+	sqlRows, err := d.QueryRows(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -245,11 +461,43 @@ func (d *DbTxQuery) Query(query string, args ...any) ([]map[string]interface{},
 	return rows, nil
 }
 
-func (d *DbTxQuery) Exec(query string, args ...any) (map[string]interface{}, error) {
-	result, err := d.Tx.Exec(query, args...)
+func (d *DbTxQuery) QueryRows(query string, args ...any) (*sql.Rows, error) {
+	return d.QueryRowsContext(context.Background(), query, args...)
+}
+
+func (d *DbTxQuery) QueryRowsContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if d.Stmts == nil {
+		return d.Tx.QueryContext(ctx, query, args...)
+	}
+	stmt, err := d.stmt(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// Exec executes the query within the transaction.
+// Returns the same keys as [DbQuery.Exec]. The keys "id" and "rows" with the same values
+// are kept for compatibility and will be removed in the future.
+func (d *DbTxQuery) Exec(query string, args ...any) (map[string]interface{}, error) {
+	var result sql.Result
+	if d.Stmts == nil {
+		res, err := d.Tx.Exec(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		result = res
+	} else {
+		stmt, err := d.stmt(context.Background(), query)
+		if err != nil {
+			return nil, err
+		}
+		res, err := stmt.Exec(args...)
+		if err != nil {
+			return nil, err
+		}
+		result = res
+	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
@@ -259,7 +507,48 @@ func (d *DbTxQuery) Exec(query string, args ...any) (map[string]interface{}, err
 	if err != nil {
 		return nil, err
 	}
-	return map[string]interface{}{"id": id, "rows": rowsId}, nil
+	return map[string]interface{}{"insertID": id, "rowsAffected": rowsId, "id": id, "rows": rowsId}, nil
+}
+
+// stmt returns the statement of the query bound to the transaction.
+// The statement is created once for each query during the transaction.
+// A cached statement is bound to the transaction using [sql.Tx.StmtContext]. If the statement is not cached,
+// it is prepared by the transaction itself and is not added to the cache, because preparing it on the [sql.DB]
+// requires one more connection while the transaction holds its own, for example sqlite in memory has only one.
+func (d *DbTxQuery) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if stmt, ok := d.txStmts[query]; ok {
+		return stmt, nil
+	}
+	var stmt *sql.Stmt
+	if entry, ok := d.Stmts.get(query); ok {
+		stmt = d.Tx.StmtContext(ctx, entry.stmt)
+		d.entries = append(d.entries, entry)
+	} else {
+		var err error
+		stmt, err = d.Tx.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.txStmts == nil {
+		d.txStmts = map[string]*sql.Stmt{}
+	}
+	d.txStmts[query] = stmt
+	return stmt, nil
+}
+
+// Release releases the cached statements that were used by the transaction.
+// The transaction statements themselves are closed by the transaction.
+func (d *DbTxQuery) Release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := 0; i < len(d.entries); i++ {
+		d.Stmts.release(d.entries[i])
+	}
+	d.entries = nil
+	d.txStmts = nil
 }
 
 // InitDatabasePool initializes a database pool.
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -51,6 +52,17 @@ func (dp *DatabasePool) AddConnection(name string, dbInteraction interfaces.Data
 	}
 }
 
+// Names returns the sorted names of all connections of the pool.
+func (dp *DatabasePool) Names() []string {
+	names := []string{}
+	dp.connnectionPool.Range(func(key, value any) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
 // Lock blocks further changes to the pool.
 func (dp *DatabasePool) Lock() {
 	dp.locked.Store(true)
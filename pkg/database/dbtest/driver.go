@@ -0,0 +1,183 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+)
+
+// connector creates the connections of the fake database for [sql.OpenDB].
+type connector struct {
+	fake *FakeDatabase
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{fake: c.fake}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, ErrOpenNotSupported{}
+}
+
+// conn connection that passes the queries to the fake database.
+type conn struct {
+	fake *FakeDatabase
+	inTx bool
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return c.fake.ping()
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.fake.begin(); err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rule, err := c.fake.handle(query, namedValues(args), false, c.inTx)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(rule.rows)
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rule, err := c.fake.handle(query, namedValues(args), true, c.inTx)
+	if err != nil {
+		return nil, err
+	}
+	return result{insertID: rule.insertID, rowsAffected: rule.rowsAffected}, nil
+}
+
+// stmt prepared statement, it is used when the statement cache is enabled.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, valuesToNamed(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, valuesToNamed(args))
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	t.conn.inTx = false
+	return t.conn.fake.commit()
+}
+
+func (t *tx) Rollback() error {
+	t.conn.inTx = false
+	return t.conn.fake.rollback()
+}
+
+type result struct {
+	insertID     int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.insertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// rows rows of the rule converted to the driver values.
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	pos     int
+}
+
+func newRows(data []map[string]any) (*rows, error) {
+	r := &rows{columns: rowsColumns(data)}
+	for i := 0; i < len(data); i++ {
+		values := make([]driver.Value, len(r.columns))
+		for j := 0; j < len(r.columns); j++ {
+			value, err := driver.DefaultParameterConverter.ConvertValue(data[i][r.columns[j]])
+			if err != nil {
+				return nil, err
+			}
+			values[j] = value
+		}
+		r.values = append(r.values, values)
+	}
+	return r, nil
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
+
+func namedValues(args []driver.NamedValue) []any {
+	values := make([]any, len(args))
+	for i := 0; i < len(args); i++ {
+		values[i] = args[i].Value
+	}
+	return values
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i := 0; i < len(args); i++ {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: args[i]}
+	}
+	return named
+}
+
+type ErrOpenNotSupported struct{}
+
+func (e ErrOpenNotSupported) Error() string {
+	return "the fake driver can be opened only by the FakeDatabase.Open method"
+}
@@ -0,0 +1,413 @@
+// Package dbtest fake database for the unit tests of handlers and other code that works with the database.
+// The database does not execute the queries, it records them and returns the results that are set by the rules.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// Call query that was passed to the fake database.
+type Call struct {
+	Query string
+	Args  []any
+	// Exec the query was executed with the Exec method.
+	Exec bool
+	// Tx the query was executed inside a transaction.
+	Tx bool
+}
+
+// Rule result of the queries that match the pattern.
+type Rule struct {
+	pattern      *regexp.Regexp
+	args         []any
+	hasArgs      bool
+	rows         []map[string]any
+	insertID     int64
+	rowsAffected int64
+	err          error
+	times        int
+	used         int
+}
+
+// WithArgs the rule is used only if the query arguments are equal to args.
+// The values are compared after conversion to the types of the database driver, so 1 and int64(1) are equal.
+func (r *Rule) WithArgs(args ...any) *Rule {
+	r.args = args
+	r.hasArgs = true
+	return r
+}
+
+// ReturnRows sets the rows that are returned by the query.
+// The columns are sorted by name, the values must be the types supported by [database/sql/driver].
+func (r *Rule) ReturnRows(rows ...map[string]any) *Rule {
+	r.rows = rows
+	return r
+}
+
+// ReturnResult sets the result of the Exec query.
+func (r *Rule) ReturnResult(insertID int64, rowsAffected int64) *Rule {
+	r.insertID = insertID
+	r.rowsAffected = rowsAffected
+	return r
+}
+
+// ReturnError sets the error that is returned instead of the result.
+func (r *Rule) ReturnError(err error) *Rule {
+	r.err = err
+	return r
+}
+
+// Times limits the number of uses of the rule. After that the next matching rule is used.
+func (r *Rule) Times(n int) *Rule {
+	r.times = n
+	return r
+}
+
+// Once the same as Times(1).
+func (r *Rule) Once() *Rule {
+	return r.Times(1)
+}
+
+func (r *Rule) match(query string, args []any) bool {
+	if r.times > 0 && r.used >= r.times {
+		return false
+	}
+	if !r.pattern.MatchString(query) {
+		return false
+	}
+	return !r.hasArgs || argsEqual(r.args, args)
+}
+
+// FakeDatabase database that works without a database server.
+// The real [database.SyncQueries], [database.AsyncQueries] and [database.MysqlTransaction] objects
+// work on top of it, so the code under test uses the same [interfaces.Database] as in production.
+// Every query is recorded, the result is taken from the first matching rule that is added by the [FakeDatabase.On] method.
+// A query without a rule returns an [ErrUnexpectedQuery] error.
+// The SAVEPOINT queries of nested transactions are always successful.
+type FakeDatabase struct {
+	mu          sync.Mutex
+	db          *sql.DB
+	syncQ       interfaces.SyncQ
+	asyncQ      interfaces.AsyncQ
+	rules       []*Rule
+	calls       []Call
+	begins      int
+	commits     int
+	rollbacks   int
+	beginErr    error
+	commitErr   error
+	rollbackErr error
+	pingErr     error
+}
+
+func NewFakeDatabase(syncQ interfaces.SyncQ, asyncQ interfaces.AsyncQ) *FakeDatabase {
+	return &FakeDatabase{
+		syncQ:  syncQ,
+		asyncQ: asyncQ,
+	}
+}
+
+// NewDefaultFakeDatabase creates an open fake database with the standard sync and async queries.
+func NewDefaultFakeDatabase() *FakeDatabase {
+	syncQ := database.NewSyncQueries()
+	db := NewFakeDatabase(syncQ, database.NewAsyncQueries(syncQ))
+	if err := db.Open(); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func (d *FakeDatabase) Open() error {
+	d.db = sql.OpenDB(&connector{fake: d})
+	d.syncQ.SetDB(&database.DbQuery{DB: d.db})
+	return nil
+}
+
+func (d *FakeDatabase) Close() error {
+	if d.db == nil {
+		return database.ErrConnectionNotOpen{}
+	}
+	return d.db.Close()
+}
+
+// PingContext returns the error set by the FailPing method.
+func (d *FakeDatabase) PingContext(ctx context.Context) error {
+	if d.db == nil {
+		return database.ErrConnectionNotOpen{}
+	}
+	return d.db.PingContext(ctx)
+}
+
+// Stats returns the statistics of the connection pool.
+func (d *FakeDatabase) Stats() sql.DBStats {
+	if d.db == nil {
+		return sql.DBStats{}
+	}
+	return d.db.Stats()
+}
+
+func (d *FakeDatabase) NewTransaction() (interfaces.DatabaseTransaction, error) {
+	return database.NewMysqlTransaction(d.db, d.syncQ, d.asyncQ)
+}
+
+func (d *FakeDatabase) SyncQ() interfaces.SyncQ {
+	return d.syncQ
+}
+
+func (d *FakeDatabase) NewAsyncQ() (interfaces.AsyncQ, error) {
+	aq, err := d.asyncQ.New()
+	if err != nil {
+		return nil, err
+	}
+	return aq.(interfaces.AsyncQ), nil
+}
+
+// On adds a rule for the queries that match the regular expression.
+// For an exact match of the query, regexp.QuoteMeta can be used.
+// An invalid pattern causes a panic.
+func (d *FakeDatabase) On(pattern string) *Rule {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rule := &Rule{pattern: regexp.MustCompile(pattern)}
+	d.rules = append(d.rules, rule)
+	return rule
+}
+
+// FailBegin sets the error of the start of the transaction. nil removes the error.
+func (d *FakeDatabase) FailBegin(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.beginErr = err
+}
+
+// FailCommit sets the error of the transaction commit. nil removes the error.
+func (d *FakeDatabase) FailCommit(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.commitErr = err
+}
+
+// FailRollback sets the error of the transaction rollback. nil removes the error.
+func (d *FakeDatabase) FailRollback(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rollbackErr = err
+}
+
+// FailPing sets the error of the connection check. nil removes the error.
+func (d *FakeDatabase) FailPing(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pingErr = err
+}
+
+// Calls returns all recorded queries in the order of execution.
+func (d *FakeDatabase) Calls() []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Call{}, d.calls...)
+}
+
+// Begins number of started transactions.
+func (d *FakeDatabase) Begins() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.begins
+}
+
+// Commits number of committed transactions.
+func (d *FakeDatabase) Commits() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.commits
+}
+
+// Rollbacks number of rolled back transactions.
+func (d *FakeDatabase) Rollbacks() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rollbacks
+}
+
+// Reset removes the rules, the recorded queries, the transaction counters and errors.
+func (d *FakeDatabase) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules = nil
+	d.calls = nil
+	d.begins, d.commits, d.rollbacks = 0, 0, 0
+	d.beginErr, d.commitErr, d.rollbackErr, d.pingErr = nil, nil, nil, nil
+}
+
+// handle records the query and finds the rule for it.
+func (d *FakeDatabase) handle(query string, args []any, exec bool, tx bool) (*Rule, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, Call{Query: query, Args: args, Exec: exec, Tx: tx})
+	if exec && savepointPattern.MatchString(query) {
+		return &Rule{}, nil
+	}
+	for i := 0; i < len(d.rules); i++ {
+		if d.rules[i].match(query, args) {
+			d.rules[i].used++
+			if d.rules[i].err != nil {
+				return nil, d.rules[i].err
+			}
+			return d.rules[i], nil
+		}
+	}
+	return nil, ErrUnexpectedQuery{Query: query, Args: args}
+}
+
+func (d *FakeDatabase) ping() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pingErr
+}
+
+func (d *FakeDatabase) begin() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.beginErr != nil {
+		return d.beginErr
+	}
+	d.begins++
+	return nil
+}
+
+func (d *FakeDatabase) commit() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.commitErr != nil {
+		return d.commitErr
+	}
+	d.commits++
+	return nil
+}
+
+func (d *FakeDatabase) rollback() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.rollbackErr != nil {
+		return d.rollbackErr
+	}
+	d.rollbacks++
+	return nil
+}
+
+// find returns the recorded queries that match the pattern and the arguments.
+func (d *FakeDatabase) find(pattern string, args []any) []Call {
+	re := regexp.MustCompile(pattern)
+	calls := []Call{}
+	for _, call := range d.Calls() {
+		if re.MatchString(call.Query) && (args == nil || argsEqual(args, call.Args)) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// AssertCalled checks that a query that matches the pattern was executed.
+// If args are passed, the query arguments must also be equal.
+func (d *FakeDatabase) AssertCalled(t testing.TB, pattern string, args ...any) {
+	t.Helper()
+	if len(d.find(pattern, args)) == 0 {
+		t.Errorf("expected query %q with args %v, recorded queries: %v", pattern, args, d.Calls())
+	}
+}
+
+// AssertNotCalled checks that no query matching the pattern was executed.
+func (d *FakeDatabase) AssertNotCalled(t testing.TB, pattern string) {
+	t.Helper()
+	if calls := d.find(pattern, nil); len(calls) != 0 {
+		t.Errorf("unexpected query %q: %v", pattern, calls)
+	}
+}
+
+// AssertCallCount checks the number of queries that match the pattern.
+func (d *FakeDatabase) AssertCallCount(t testing.TB, pattern string, count int) {
+	t.Helper()
+	if calls := d.find(pattern, nil); len(calls) != count {
+		t.Errorf("expected %d queries %q, got %d", count, pattern, len(calls))
+	}
+}
+
+// AssertCommitted checks that at least one transaction was committed.
+func (d *FakeDatabase) AssertCommitted(t testing.TB) {
+	t.Helper()
+	if d.Commits() == 0 {
+		t.Error("expected the transaction to be committed")
+	}
+}
+
+// AssertRolledBack checks that at least one transaction was rolled back.
+func (d *FakeDatabase) AssertRolledBack(t testing.TB) {
+	t.Helper()
+	if d.Rollbacks() == 0 {
+		t.Error("expected the transaction to be rolled back")
+	}
+}
+
+// AssertRulesUsed checks that each rule was used. Rules with the Times limit must be used the specified number of times.
+func (d *FakeDatabase) AssertRulesUsed(t testing.TB) {
+	t.Helper()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := 0; i < len(d.rules); i++ {
+		rule := d.rules[i]
+		if rule.used == 0 || rule.times > 0 && rule.used < rule.times {
+			t.Errorf("rule %q was used %d times", rule.pattern.String(), rule.used)
+		}
+	}
+}
+
+// savepointPattern queries of the nested transactions.
+var savepointPattern = regexp.MustCompile(`^(SAVEPOINT|RELEASE SAVEPOINT|ROLLBACK TO SAVEPOINT) `)
+
+// argsEqual compares the arguments after conversion to the driver types.
+func argsEqual(expected []any, actual []any) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := 0; i < len(expected); i++ {
+		value, err := driver.DefaultParameterConverter.ConvertValue(expected[i])
+		if err != nil {
+			value = expected[i]
+		}
+		if !reflect.DeepEqual(value, actual[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// rowsColumns returns the sorted names of the columns of all rows.
+func rowsColumns(rows []map[string]any) []string {
+	columns := map[string]interface{}{}
+	for i := 0; i < len(rows); i++ {
+		for column := range rows[i] {
+			columns[column] = nil
+		}
+	}
+	return dbutils.SortedKeys(columns)
+}
+
+type ErrUnexpectedQuery struct {
+	Query string
+	Args  []any
+}
+
+func (e ErrUnexpectedQuery) Error() string {
+	return fmt.Sprintf("no rule for the query %q with args %v", e.Query, e.Args)
+}
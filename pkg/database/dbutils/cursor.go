@@ -0,0 +1,131 @@
+package dbutils
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Cursor iterates over the rows of the query result one by one.
+// Unlike [ScanRows], the rows are not collected into a slice, so the cursor
+// is suitable for processing large results.
+// The cursor is closed automatically when the rows run out, an error occurs,
+// or the context is canceled.
+// IMPORTANT: if the iteration is stopped early, the cursor must be closed using the Close method.
+//
+// Usage:
+//
+//	cursor, err := syncQ.QueryIter(ctx, "SELECT id, name FROM items")
+//	if err != nil {
+//		return err
+//	}
+//	defer cursor.Close()
+//	for cursor.Next() {
+//		var id int
+//		var name string
+//		if err := cursor.Scan(&id, &name); err != nil {
+//			return err
+//		}
+//	}
+//	return cursor.Err()
+type Cursor struct {
+	ctx     context.Context
+	rows    *sql.Rows
+	columns []string
+	err     error
+}
+
+// NewCursor creates a new cursor over the rows.
+// The rows must be created with the same context.
+func NewCursor(ctx context.Context, rows *sql.Rows) *Cursor {
+	return &Cursor{ctx: ctx, rows: rows}
+}
+
+// Next moves the cursor to the next row.
+// Returns false if there are no more rows or an error has occurred,
+// the cause can be obtained using the Err method.
+func (c *Cursor) Next() bool {
+	if err := c.ctx.Err(); err != nil {
+		c.err = err
+		c.rows.Close()
+		return false
+	}
+	if c.rows.Next() {
+		return true
+	}
+	c.rows.Close()
+	return false
+}
+
+// Scan copies the columns of the current row into the values pointed at by dest.
+// Works the same as [sql.Rows.Scan].
+func (c *Cursor) Scan(dest ...any) error {
+	return c.rows.Scan(dest...)
+}
+
+// ScanMap returns the current row as a map, where the key is the name of the column.
+// The values are the same as in the result of the [ScanRows] function.
+func (c *Cursor) ScanMap() (map[string]interface{}, error) {
+	columns, err := c.Columns()
+	if err != nil {
+		return nil, err
+	}
+	dataColumns := make([]interface{}, len(columns))
+	for i := range columns {
+		dataColumns[i] = new(interface{})
+	}
+	if err := c.rows.Scan(dataColumns...); err != nil {
+		return nil, err
+	}
+	row := make(map[string]interface{}, len(columns))
+	for i := 0; i < len(columns); i++ {
+		row[columns[i]] = *dataColumns[i].(*interface{})
+	}
+	return row, nil
+}
+
+// Columns returns the names of the columns of the result.
+func (c *Cursor) Columns() ([]string, error) {
+	if c.columns == nil {
+		columns, err := c.rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		c.columns = columns
+	}
+	return c.columns, nil
+}
+
+// Rows returns the rows that the cursor iterates over.
+// It can be used with functions that accept [*sql.Rows], for example mapper.ScanRowsInto.
+func (c *Cursor) Rows() *sql.Rows {
+	return c.rows
+}
+
+// Err returns the error that occurred during iteration, including context cancellation.
+func (c *Cursor) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.rows.Err()
+}
+
+// Close closes the cursor. It can be called several times.
+func (c *Cursor) Close() error {
+	return c.rows.Close()
+}
+
+// Each calls fn for each row of the cursor as a map, then closes the cursor.
+// The iteration stops at the first error returned by fn.
+func (c *Cursor) Each(fn func(row map[string]interface{}) error) error {
+	defer c.Close()
+	for c.Next() {
+		row, err := c.ScanMap()
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return c.Err()
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -66,16 +68,26 @@ func ScanRows(rows *sql.Rows, fn func(row map[string]interface{})) error {
 // Name of the keys.
 // Key data.
 // It is important to note that the sequence number of the key coincides with the number of its value and vice versa.
+// The keys are sorted, so the order of the columns is always the same.
 func ParseParams(params map[string]interface{}) ([]string, []interface{}) {
-	var values []interface{}
-	var keys []string
-	for key, val := range params {
-		keys = append(keys, key)
-		values = append(values, val)
+	keys := SortedKeys(params)
+	values := make([]interface{}, len(keys))
+	for i := 0; i < len(keys); i++ {
+		values[i] = params[keys[i]]
 	}
 	return keys, values
 }
 
+// SortedKeys returns the sorted keys of the map.
+func SortedKeys(params map[string]interface{}) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // ParseEquals handles a slice of the DbEquals structure.
 // Converts the data into the format "key = ?", and then creates from this a part of the string for sql query.
 // The conjunction parameter is responsible for the delimiter between the data, if there is more than one.
@@ -96,20 +108,25 @@ func ParseEquals(equals []DbEquals, conjunction string) (string, []interface{})
 // ParseMapAsEquals turns the map into equals values ​​for sql.
 // A string is part of a query string, for example <key = ?, val = ?>.
 // The slice represents the data that will be inserted in order instead of <?>.
+// The keys are sorted, so the order of the values is always the same.
 func ParseMapAsEquals(params *map[string]interface{}) (string, []interface{}) {
-	var paramsString string
-	args := []interface{}{}
-	i := 0
-	for name, value := range *params {
-		if i == len(*params)-1 {
-			paramsString += fmt.Sprintf("%s = ?", name)
-		} else {
-			paramsString += fmt.Sprintf("%s = ?, ", name)
-		}
-		args = append(args, value)
-		i++
+	keys := SortedKeys(*params)
+	parts := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i := 0; i < len(keys); i++ {
+		parts[i] = fmt.Sprintf("%s = ?", keys[i])
+		args[i] = (*params)[keys[i]]
+	}
+	return strings.Join(parts, ", "), args
+}
+
+// RowsAffected reads the number of affected rows from the result of the Exec method.
+// Returns -1 if the result does not contain it.
+func RowsAffected(res map[string]interface{}) int64 {
+	if n, ok := res["rowsAffected"].(int64); ok {
+		return n
 	}
-	return paramsString, args
+	return -1
 }
 
 // ParseString processing text values from a database.
@@ -117,6 +134,9 @@ func ParseString(value interface{}) string {
 	if value == nil {
 		return ""
 	}
+	if s, ok := value.(string); ok {
+		return s
+	}
 	_uint8 := value.([]uint8)
 	return string(_uint8)
 }
@@ -139,6 +159,12 @@ func ParseInt(value interface{}) (int, error) {
 		v = parseInt
 	case "int64":
 		v = value.(int64)
+	case "string":
+		parseInt, err := strconv.ParseInt(value.(string), 0, 64)
+		if err != nil {
+			return -1, err
+		}
+		v = parseInt
 	}
 	return int(v), nil
 }
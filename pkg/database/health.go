@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// DEFAULT_HEALTH_TIMEOUT the time for which the database must respond to the check
+// if the timeout is not specified.
+const DEFAULT_HEALTH_TIMEOUT = 5 * time.Second
+
+// PingableDatabase a database that can check its connection.
+// [MysqlDatabase], [SqliteDatabase] and [ReplicaGroup] implement this interface.
+type PingableDatabase interface {
+	PingContext(ctx context.Context) error
+}
+
+// NamedDatabasePool a pool that provides the names of its connections, for example [DatabasePool].
+type NamedDatabasePool interface {
+	Names() []string
+}
+
+// StatsDatabase a database that provides the statistics of its connection pool.
+type StatsDatabase interface {
+	Stats() sql.DBStats
+}
+
+// PingDatabase checks the connection to the database. If the database does not implement
+// [PingableDatabase], the "SELECT 1" query is executed instead. The query is bound to the context
+// only if the synchronous queries implement the [interfaces.RowsQuery] interface.
+func PingDatabase(ctx context.Context, db interfaces.DatabaseInteraction) error {
+	if p, ok := db.(PingableDatabase); ok {
+		return p.PingContext(ctx)
+	}
+	rowsQuery, ok := db.SyncQ().(interfaces.RowsQuery)
+	if !ok {
+		_, err := db.SyncQ().Query("SELECT 1")
+		return err
+	}
+	rows, err := rowsQuery.QueryRowsContext(ctx, "SELECT 1")
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// ConnectionHealth the result of the check of one connection of the pool.
+type ConnectionHealth struct {
+	Name    string        `json:"name"`
+	Healthy bool          `json:"healthy"`
+	Latency time.Duration `json:"-"`
+	// LatencyMs the time of the response in milliseconds.
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	// Statistics of the connection pool, if the database provides them.
+	OpenConnections int   `json:"open_connections"`
+	InUse           int   `json:"in_use"`
+	Idle            int   `json:"idle"`
+	WaitCount       int64 `json:"wait_count"`
+}
+
+// HealthStatus the result of the check of all connections of the pool.
+type HealthStatus struct {
+	// Ready all connections are healthy.
+	Ready       bool               `json:"ready"`
+	CheckedAt   time.Time          `json:"checked_at"`
+	Connections []ConnectionHealth `json:"connections"`
+}
+
+// Connection returns the result of the named connection.
+func (s HealthStatus) Connection(name string) (ConnectionHealth, bool) {
+	for i := 0; i < len(s.Connections); i++ {
+		if s.Connections[i].Name == name {
+			return s.Connections[i], true
+		}
+	}
+	return ConnectionHealth{}, false
+}
+
+// HealthChecker checks the connections of the [interfaces.DatabasePool].
+// Each connection is pinged, the time of the response and the statistics of the connection pool are recorded.
+// A ping of a lost connection makes [database/sql] establish a new one, so a periodic check
+// also restores the connections after the database server is restarted.
+//
+// The checks can be performed periodically by the Start method or once by the Check method.
+// The Handler method returns the result as JSON, so it can be used as a readiness endpoint.
+type HealthChecker struct {
+	pool      interfaces.DatabasePool
+	names     []string
+	timeout   time.Duration
+	mu        sync.RWMutex
+	status    HealthStatus
+	checked   bool
+	stopCheck chan struct{}
+	checkWg   sync.WaitGroup
+}
+
+// NewHealthChecker creates a checker of the pool. The pool must be locked before the check.
+// If timeout is not greater than zero, [DEFAULT_HEALTH_TIMEOUT] is used.
+// The names of the checked connections can be passed explicitly, otherwise they are taken
+// from the pool if it implements the [NamedDatabasePool] interface.
+func NewHealthChecker(pool interfaces.DatabasePool, timeout time.Duration, names ...string) *HealthChecker {
+	if timeout <= 0 {
+		timeout = DEFAULT_HEALTH_TIMEOUT
+	}
+	return &HealthChecker{pool: pool, names: names, timeout: timeout}
+}
+
+// Check checks all connections of the pool once and saves the result.
+// If the names of the connections are unknown, the pool is not ready.
+func (h *HealthChecker) Check() HealthStatus {
+	names := h.names
+	known := len(names) > 0
+	if !known {
+		if namedPool, ok := h.pool.(NamedDatabasePool); ok {
+			names = namedPool.Names()
+			known = true
+		}
+	}
+	status := HealthStatus{Ready: known, CheckedAt: time.Now(), Connections: make([]ConnectionHealth, 0, len(names))}
+	for i := 0; i < len(names); i++ {
+		conn := h.checkConnection(names[i])
+		status.Ready = status.Ready && conn.Healthy
+		status.Connections = append(status.Connections, conn)
+	}
+	h.mu.Lock()
+	h.status = status
+	h.checked = true
+	h.mu.Unlock()
+	return status
+}
+
+func (h *HealthChecker) checkConnection(name string) ConnectionHealth {
+	conn := ConnectionHealth{Name: name}
+	db, err := h.pool.ConnectionPool(name)
+	if err != nil {
+		conn.Error = err.Error()
+		return conn
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	start := time.Now()
+	err = PingDatabase(ctx, db)
+	conn.Latency = time.Since(start)
+	conn.LatencyMs = float64(conn.Latency) / float64(time.Millisecond)
+	if err != nil {
+		conn.Error = err.Error()
+	} else {
+		conn.Healthy = true
+	}
+	if s, ok := db.(StatsDatabase); ok {
+		stats := s.Stats()
+		conn.OpenConnections = stats.OpenConnections
+		conn.InUse = stats.InUse
+		conn.Idle = stats.Idle
+		conn.WaitCount = stats.WaitCount
+	}
+	return conn
+}
+
+// Status returns the result of the last check. If there was no check yet, the check is performed.
+func (h *HealthChecker) Status() HealthStatus {
+	h.mu.RLock()
+	status, checked := h.status, h.checked
+	h.mu.RUnlock()
+	if !checked {
+		return h.Check()
+	}
+	return status
+}
+
+// Ready returns true if all connections were healthy during the last check.
+func (h *HealthChecker) Ready() bool {
+	return h.Status().Ready
+}
+
+// Start starts checking the connections with the given interval. The first check is performed immediately.
+// The check is stopped by the Stop method.
+func (h *HealthChecker) Start(interval time.Duration) {
+	h.Stop()
+	h.Check()
+	stop := make(chan struct{})
+	h.mu.Lock()
+	h.stopCheck = stop
+	h.mu.Unlock()
+	h.checkWg.Add(1)
+	go func(stop chan struct{}) {
+		defer h.checkWg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				h.Check()
+			}
+		}
+	}(stop)
+}
+
+// Stop stops the periodic check if it is running.
+func (h *HealthChecker) Stop() {
+	h.mu.Lock()
+	stop := h.stopCheck
+	h.stopCheck = nil
+	h.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		h.checkWg.Wait()
+	}
+}
+
+// Handler sends the [HealthStatus] as JSON. The status code is 200 if all connections are healthy,
+// otherwise 503. If the periodic check is not started, each request performs the check.
+// Can be registered in the router, for example router.Get("/ready", checker.Handler).
+func (h *HealthChecker) Handler(w http.ResponseWriter, r *http.Request, manager interfaces.Manager) error {
+	return h.writeStatus(w)
+}
+
+// ServeHTTP works like Handler, for use without the router.
+func (h *HealthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.writeStatus(w)
+}
+
+func (h *HealthChecker) writeStatus(w http.ResponseWriter) error {
+	h.mu.RLock()
+	started := h.stopCheck != nil
+	h.mu.RUnlock()
+	var status HealthStatus
+	if started {
+		status = h.Status()
+	} else {
+		status = h.Check()
+	}
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,450 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/uwine4850/foozy/pkg/config"
+	"github.com/uwine4850/foozy/pkg/debug"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// QueryEvent information about a query that is passed to [QueryHook].
+type QueryEvent struct {
+	Query string
+	Args  []any
+	// Exec true for queries executed by the Exec method.
+	Exec bool
+	// Fields below are set after the query is executed.
+	Duration time.Duration
+	// Rows the number of returned rows for reading queries or the number
+	// of affected rows for Exec. -1 if the number is unknown.
+	Rows int64
+	Err  error
+}
+
+// QueryHook is called before and after each query of [SyncQueries].
+// Hooks are added with the [SyncQueries.AddHook] method.
+type QueryHook interface {
+	// BeforeQuery is called before the query. The returned context is passed to AfterQuery.
+	BeforeQuery(ctx context.Context, event *QueryEvent) context.Context
+	// AfterQuery is called after the query, the event contains the result.
+	AfterQuery(ctx context.Context, event *QueryEvent)
+}
+
+// AfterQueryFunc a hook that is only called after the query.
+type AfterQueryFunc func(ctx context.Context, event *QueryEvent)
+
+func (f AfterQueryFunc) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (f AfterQueryFunc) AfterQuery(ctx context.Context, event *QueryEvent) {
+	f(ctx, event)
+}
+
+// HooksFromConfig creates hooks from the Default.Database settings:
+//   - LogQueries — [LogHook], works only in debug mode.
+//   - SlowQueryThreshold — [SlowQueryHook].
+func HooksFromConfig() []QueryHook {
+	cnf := config.LoadedConfig().Default
+	var hooks []QueryHook
+	if cnf.Database.LogQueries && cnf.Debug.Debug {
+		hooks = append(hooks, &LogHook{})
+	}
+	if cnf.Database.SlowQueryThreshold > 0 {
+		hooks = append(hooks, &SlowQueryHook{Threshold: cnf.Database.SlowQueryThreshold, LogPath: cnf.Database.SlowQueryLogPath})
+	}
+	return hooks
+}
+
+// DEFAULT_SENSITIVE_COLUMNS names of columns whose values are hidden in the logs.
+// A column is sensitive if its name contains one of these words.
+var DEFAULT_SENSITIVE_COLUMNS = []string{"password", "token", "secret"}
+
+// REDACTED the value that replaces hidden arguments.
+const REDACTED = "[REDACTED]"
+
+// LogHook writes each query to the request log with the [debug.P_DATABASE] prefix.
+// Arguments of sensitive columns are replaced with [REDACTED].
+type LogHook struct {
+	// SensitiveColumns if empty, [DEFAULT_SENSITIVE_COLUMNS] is used.
+	SensitiveColumns []string
+	// RedactAll hides all arguments.
+	RedactAll bool
+	// Write writes the message. By default, [debug.RequestLogginIfEnable] is used.
+	Write func(message string)
+}
+
+func (h *LogHook) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *LogHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	var args []any
+	if h.RedactAll {
+		args = make([]any, len(event.Args))
+		for i := 0; i < len(args); i++ {
+			args[i] = REDACTED
+		}
+	} else {
+		sensitive := h.SensitiveColumns
+		if len(sensitive) == 0 {
+			sensitive = DEFAULT_SENSITIVE_COLUMNS
+		}
+		args = RedactArgs(event.Query, event.Args, sensitive)
+	}
+	message := fmt.Sprintf("%s %v | %s | rows: %d", event.Query, args, event.Duration, event.Rows)
+	if event.Err != nil {
+		message += " | error: " + event.Err.Error()
+	}
+	if h.Write != nil {
+		h.Write(message)
+		return
+	}
+	debug.RequestLogginIfEnable(debug.P_DATABASE, message)
+}
+
+var (
+	insertRegexp       = regexp.MustCompile(`(?is)^\s*(?:INSERT|REPLACE)\b`)
+	insertValuesRegexp = regexp.MustCompile(`(?is)^\s*(?:INSERT|REPLACE)\s+INTO\s+\S+?\s*(?:\(([^)]*)\)\s*)?VALUES`)
+)
+
+// RedactArgs returns a copy of the arguments in which the values of sensitive columns are replaced with [REDACTED].
+// All placeholders of the query are scanned, and the column of each argument is determined by its index.
+// The query is scanned once. Outside of VALUES, the column is taken from the comparison before the placeholder,
+// for example "password = ?". The column is kept for all placeholders of the IN list and of the function arguments,
+// for example "password IN (?, ?)" or "password = LOWER(?)".
+// Inside VALUES of the INSERT query, the column is taken from the column list by the position of the value in the row.
+// If the mapping is ambiguous, all arguments of the row are hidden: the INSERT query has no column list or
+// the number of values in the row does not match it. The arguments of INSERT ... SELECT are all hidden.
+func RedactArgs(query string, args []any, sensitive []string) []any {
+	res := append([]any(nil), args...)
+	isSensitive := func(column string) bool {
+		column = strings.ToLower(strings.Trim(column[strings.LastIndex(column, ".")+1:], "`\""))
+		for i := 0; i < len(sensitive); i++ {
+			if strings.Contains(column, strings.ToLower(sensitive[i])) {
+				return true
+			}
+		}
+		return false
+	}
+	redact := func(indexes []int) {
+		for i := 0; i < len(indexes); i++ {
+			if indexes[i] < len(res) {
+				res[indexes[i]] = REDACTED
+			}
+		}
+	}
+	valuesStart := -1
+	var insertColumns []string
+	if match := insertValuesRegexp.FindStringSubmatchIndex(query); match != nil {
+		valuesStart = match[1]
+		if match[2] != -1 {
+			for _, col := range strings.Split(query[match[2]:match[3]], ",") {
+				insertColumns = append(insertColumns, strings.TrimSpace(col))
+			}
+		}
+	} else if insertRegexp.MatchString(query) {
+		for i := 0; i < len(res); i++ {
+			res[i] = REDACTED
+		}
+		return res
+	}
+	n := 0
+	var quote rune
+	quoteStart := 0
+	// State of the VALUES part: the depth of the parentheses, the position of the value in the row
+	// and the arguments of the current row.
+	inValues := false
+	depth := 0
+	item := 0
+	var row []int
+	// State of the comparisons: the last identifier, the column whose value is expected
+	// and the depth of the parentheses that keep the column for all their placeholders,
+	// for example "password IN (?, ?)" or "password = LOWER(?)".
+	ident := ""
+	column := ""
+	parens := 0
+	listDepth := 0
+	wordStart := -1
+	for i, r := range query {
+		if i == valuesStart {
+			inValues = true
+		}
+		if inValues && quote == 0 && depth == 0 && r != '(' && r != ',' && !unicode.IsSpace(r) {
+			// The rows have ended, for example ON DUPLICATE KEY UPDATE starts.
+			inValues = false
+		}
+		if wordStart != -1 && !isWordRune(r) {
+			ident, column = compareWord(query, wordStart, i, ident, column, listDepth != 0)
+			wordStart = -1
+		}
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				if r != '\'' {
+					ident = query[quoteStart : i+1]
+				}
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			quoteStart = i
+		case r == '?':
+			if n >= len(res) {
+				return res
+			}
+			if inValues && depth > 0 {
+				row = append(row, n)
+				if item < len(insertColumns) && isSensitive(insertColumns[item]) {
+					res[n] = REDACTED
+				}
+			} else {
+				if column != "" && isSensitive(column) {
+					res[n] = REDACTED
+				}
+				if listDepth == 0 {
+					column = ""
+				}
+				ident = ""
+			}
+			n++
+		case isWordRune(r):
+			if wordStart == -1 {
+				wordStart = i
+			}
+		case inValues:
+			switch r {
+			case '(':
+				depth++
+				if depth == 1 {
+					item = 0
+					row = row[:0]
+				}
+			case ')':
+				depth--
+				if depth == 0 && item+1 != len(insertColumns) {
+					redact(row)
+				}
+			case ',':
+				if depth == 1 {
+					item++
+				}
+			}
+		case r == '=' || r == '<' || r == '>' || r == '!':
+			if ident != "" {
+				column = ident
+			}
+			ident = ""
+		case r == '(':
+			parens++
+			if column != "" && listDepth == 0 {
+				listDepth = parens
+			}
+		case r == ')':
+			if parens == listDepth {
+				listDepth = 0
+				column = ""
+			}
+			parens--
+		case r == ',':
+			if listDepth == 0 {
+				column = ""
+			}
+		}
+	}
+	return res
+}
+
+// isWordRune returns true for the runes of identifiers and keywords.
+func isWordRune(r rune) bool {
+	return r == '_' || r == '.' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// compareWord updates the last identifier and the expected column after the word query[start:end].
+// LIKE and IN make the last identifier the expected column, a function name keeps the column
+// for its arguments, and any other word is a new identifier that ends the previous comparison.
+func compareWord(query string, start int, end int, ident string, column string, inList bool) (string, string) {
+	switch word := query[start:end]; strings.ToUpper(word) {
+	case "NOT":
+		return ident, column
+	case "LIKE", "IN":
+		if ident != "" {
+			column = ident
+		}
+		return "", column
+	default:
+		if next := strings.TrimLeftFunc(query[end:], unicode.IsSpace); strings.HasPrefix(next, "(") {
+			return "", column
+		}
+		if !inList {
+			column = ""
+		}
+		return word, column
+	}
+}
+
+// SlowQueryHook writes queries that take longer than Threshold to the slow query log.
+// Arguments are not written.
+type SlowQueryHook struct {
+	Threshold time.Duration
+	// LogPath path to the log file. If empty, the error log is used.
+	LogPath string
+}
+
+func (h *SlowQueryHook) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *SlowQueryHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	if event.Duration < h.Threshold {
+		return
+	}
+	message := fmt.Sprintf("slow query %s: %s", event.Duration, event.Query)
+	if h.LogPath == "" {
+		debug.LogError(message)
+		return
+	}
+	debug.WriteLog(config.LoadedConfig().Default.Debug.SkipLoggingLevel, h.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, debug.P_DATABASE, message, -1)
+}
+
+// QueryCounter counts the executed queries. It is used to find the N+1 problem,
+// when the same query is executed in a loop. The counter is created for each request
+// and connected using the [WithHooks] function.
+type QueryCounter struct {
+	mu     sync.Mutex
+	total  int
+	counts map[string]int
+}
+
+func NewQueryCounter() *QueryCounter {
+	return &QueryCounter{counts: map[string]int{}}
+}
+
+func (c *QueryCounter) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (c *QueryCounter) AfterQuery(ctx context.Context, event *QueryEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total++
+	c.counts[event.Query]++
+}
+
+// Count returns the total number of queries.
+func (c *QueryCounter) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// Repeated returns the queries that were executed at least min times and their number.
+func (c *QueryCounter) Repeated(min int) map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := map[string]int{}
+	for query, count := range c.counts {
+		if count >= min {
+			res[query] = count
+		}
+	}
+	return res
+}
+
+// Report writes the number of queries to the request log in debug mode.
+// Queries that were executed at least threshold times are reported as a possible N+1 problem.
+func (c *QueryCounter) Report(threshold int) {
+	if !config.LoadedConfig().Default.Debug.Debug {
+		return
+	}
+	debug.RequestLogginIfEnable(debug.P_DATABASE, fmt.Sprintf("queries executed: %d", c.Count()))
+	repeated := c.Repeated(threshold)
+	queries := make([]string, 0, len(repeated))
+	for query := range repeated {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	for i := 0; i < len(queries); i++ {
+		debug.RequestLogginIfEnable(debug.P_DATABASE, fmt.Sprintf("possible N+1 problem, the query is executed %d times: %s", repeated[queries[i]], queries[i]))
+	}
+}
+
+// hookable an object that can add query hooks.
+type hookable interface {
+	AddHook(hooks ...QueryHook)
+}
+
+// HookedDatabase a database whose queries call additional hooks.
+// It is created by the [WithHooks] function.
+type HookedDatabase struct {
+	db    interfaces.DatabaseInteraction
+	syncQ interfaces.SyncQ
+	hooks []QueryHook
+}
+
+// WithHooks returns a database that calls additional hooks for its queries and transactions.
+// The original database is not changed, so the function can be used for a single request,
+// for example with [QueryCounter].
+// The synchronous queries of the database must be [SyncQueries].
+func WithHooks(db interfaces.DatabaseInteraction, hooks ...QueryHook) (*HookedDatabase, error) {
+	syncQ, err := newHookedSyncQ(db.SyncQ(), hooks)
+	if err != nil {
+		return nil, err
+	}
+	return &HookedDatabase{db: db, syncQ: syncQ, hooks: hooks}, nil
+}
+
+func newHookedSyncQ(syncQ interfaces.SyncQ, hooks []QueryHook) (interfaces.SyncQ, error) {
+	newSyncQ, err := syncQ.New()
+	if err != nil {
+		return nil, err
+	}
+	h, ok := newSyncQ.(hookable)
+	if !ok {
+		return nil, ErrHooksNotSupported{}
+	}
+	h.AddHook(hooks...)
+	return newSyncQ.(interfaces.SyncQ), nil
+}
+
+func (d *HookedDatabase) SyncQ() interfaces.SyncQ {
+	return d.syncQ
+}
+
+func (d *HookedDatabase) NewAsyncQ() (interfaces.AsyncQ, error) {
+	aq, err := d.db.NewAsyncQ()
+	if err != nil {
+		return nil, err
+	}
+	aq.SetSyncQueries(d.syncQ)
+	return aq, nil
+}
+
+// NewTransaction creates a transaction of the original database with the hooks.
+func (d *HookedDatabase) NewTransaction() (interfaces.DatabaseTransaction, error) {
+	tx, err := d.db.NewTransaction()
+	if err != nil {
+		return nil, err
+	}
+	h, ok := tx.SyncQ().(hookable)
+	if !ok {
+		return nil, ErrHooksNotSupported{}
+	}
+	h.AddHook(d.hooks...)
+	return tx, nil
+}
+
+type ErrHooksNotSupported struct{}
+
+func (e ErrHooksNotSupported) Error() string {
+	return "the synchronous queries do not support hooks"
+}
@@ -0,0 +1,341 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+const (
+	MIGRATIONS_TABLE      = "schema_migrations"
+	MIGRATIONS_LOCK_TABLE = "schema_migrations_lock"
+)
+
+// Migration one versioned change of the database schema.
+// The migration can be created from go functions or loaded from sql files using [LoadDir].
+// Each migration is performed in a separate transaction, so the Up and Down
+// functions receive the [interfaces.SyncQ] of this transaction.
+// IMPORTANT: some databases, such as MySQL, commit DDL commands immediately,
+// so they cannot be rolled back in case of an error.
+type Migration struct {
+	// Version unique number of the migration. Migrations are applied in ascending order of versions.
+	// Usually the creation time is used, for example 20240101120000.
+	Version int64
+	Name    string
+	Up      func(syncQ interfaces.SyncQ) error
+	Down    func(syncQ interfaces.SyncQ) error
+}
+
+// MigrationStatus shows whether the migration has been applied.
+type MigrationStatus struct {
+	Migration Migration
+	Applied   bool
+}
+
+// Migrator applies and rolls back migrations.
+// Applied migrations are stored in the [MIGRATIONS_TABLE] table.
+// While the migrator is running, the [MIGRATIONS_LOCK_TABLE] table is locked so that
+// several instances of the application cannot run migrations at the same time.
+type Migrator struct {
+	db         interfaces.DatabaseInteraction
+	dialect    qb.Dialect
+	migrations map[int64]Migration
+}
+
+func NewMigrator(db interfaces.DatabaseInteraction, dialect qb.Dialect) *Migrator {
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: map[int64]Migration{},
+	}
+}
+
+// Add adds migrations to the migrator.
+// The version of each migration must be unique.
+func (m *Migrator) Add(migrations ...Migration) error {
+	for i := 0; i < len(migrations); i++ {
+		if _, ok := m.migrations[migrations[i].Version]; ok {
+			return ErrDuplicateMigration{Version: migrations[i].Version}
+		}
+		m.migrations[migrations[i].Version] = migrations[i]
+	}
+	return nil
+}
+
+// LoadDir loads sql migrations from a directory and adds them to the migrator.
+func (m *Migrator) LoadDir(dir string) error {
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		return err
+	}
+	return m.Add(migrations...)
+}
+
+// Migrations returns all added migrations sorted by version.
+func (m *Migrator) Migrations() []Migration {
+	migrations := make([]Migration, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+// Init creates migration tables if they do not exist.
+func (m *Migrator) Init() error {
+	tableQB := qb.NewTableQB().SetDialect(m.dialect).Create(MIGRATIONS_TABLE, []qb.Col{
+		{Name: "version", Type: qb.T{}.BigInt(), PK: true},
+		{Name: "name", Type: qb.T{}.Varchar(255)},
+	}).Build()
+	if _, err := m.db.SyncQ().Exec(tableQB.String()); err != nil {
+		return err
+	}
+	lockQB := qb.NewTableQB().SetDialect(m.dialect).Create(MIGRATIONS_LOCK_TABLE, []qb.Col{
+		{Name: "id", Type: qb.T{}.Int(11), PK: true},
+	}).Build()
+	if _, err := m.db.SyncQ().Exec(lockQB.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Lock locks the migrations. Only one row can be in the lock table, so
+// if another instance has already locked the migrations, the insertion will fail.
+func (m *Migrator) Lock() error {
+	if _, err := m.newQB().Insert(MIGRATIONS_LOCK_TABLE, map[string]any{"id": 1}).Exec(); err != nil {
+		return ErrMigrationsLocked{Err: err}
+	}
+	return nil
+}
+
+// Unlock removes the migration lock.
+// It can also be used to remove a lock that was left after the application crashed.
+func (m *Migrator) Unlock() error {
+	_, err := m.newQB().Delete(MIGRATIONS_LOCK_TABLE).Where(qb.Compare("id", qb.EQUAL, 1)).Exec()
+	return err
+}
+
+// Status returns the status of all known migrations.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.Init(); err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	migrations := m.Migrations()
+	status := make([]MigrationStatus, len(migrations))
+	for i := 0; i < len(migrations); i++ {
+		_, ok := applied[migrations[i].Version]
+		status[i] = MigrationStatus{Migration: migrations[i], Applied: ok}
+	}
+	return status, nil
+}
+
+// Up applies all migrations that have not yet been applied.
+// Returns the applied migrations.
+func (m *Migrator) Up() (applied []Migration, err error) {
+	err = m.locked(func() error {
+		appliedVersions, err := m.appliedVersions()
+		if err != nil {
+			return err
+		}
+		migrations := m.Migrations()
+		for i := 0; i < len(migrations); i++ {
+			if _, ok := appliedVersions[migrations[i].Version]; ok {
+				continue
+			}
+			if err := m.apply(migrations[i], true); err != nil {
+				return err
+			}
+			applied = append(applied, migrations[i])
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the specified number of the last applied migrations.
+// Returns the rolled back migrations.
+func (m *Migrator) Down(steps int) (rolledBack []Migration, err error) {
+	err = m.locked(func() error {
+		appliedVersions, err := m.appliedVersions()
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(appliedVersions))
+		for version := range appliedVersions {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] > versions[j]
+		})
+		for i := 0; i < len(versions) && i < steps; i++ {
+			migration, ok := m.migrations[versions[i]]
+			if !ok {
+				return ErrUnknownMigration{Version: versions[i]}
+			}
+			if err := m.apply(migration, false); err != nil {
+				return err
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// locked runs the function while the migrations are locked.
+func (m *Migrator) locked(fn func() error) error {
+	if err := m.Init(); err != nil {
+		return err
+	}
+	if err := m.Lock(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if unlockErr := m.Unlock(); unlockErr != nil {
+			return errors.Join(err, unlockErr)
+		}
+		return err
+	}
+	return m.Unlock()
+}
+
+// apply applies or rolls back a migration in a separate transaction.
+// The migration table is updated in the same transaction.
+func (m *Migrator) apply(migration Migration, up bool) error {
+	fn := migration.Up
+	if !up {
+		fn = migration.Down
+	}
+	if fn == nil {
+		return ErrMissingMigrationFunc{Version: migration.Version, Up: up}
+	}
+	tx, err := m.db.NewTransaction()
+	if err != nil {
+		return err
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		return err
+	}
+	if err := fn(tx.SyncQ()); err != nil {
+		return rollback(tx, ErrMigrationFailed{Version: migration.Version, Name: migration.Name, Err: err})
+	}
+	var q *qb.QB
+	if up {
+		q = qb.NewSyncQB(tx.SyncQ()).SetDialect(m.dialect).Insert(MIGRATIONS_TABLE, map[string]any{"version": migration.Version, "name": migration.Name})
+	} else {
+		q = qb.NewSyncQB(tx.SyncQ()).SetDialect(m.dialect).Delete(MIGRATIONS_TABLE).Where(qb.Compare("version", qb.EQUAL, migration.Version))
+	}
+	if _, err := q.Exec(); err != nil {
+		return rollback(tx, err)
+	}
+	return tx.CommitTransaction()
+}
+
+// appliedVersions returns the versions of all applied migrations.
+func (m *Migrator) appliedVersions() (map[int64]struct{}, error) {
+	res, err := m.newQB().SelectFrom("version", MIGRATIONS_TABLE).Query()
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]struct{}, len(res))
+	for i := 0; i < len(res); i++ {
+		version, err := parseVersion(res[i]["version"])
+		if err != nil {
+			return nil, err
+		}
+		versions[version] = struct{}{}
+	}
+	return versions, nil
+}
+
+// parseVersion converts the value of the version column to a number.
+// Drivers return the column as a number or as text, for example tables created by older versions
+// of the migrator store the version in a VARCHAR column.
+func parseVersion(value any) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	}
+}
+
+func (m *Migrator) newQB() *qb.QB {
+	return qb.NewSyncQB(m.db.SyncQ()).SetDialect(m.dialect)
+}
+
+func rollback(tx interfaces.DatabaseTransaction, err error) error {
+	if rollbackErr := tx.RollBackTransaction(); rollbackErr != nil {
+		return errors.Join(err, rollbackErr)
+	}
+	return err
+}
+
+type ErrDuplicateMigration struct {
+	Version int64
+}
+
+func (e ErrDuplicateMigration) Error() string {
+	return fmt.Sprintf("migration with version %v already exists", e.Version)
+}
+
+type ErrUnknownMigration struct {
+	Version int64
+}
+
+func (e ErrUnknownMigration) Error() string {
+	return fmt.Sprintf("migration with version %v is applied but not found", e.Version)
+}
+
+type ErrMissingMigrationFunc struct {
+	Version int64
+	Up      bool
+}
+
+func (e ErrMissingMigrationFunc) Error() string {
+	direction := "down"
+	if e.Up {
+		direction = "up"
+	}
+	return fmt.Sprintf("migration %v has no %s function", e.Version, direction)
+}
+
+type ErrMigrationFailed struct {
+	Version int64
+	Name    string
+	Err     error
+}
+
+func (e ErrMigrationFailed) Error() string {
+	return fmt.Sprintf("migration %v_%s failed: %s", e.Version, e.Name, e.Err.Error())
+}
+
+func (e ErrMigrationFailed) Unwrap() error {
+	return e.Err
+}
+
+type ErrMigrationsLocked struct {
+	Err error
+}
+
+func (e ErrMigrationsLocked) Error() string {
+	return fmt.Sprintf("migrations are locked by another process: %s", e.Err.Error())
+}
+
+func (e ErrMigrationsLocked) Unwrap() error {
+	return e.Err
+}
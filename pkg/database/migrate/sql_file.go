@@ -0,0 +1,241 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// VERSION_LAYOUT time format that is used to create the version of a new migration.
+const VERSION_LAYOUT = "20060102150405"
+
+// sqlFileRegexp the name of the sql migration file, for example 20240101120000_create_users.up.sql.
+var sqlFileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadDir loads sql migrations from a directory.
+// Each migration consists of two files: <version>_<name>.up.sql and <version>_<name>.down.sql.
+// The down file is optional. Other files in the directory are ignored.
+func LoadDir(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	migrations := map[int64]*Migration{}
+	var versions []int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := sqlFileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrations[version] = migration
+			versions = append(versions, version)
+		}
+		if migration.Name != match[2] {
+			return nil, ErrDuplicateMigration{Version: version}
+		}
+		if match[3] == "up" {
+			migration.Up = SQLFunc(string(data))
+		} else {
+			migration.Down = SQLFunc(string(data))
+		}
+	}
+	res := make([]Migration, 0, len(versions))
+	for i := 0; i < len(versions); i++ {
+		res = append(res, *migrations[versions[i]])
+	}
+	return res, nil
+}
+
+// SQLFunc creates a migration function that executes sql code.
+// The code is split into separate queries by the ";" sign, because not all drivers
+// can execute several queries at once.
+func SQLFunc(sqlCode string) func(syncQ interfaces.SyncQ) error {
+	statements := SplitStatements(sqlCode)
+	return func(syncQ interfaces.SyncQ) error {
+		for i := 0; i < len(statements); i++ {
+			if _, err := syncQ.Exec(statements[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// SplitStatements splits sql code into separate queries.
+// The ";" sign inside quotes, "--" line comments and "/* */" block comments is not a separator.
+// Comments are removed, except for the MySQL "/*! */" and "/*+ */" comments, which are executed by the database.
+// Inside a quoted string, a backslash escapes the next character as in MySQL, so a string
+// must not end with a single backslash.
+// The ";" sign inside a BEGIN ... END block is not a separator either, so the body of a trigger
+// or a stored procedure stays in one query. BEGIN that starts a transaction does not open a block.
+func SplitStatements(sqlCode string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote rune
+	depth := 0
+	lineComment := false
+	blockComment := false
+	keepComment := false
+	runes := []rune(sqlCode)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case lineComment:
+			if r == '\n' {
+				lineComment = false
+			}
+			continue
+		case blockComment:
+			if r == '*' && i+1 < len(runes) && runes[i+1] == '/' {
+				blockComment = false
+				if keepComment {
+					current.WriteString("*/")
+				}
+				i++
+			} else if keepComment {
+				current.WriteRune(r)
+			}
+			continue
+		case quote != 0:
+			if r == '\\' && quote != '`' && i+1 < len(runes) {
+				current.WriteRune(r)
+				current.WriteRune(runes[i+1])
+				i++
+				continue
+			}
+			if r == quote {
+				quote = 0
+			}
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			lineComment = true
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			blockComment = true
+			keepComment = i+2 < len(runes) && (runes[i+2] == '!' || runes[i+2] == '+')
+			if keepComment {
+				current.WriteString("/*")
+			}
+			i++
+			continue
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case isIdentRune(r) && (i == 0 || !isIdentRune(runes[i-1])):
+			word := wordAt(runes, i)
+			depth = blockDepth(depth, strings.ToUpper(word), strings.ToUpper(nextWord(runes, i+len([]rune(word)))))
+			current.WriteString(word)
+			i += len([]rune(word)) - 1
+			continue
+		case r == ';' && depth > 0:
+		case r == ';':
+			if statement := strings.TrimSpace(current.String()); statement != "" {
+				statements = append(statements, statement)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// transactionWords words after BEGIN that start a transaction instead of a block.
+var transactionWords = map[string]bool{
+	"": true, "TRANSACTION": true, "WORK": true, "DEFERRED": true, "IMMEDIATE": true, "EXCLUSIVE": true,
+}
+
+// endWords words after END that close a block which is not counted by [blockDepth].
+var endWords = map[string]bool{
+	"IF": true, "LOOP": true, "WHILE": true, "REPEAT": true, "FOR": true,
+}
+
+// blockDepth returns the nesting depth of BEGIN ... END blocks after the word.
+// CASE is also counted, because both the CASE expression and the CASE statement end with END.
+func blockDepth(depth int, word string, next string) int {
+	switch word {
+	case "BEGIN":
+		if !transactionWords[next] {
+			return depth + 1
+		}
+	case "CASE":
+		return depth + 1
+	case "END":
+		if depth > 0 && !endWords[next] {
+			return depth - 1
+		}
+	}
+	return depth
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordAt returns the identifier that starts at the position.
+func wordAt(runes []rune, i int) string {
+	j := i
+	for j < len(runes) && isIdentRune(runes[j]) {
+		j++
+	}
+	return string(runes[i:j])
+}
+
+// nextWord returns the identifier that follows the position after the spaces.
+// An empty string is returned if there is no identifier, for example before ";".
+func nextWord(runes []rune, i int) string {
+	for i < len(runes) && unicode.IsSpace(runes[i]) {
+		i++
+	}
+	return wordAt(runes, i)
+}
+
+// NewSQLFiles creates empty up and down files for a new sql migration.
+// The current time is used as the version. Returns the paths to the created files.
+func NewSQLFiles(dir string, name string) (upPath string, downPath string, err error) {
+	return WriteSQLFiles(dir, name, "", "")
+}
+
+// WriteSQLFiles creates up and down files for a new sql migration with the given content.
+// The current time is used as the version. Returns the paths to the created files.
+func WriteSQLFiles(dir string, name string, up string, down string) (upPath string, downPath string, err error) {
+	name = strings.ReplaceAll(strings.TrimSpace(name), " ", "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migration name is empty")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", "", err
+	}
+	base := fmt.Sprintf("%s_%s", time.Now().Format(VERSION_LAYOUT), name)
+	upPath = filepath.Join(dir, base+".up.sql")
+	downPath = filepath.Join(dir, base+".down.sql")
+	if err := os.WriteFile(upPath, []byte(up), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(downPath, []byte(down), 0644); err != nil {
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+	"github.com/uwine4850/foozy/pkg/mapper"
+)
+
+// KeysetColumn the column by which the keyset pagination is performed.
+// Together the columns must uniquely identify the row, so the last column is usually the primary key.
+type KeysetColumn struct {
+	Name string
+	Desc bool
+}
+
+// Page one page of the result.
+// The structure can be passed to the template or sent as JSON.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NextPage number of the next page for the offset pagination.
+func (p *Page[T]) NextPage() int {
+	return p.Page + 1
+}
+
+// PrevPage number of the previous page for the offset pagination.
+func (p *Page[T]) PrevPage() int {
+	return p.Page - 1
+}
+
+// Pages numbers of all pages, used to display the page list in the template.
+func (p *Page[T]) Pages() []int {
+	pages := make([]int, p.TotalPages)
+	for i := 0; i < p.TotalPages; i++ {
+		pages[i] = i + 1
+	}
+	return pages
+}
+
+// Paginator divides the result of the query into pages.
+// The base query must contain only SELECT, FROM, JOIN and WHERE parts, without sorting and limits.
+// The paginator wraps it in a subquery, so the page query and the COUNT(*) query use the same filters.
+// Because of this, the sorting and keyset columns are the names of the result columns.
+//
+// An invalid setting does not stop the chain of calls. The first error is saved and returned
+// by the Err method, the [PaginateOffset] and [PaginateKeyset] functions and the KeysetQuery method.
+type Paginator struct {
+	syncQ     interfaces.SyncQ
+	base      *qb.QB
+	perPage   int
+	order     []string
+	keyset    []KeysetColumn
+	skipTotal bool
+	err       error
+}
+
+// NewPaginator creates a paginator for the base query.
+// The base query can be created with [qb.NewNoDbQB], all queries are executed by syncQ.
+// If perPage is not greater than zero, the [ErrPerPage] error is saved.
+func NewPaginator(syncQ interfaces.SyncQ, base *qb.QB, perPage int) *Paginator {
+	p := &Paginator{syncQ: syncQ, base: base, perPage: perPage}
+	if perPage <= 0 {
+		p.setErr(ErrPerPage{PerPage: perPage})
+	}
+	return p
+}
+
+// Err returns the first error of the paginator settings.
+// The error of building the base query is returned too.
+func (p *Paginator) Err() error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.base.Err()
+}
+
+// setErr saves the error of the settings. Only the first error is saved.
+func (p *Paginator) setErr(err error) {
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+}
+
+// OrderBy sets the sorting for the offset pagination.
+// If the sorting comes from the user, the conditions must be created with [qb.SortAllowlist].
+func (p *Paginator) OrderBy(conditions ...string) *Paginator {
+	p.order = conditions
+	return p
+}
+
+// Keyset sets the columns for the keyset pagination. The rows are sorted by these columns.
+// An invalid column name saves the [qb.ErrInvalidIdent] error.
+func (p *Paginator) Keyset(columns ...KeysetColumn) *Paginator {
+	for i := 0; i < len(columns); i++ {
+		if err := qb.CheckIdent(columns[i].Name); err != nil {
+			p.setErr(err)
+			return p
+		}
+	}
+	p.keyset = columns
+	return p
+}
+
+// WithoutTotal disables the COUNT(*) query. In this case the Total and TotalPages fields of the page are not filled.
+// For large tables it is useful together with the keyset pagination.
+func (p *Paginator) WithoutTotal() *Paginator {
+	p.skipTotal = true
+	return p
+}
+
+// wrap creates a query that selects all rows of the base query.
+func (p *Paginator) wrap() *qb.QB {
+	return qb.NewSyncQB(p.syncQ).SetDialect(p.base.Dialect()).SelectFrom("*", qb.SQ(true, p.base)).As("paginated")
+}
+
+// CountQuery returns a query that counts the rows of the base query.
+// The number of rows is in the "total" column. The query does not contain the errors
+// of the paginator settings, so they must be checked by the Err method.
+func (p *Paginator) CountQuery() *qb.QB {
+	return qb.NewSyncQB(p.syncQ).SetDialect(p.base.Dialect()).SelectFrom("COUNT(*) AS total", qb.SQ(true, p.base)).As("paginated")
+}
+
+// OffsetQuery returns a query for the page with the number page. Numbering starts with 1.
+// One extra row is selected to find out if there is a next page.
+// The errors of the paginator settings must be checked by the Err method.
+func (p *Paginator) OffsetQuery(page int) *qb.QB {
+	if page < 1 {
+		page = 1
+	}
+	q := p.wrap().OrderBy(p.order...).Limit(p.perPage + 1)
+	if page > 1 {
+		q.Offset((page - 1) * p.perPage)
+	}
+	return q
+}
+
+// KeysetQuery returns a query for the page that follows the cursor. An empty cursor means the first page.
+// One extra row is selected to find out if there is a next page.
+func (p *Paginator) KeysetQuery(cursor string) (*qb.QB, error) {
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.keyset) == 0 {
+		return nil, ErrNoKeyset{}
+	}
+	q := p.wrap()
+	if cursor != "" {
+		values, err := p.decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		condition, args := p.keysetCondition(values)
+		q.Custom("WHERE "+condition, args...)
+	}
+	order := make([]string, len(p.keyset))
+	for i := 0; i < len(p.keyset); i++ {
+		if p.keyset[i].Desc {
+			order[i] = qb.DESC(p.keyset[i].Name)
+		} else {
+			order[i] = qb.ASC(p.keyset[i].Name)
+		}
+	}
+	return q.OrderBy(order...).Limit(p.perPage + 1), nil
+}
+
+// keysetCondition creates a condition that selects the rows after the cursor values.
+// For the columns (a, b) it looks like (a > ? OR (a = ? AND b > ?)).
+func (p *Paginator) keysetCondition(values []any) (string, []any) {
+	var or []string
+	var args []any
+	for i := 0; i < len(p.keyset); i++ {
+		var and []string
+		for j := 0; j < i; j++ {
+			and = append(and, fmt.Sprintf("%s = ?", p.keyset[j].Name))
+			args = append(args, values[j])
+		}
+		operator := qb.GREATER
+		if p.keyset[i].Desc {
+			operator = qb.LESS
+		}
+		and = append(and, fmt.Sprintf("%s %s ?", p.keyset[i].Name, operator))
+		args = append(args, values[i])
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	return "(" + strings.Join(or, " OR ") + ")", args
+}
+
+// encodeCursor encodes the keyset column values of the row into an opaque string.
+func (p *Paginator) encodeCursor(row map[string]interface{}) (string, error) {
+	values := make([]any, len(p.keyset))
+	for i := 0; i < len(p.keyset); i++ {
+		value, ok := row[p.keyset[i].Name]
+		if !ok {
+			return "", ErrKeysetColumnNotFound{Column: p.keyset[i].Name}
+		}
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		values[i] = value
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes the values of the keyset columns.
+// Integer values are converted to int64, so they are not rounded like float64.
+func (p *Paginator) decodeCursor(cursor string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor{}
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var values []any
+	if err := decoder.Decode(&values); err != nil || len(values) != len(p.keyset) {
+		return nil, ErrInvalidCursor{}
+	}
+	for i := 0; i < len(values); i++ {
+		number, ok := values[i].(json.Number)
+		if !ok {
+			continue
+		}
+		if n, err := number.Int64(); err == nil {
+			values[i] = n
+		} else if f, err := number.Float64(); err == nil {
+			values[i] = f
+		}
+	}
+	return values, nil
+}
+
+// total executes the COUNT(*) query and returns the number of rows and pages.
+func (p *Paginator) total() (int64, int, error) {
+	res, err := p.CountQuery().Query()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := dbutils.DatabaseResultNotEmpty(res); err != nil {
+		return 0, 0, err
+	}
+	count, err := dbutils.ParseInt(res[0]["total"])
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(count), (count + p.perPage - 1) / p.perPage, nil
+}
+
+// PaginateOffset returns the page with the number page using LIMIT and OFFSET.
+// The structure fields must have the `db:"<column name>"` tag.
+func PaginateOffset[T any](p *Paginator, page int) (*Page[T], error) {
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	rows, err := p.OffsetQuery(page).Query()
+	if err != nil {
+		return nil, err
+	}
+	res := &Page[T]{Page: page, PerPage: p.perPage, HasPrev: page > 1}
+	if len(rows) > p.perPage {
+		res.HasNext = true
+		rows = rows[:p.perPage]
+	}
+	if err := fillPageItems(res, rows); err != nil {
+		return nil, err
+	}
+	if !p.skipTotal {
+		if res.Total, res.TotalPages, err = p.total(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// PaginateKeyset returns the page that follows the cursor. An empty cursor means the first page.
+// The cursor of the next page is in the NextCursor field. The keyset columns must be
+// present in the result and must not contain NULL values.
+func PaginateKeyset[T any](p *Paginator, cursor string) (*Page[T], error) {
+	q, err := p.KeysetQuery(cursor)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Query()
+	if err != nil {
+		return nil, err
+	}
+	res := &Page[T]{PerPage: p.perPage, HasPrev: cursor != ""}
+	if len(rows) > p.perPage {
+		res.HasNext = true
+		rows = rows[:p.perPage]
+		res.NextCursor, err = p.encodeCursor(rows[len(rows)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := fillPageItems(res, rows); err != nil {
+		return nil, err
+	}
+	if !p.skipTotal {
+		if res.Total, res.TotalPages, err = p.total(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func fillPageItems[T any](page *Page[T], rows []map[string]interface{}) error {
+	page.Items = make([]T, len(rows))
+	return mapper.FillStructSliceFromDb(&page.Items, &rows)
+}
+
+type ErrPerPage struct {
+	PerPage int
+}
+
+func (e ErrPerPage) Error() string {
+	return fmt.Sprintf("the number of rows per page must be greater than 0, got %d", e.PerPage)
+}
+
+type ErrNoKeyset struct{}
+
+func (e ErrNoKeyset) Error() string {
+	return "keyset columns are not set"
+}
+
+type ErrInvalidCursor struct{}
+
+func (e ErrInvalidCursor) Error() string {
+	return "invalid pagination cursor"
+}
+
+type ErrKeysetColumnNotFound struct {
+	Column string
+}
+
+func (e ErrKeysetColumnNotFound) Error() string {
+	return fmt.Sprintf("keyset column %s not found in the result", e.Column)
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/uwine4850/foozy/pkg/config"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+const (
+	DRIVER_MYSQL  = "mysql"
+	DRIVER_SQLITE = "sqlite"
+)
+
+// DbArgsFromConfig creates connection arguments from the connection settings.
+func DbArgsFromConfig(cnf *config.ConnectionConfig) DbArgs {
+	return DbArgs{
+		Username:     cnf.Username,
+		Password:     cnf.Password,
+		Host:         cnf.Host,
+		Port:         cnf.Port,
+		DatabaseName: cnf.DatabaseName,
+		Charset:      cnf.Charset,
+		ParseTime:    cnf.ParseTime,
+		TLS:          cnf.TLS,
+		Timeout:      cnf.Timeout,
+		ReadTimeout:  cnf.ReadTimeout,
+		WriteTimeout: cnf.WriteTimeout,
+		Params:       cnf.Params,
+		Pool: PoolArgs{
+			MaxOpenConns:    cnf.MaxOpenConns,
+			MaxIdleConns:    cnf.MaxIdleConns,
+			ConnMaxLifetime: cnf.ConnMaxLifetime,
+			ConnMaxIdleTime: cnf.ConnMaxIdleTime,
+		},
+	}
+}
+
+// NewDatabaseFromConfig creates a database from the connection settings.
+// The database is not opened. For the sqlite driver, DatabaseName is the path to the database file.
+func NewDatabaseFromConfig(cnf *config.ConnectionConfig) (interfaces.Database, error) {
+	syncQ := NewSyncQueries()
+	asyncQ := NewAsyncQueries(syncQ)
+	args := DbArgsFromConfig(cnf)
+	switch cnf.Driver {
+	case DRIVER_MYSQL, "":
+		db := NewMysqlDatabase(args, syncQ, asyncQ)
+		if cnf.StmtCacheSize > 0 {
+			db.EnableStmtCache(cnf.StmtCacheSize)
+		}
+		return db, nil
+	case DRIVER_SQLITE:
+		db := NewSqliteDatabase(cnf.DatabaseName, syncQ, asyncQ)
+		db.SetPool(args.Pool)
+		if cnf.StmtCacheSize > 0 {
+			db.EnableStmtCache(cnf.StmtCacheSize)
+		}
+		return db, nil
+	default:
+		return nil, ErrUnknownDriver{Driver: cnf.Driver}
+	}
+}
+
+// InitDatabasePoolFromConfig opens all connections from the [Default.Database.Connections]
+// settings and adds them to the database pool under their names.
+// The hooks created by the [HooksFromConfig] function are added to each connection.
+// If one of the connections cannot be opened, the already opened connections are closed.
+// Once created, the pool is locked.
+func InitDatabasePoolFromConfig(manager interfaces.Manager) error {
+	connections := config.LoadedConfig().Default.Database.Connections
+	names := make([]string, 0, len(connections))
+	for name := range connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hooks := HooksFromConfig()
+	var opened []interfaces.Database
+	closeOpened := func(err error) error {
+		for i := 0; i < len(opened); i++ {
+			err = errors.Join(err, opened[i].Close())
+		}
+		return err
+	}
+	for i := 0; i < len(names); i++ {
+		cnf := connections[names[i]]
+		db, err := NewDatabaseFromConfig(&cnf)
+		if err != nil {
+			return closeOpened(ErrConnectionConfig{Name: names[i], Err: err})
+		}
+		if h, ok := db.SyncQ().(hookable); ok {
+			h.AddHook(hooks...)
+		}
+		if err := db.Open(); err != nil {
+			return closeOpened(ErrConnectionConfig{Name: names[i], Err: err})
+		}
+		opened = append(opened, db)
+		if err := manager.Database().AddConnection(names[i], db); err != nil {
+			return closeOpened(err)
+		}
+	}
+	manager.Database().Lock()
+	return nil
+}
+
+type ErrUnknownDriver struct {
+	Driver string
+}
+
+func (e ErrUnknownDriver) Error() string {
+	return fmt.Sprintf("unknown database driver %s", e.Driver)
+}
+
+type ErrConnectionConfig struct {
+	Name string
+	Err  error
+}
+
+func (e ErrConnectionConfig) Error() string {
+	return fmt.Sprintf("connection %s: %s", e.Name, e.Err.Error())
+}
+
+func (e ErrConnectionConfig) Unwrap() error {
+	return e.Err
+}
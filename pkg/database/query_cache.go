@@ -0,0 +1,415 @@
+package database
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DEFAULT_QUERY_CACHE_SIZE the number of query results that are stored
+// in the [LRUCacheStore] if the size is not specified.
+const DEFAULT_QUERY_CACHE_SIZE = 1000
+
+// QueryCacheEntry the cached result of the query.
+type QueryCacheEntry struct {
+	Rows []map[string]interface{}
+	// Tags versions of the tables of the query at the moment it was executed.
+	Tags    map[string]uint64
+	Expires time.Time
+}
+
+// QueryCacheStore storage of the cached results.
+// The [LRUCacheStore] is used by default, another storage can be used by implementing this interface.
+// The storage must be safe for concurrent use. The expiration of the entries is checked by [QueryCache].
+type QueryCacheStore interface {
+	Get(key string) (*QueryCacheEntry, bool)
+	Set(key string, entry *QueryCacheEntry)
+	Delete(key string)
+	Clear()
+	Len() int
+}
+
+// LRUCacheStore in-memory storage of the results.
+// When the storage is full, the least recently used result is removed.
+type LRUCacheStore struct {
+	mu       sync.Mutex
+	capacity int
+	list     *list.List
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry *QueryCacheEntry
+}
+
+// NewLRUCacheStore creates a storage for up to capacity results.
+// If capacity is not greater than zero, [DEFAULT_QUERY_CACHE_SIZE] is used.
+func NewLRUCacheStore(capacity int) *LRUCacheStore {
+	if capacity <= 0 {
+		capacity = DEFAULT_QUERY_CACHE_SIZE
+	}
+	return &LRUCacheStore{
+		capacity: capacity,
+		list:     list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (s *LRUCacheStore) Get(key string) (*QueryCacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.list.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+func (s *LRUCacheStore) Set(key string, entry *QueryCacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		el.Value.(*lruItem).entry = entry
+		s.list.MoveToFront(el)
+		return
+	}
+	s.items[key] = s.list.PushFront(&lruItem{key: key, entry: entry})
+	for s.list.Len() > s.capacity {
+		el := s.list.Back()
+		s.list.Remove(el)
+		delete(s.items, el.Value.(*lruItem).key)
+	}
+}
+
+func (s *LRUCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.list.Remove(el)
+		delete(s.items, key)
+	}
+}
+
+func (s *LRUCacheStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list.Init()
+	s.items = map[string]*list.Element{}
+}
+
+func (s *LRUCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list.Len()
+}
+
+// QueryCacheStats statistics of the query result cache.
+type QueryCacheStats struct {
+	// Number of results in the storage.
+	Size int
+	// Number of queries whose result was taken from the cache.
+	Hits uint64
+	// Number of queries that were executed by the database.
+	Misses uint64
+	// Number of invalidated tables.
+	Invalidations uint64
+}
+
+// QueryCache a cache of the results of reading queries. The key is the text of the sql query and its arguments.
+// Each result is tagged with the tables of the query. When an Exec query changes a table,
+// all results of this table become stale. If the tables of the Exec query cannot be determined,
+// the whole cache is cleared.
+//
+// The cache is enabled by the [SyncQueries.SetCache] method. If ttl is greater than zero, all SELECT queries are cached
+// for this time, otherwise only queries that are executed with the [SyncQueries.QueryCache] method or [qb.QB.Cache].
+// Queries inside a transaction are not cached, and the tables changed by the transaction are invalidated again after the commit.
+//
+// IMPORTANT: the cache only knows about the changes made by the queries of this application.
+// Changes made by other applications are visible only after the ttl expires.
+type QueryCache struct {
+	store         QueryCacheStore
+	ttl           time.Duration
+	mu            sync.Mutex
+	versions      map[string]uint64
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+}
+
+// NewQueryCache creates a cache. If store is nil, [LRUCacheStore] with the default size is used.
+func NewQueryCache(store QueryCacheStore, ttl time.Duration) *QueryCache {
+	if store == nil {
+		store = NewLRUCacheStore(0)
+	}
+	return &QueryCache{
+		store:    store,
+		ttl:      ttl,
+		versions: map[string]uint64{},
+	}
+}
+
+// TTL returns the time for which all SELECT queries are cached. 0 means that only selected queries are cached.
+func (c *QueryCache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Fetch returns the cached result of the query. If there is no result or it is stale,
+// fetch is called and its result is stored for ttl. Errors are not cached.
+// The returned rows are a copy, so they can be changed.
+func (c *QueryCache) Fetch(ttl time.Duration, query string, args []any, fetch func() ([]map[string]interface{}, error)) ([]map[string]interface{}, error) {
+	key := queryCacheKey(query, args)
+	tables := QueryTables(query)
+	if entry, ok := c.store.Get(key); ok {
+		if time.Now().Before(entry.Expires) && c.fresh(entry.Tags) {
+			c.count(&c.hits)
+			return copyRows(entry.Rows), nil
+		}
+		c.store.Delete(key)
+	}
+	c.count(&c.misses)
+	// The versions are read before the query, so a change made during the query makes the result stale.
+	tags := c.tags(tables)
+	rows, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.store.Set(key, &QueryCacheEntry{Rows: copyRows(rows), Tags: tags, Expires: time.Now().Add(ttl)})
+	return rows, nil
+}
+
+// Invalidate makes the cached results of the tables stale.
+func (c *QueryCache) Invalidate(tables ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < len(tables); i++ {
+		c.versions[normalizeTable(tables[i])]++
+		c.invalidations++
+	}
+}
+
+// Clear removes all results from the cache.
+func (c *QueryCache) Clear() {
+	c.store.Clear()
+}
+
+// Stats returns the current statistics of the cache.
+func (c *QueryCache) Stats() QueryCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return QueryCacheStats{
+		Size:          c.store.Len(),
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Invalidations: c.invalidations,
+	}
+}
+
+// invalidateQuery invalidates the tables changed by the query.
+func (c *QueryCache) invalidateQuery(tables []string) {
+	if len(tables) == 0 {
+		c.Clear()
+		return
+	}
+	c.Invalidate(tables...)
+}
+
+func (c *QueryCache) count(counter *uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*counter++
+}
+
+func (c *QueryCache) tags(tables []string) map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tags := make(map[string]uint64, len(tables))
+	for i := 0; i < len(tables); i++ {
+		tags[tables[i]] = c.versions[tables[i]]
+	}
+	return tags
+}
+
+func (c *QueryCache) fresh(tags map[string]uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for table, version := range tags {
+		if c.versions[table] != version {
+			return false
+		}
+	}
+	return true
+}
+
+// tablesPattern finds the names of the tables after the FROM, JOIN, INTO, UPDATE and TABLE keywords,
+// including lists separated by a comma, for example "FROM a, b AS c".
+var tablesPattern = regexp.MustCompile("(?i)\\b(?:FROM|JOIN|INTO|UPDATE|TABLE)\\s+((?:[`\"\\[]?[\\w$.]+[`\"\\]]?(?:\\s+(?:AS\\s+)?\\w+)?\\s*,\\s*)*[`\"\\[]?[\\w$.]+[`\"\\]]?)")
+
+// QueryTables returns the names of the tables used in the query.
+// The names are in lower case and without the schema name and quotes.
+func QueryTables(query string) []string {
+	tables := []string{}
+	seen := map[string]bool{}
+	for _, match := range tablesPattern.FindAllStringSubmatch(query, -1) {
+		for _, part := range strings.Split(match[1], ",") {
+			fields := strings.Fields(part)
+			if len(fields) == 0 {
+				continue
+			}
+			table := normalizeTable(fields[0])
+			if table != "" && !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+	return tables
+}
+
+func normalizeTable(name string) string {
+	name = strings.Trim(name, "`\"[]")
+	if i := strings.LastIndex(name, "."); i != -1 {
+		name = strings.Trim(name[i+1:], "`\"[]")
+	}
+	return strings.ToLower(name)
+}
+
+// cacheableQuery returns true for the queries that only read data.
+// The query must be SELECT, or WITH whose main statement after the list of CTEs is SELECT.
+// A query that changes data anywhere, for example in a CTE, locks rows with FOR UPDATE, FOR SHARE
+// or LOCK IN SHARE MODE, or writes the result with SELECT ... INTO is not cacheable.
+func cacheableQuery(query string) bool {
+	words := queryWords(query)
+	if len(words) == 0 {
+		return false
+	}
+	statement := words[0].word
+	if statement == "WITH" {
+		statement = ""
+		for i := 1; i < len(words); i++ {
+			if words[i].depth == 0 && statementKeywords[words[i].word] {
+				statement = words[i].word
+				break
+			}
+		}
+	}
+	if statement != "SELECT" {
+		return false
+	}
+	for i := 0; i < len(words); i++ {
+		switch words[i].word {
+		case "INSERT", "UPDATE", "DELETE", "MERGE":
+			return false
+		case "INTO":
+			if words[i].depth == 0 {
+				return false
+			}
+		case "FOR", "LOCK":
+			if i+1 < len(words) && lockKeywords[words[i+1].word] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// statementKeywords the keywords that start the main statement after the list of CTEs.
+var statementKeywords = map[string]bool{"SELECT": true, "INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "REPLACE": true}
+
+// lockKeywords the keywords after FOR or LOCK that lock the read rows,
+// for example FOR SHARE, FOR NO KEY UPDATE or LOCK IN SHARE MODE.
+var lockKeywords = map[string]bool{"UPDATE": true, "SHARE": true, "NO": true, "KEY": true, "IN": true}
+
+type queryWord struct {
+	word string
+	// depth the number of parentheses in which the word is located.
+	depth int
+}
+
+// queryWords returns the words of the query in upper case. Quoted strings and identifiers are skipped,
+// a backslash escapes the next character in a string.
+func queryWords(query string) []queryWord {
+	var words []queryWord
+	var quote rune
+	var escaped bool
+	depth := 0
+	start := -1
+	for i, r := range query {
+		isWordRune := quote == 0 && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+		if start != -1 && !isWordRune {
+			words = append(words, queryWord{word: strings.ToUpper(query[start:i]), depth: depth})
+			start = -1
+		}
+		switch {
+		case quote != 0:
+			if escaped {
+				escaped = false
+			} else if r == '\\' && quote != '`' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case isWordRune && start == -1:
+			start = i
+		}
+	}
+	if start != -1 {
+		words = append(words, queryWord{word: strings.ToUpper(query[start:]), depth: depth})
+	}
+	return words
+}
+
+// queryCacheKey creates the key from the query and the types and values of its arguments.
+// The arguments are first converted in the same way as the driver does, so pointers are keyed
+// by the value they point to and [driver.Valuer] by the value it returns.
+func queryCacheKey(query string, args []any) string {
+	var key strings.Builder
+	key.WriteString(query)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if value, err := driver.DefaultParameterConverter.ConvertValue(arg); err == nil {
+			arg = value
+		}
+		switch v := arg.(type) {
+		case []byte:
+			fmt.Fprintf(&key, "\x00[]byte:%x", v)
+		case time.Time:
+			// The %v format of the time includes the monotonic clock reading.
+			fmt.Fprintf(&key, "\x00time:%s", v.UTC().Format(time.RFC3339Nano))
+		default:
+			fmt.Fprintf(&key, "\x00%T:%v", v, v)
+		}
+	}
+	return key.String()
+}
+
+// copyRows copies the rows so that changing the result does not change the cache.
+func copyRows(rows []map[string]interface{}) []map[string]interface{} {
+	if rows == nil {
+		return nil
+	}
+	res := make([]map[string]interface{}, len(rows))
+	for i := 0; i < len(rows); i++ {
+		row := make(map[string]interface{}, len(rows[i]))
+		for column, value := range rows[i] {
+			if b, ok := value.([]byte); ok {
+				value = append([]byte(nil), b...)
+			}
+			row[column] = value
+		}
+		res[i] = row
+	}
+	return res
+}
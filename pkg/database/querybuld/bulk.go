@@ -0,0 +1,72 @@
+package qb
+
+import (
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// BulkOptions settings of the [BulkInsert] function.
+type BulkOptions struct {
+	// Dialect if nil, [DefaultDialect] is used.
+	Dialect Dialect
+	// MaxPlaceholders the maximum number of placeholders in one query.
+	// If 0, the limit of the dialect is used.
+	MaxPlaceholders int
+	// ConflictColumns and UpdateColumns enable upsert, more details in the [QB.Upsert] method.
+	// If only ConflictColumns are set, the rows that already exist are left unchanged.
+	ConflictColumns []string
+	UpdateColumns   []string
+}
+
+// ChunkRows splits the rows into chunks so that the number of placeholders in each chunk
+// does not exceed maxPlaceholders.
+func ChunkRows(columns int, rows [][]any, maxPlaceholders int) [][][]any {
+	size := len(rows)
+	if columns > 0 {
+		size = maxPlaceholders / columns
+	}
+	if size < 1 {
+		size = 1
+	}
+	var chunks [][][]any
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		chunks = append(chunks, rows[start:end])
+	}
+	return chunks
+}
+
+// BulkInsert inserts the rows with multi-row INSERT queries. The rows are split into chunks
+// so as not to exceed the placeholder limit, each chunk is a separate query.
+// Returns the total number of affected rows.
+// IMPORTANT: chunks are executed one by one, so to insert all rows atomically,
+// the function must be called with the synchronous queries of a transaction.
+func BulkInsert(syncQ interfaces.SyncQ, tableName string, columns []string, rows [][]any, opts BulkOptions) (int64, error) {
+	dialect := opts.Dialect
+	if dialect == nil {
+		dialect = DefaultDialect
+	}
+	maxPlaceholders := opts.MaxPlaceholders
+	if maxPlaceholders <= 0 {
+		maxPlaceholders = dialect.MaxPlaceholders()
+	}
+	var total int64
+	chunks := ChunkRows(len(columns), rows, maxPlaceholders)
+	for i := 0; i < len(chunks); i++ {
+		q := NewSyncQB(syncQ).SetDialect(dialect).InsertMany(tableName, columns, chunks[i])
+		if len(opts.ConflictColumns) > 0 || len(opts.UpdateColumns) > 0 {
+			q.Upsert(opts.ConflictColumns, opts.UpdateColumns)
+		}
+		res, err := q.Exec()
+		if err != nil {
+			return total, err
+		}
+		if n := dbutils.RowsAffected(res); n > 0 {
+			total += n
+		}
+	}
+	return total, nil
+}
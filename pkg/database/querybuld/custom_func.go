@@ -5,6 +5,9 @@ import "fmt"
 // SelectExists checks if there is a value in the table.
 // It is important to use a condition for correct operation.
 func SelectExists(qb *QB, tableName string, whereValues ...any) (bool, error) {
+	if err := CheckIdent(tableName); err != nil {
+		return false, err
+	}
 	exSQ := SQ(false, NewNoDbQB().SelectFrom("1", tableName).Where(whereValues...))
 	baseSQ := SQ(false, NewNoDbQB().Select(Exists(exSQ)).As("is_exists"))
 	qb.Func(baseSQ).Merge()
@@ -17,6 +20,12 @@ func SelectExists(qb *QB, tableName string, whereValues ...any) (bool, error) {
 
 // Increment increases the numeric value of the table by one.
 func Increment(qb *QB, tableName string, field string, whereValues ...any) (bool, error) {
+	if err := CheckIdent(tableName); err != nil {
+		return false, err
+	}
+	if err := CheckIdent(field); err != nil {
+		return false, err
+	}
 	customQ := fmt.Sprintf("UPDATE %s SET %s = %s + 1", tableName, field, field)
 	baseSQ := SQ(false, NewNoDbQB().Custom(customQ).Where(whereValues...))
 	qb.Func(baseSQ).Merge()
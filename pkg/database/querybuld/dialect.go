@@ -0,0 +1,432 @@
+package qb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect describes the differences in the sql syntax of the databases.
+// The query builder always creates a query with "?" placeholders and
+// MySQL-like syntax, and the dialect converts the parts that differ.
+// The dialect is selected for each [QB] or [TableQB] instance, by default
+// the [DefaultDialect] is used.
+type Dialect interface {
+	// Name returns the name of the dialect.
+	Name() string
+	// Placeholder returns a positional argument with the number n.
+	// Numbering starts with 1.
+	Placeholder(n int) string
+	// QuoteIdent quotes the name of a table or column.
+	// A name with a dot, for example "table.column", is quoted in parts.
+	QuoteIdent(name string) string
+	// Limit returns the part of the query that limits the number of rows.
+	Limit(number int) string
+	// Offset returns the part of the query that skips the rows.
+	Offset(number int) string
+	// Upsert returns the part of the INSERT query that updates the updateColumns
+	// if a row with the same conflictColumns already exists.
+	// If updateColumns are empty, the existing row is left unchanged.
+	// Returns the [ErrNoConflictColumns] error if the dialect needs conflictColumns, but they are empty.
+	Upsert(conflictColumns []string, updateColumns []string) (string, error)
+	// TypeName returns the name of the data type of the column.
+	TypeName(t T) string
+	// Column returns the full definition of the table column.
+	Column(c *Col) string
+	// ModifyColumn returns a query that changes the definition of an existing column.
+	// Returns the [ErrNotSupported] error if the dialect cannot change columns.
+	ModifyColumn(tableName string, c *Col) (string, error)
+	// DropIndex returns a query that removes the index.
+	DropIndex(tableName string, indexName string) string
+	// Truncate returns a query that deletes all rows from tables.
+	Truncate(tables []string) string
+	// Rand returns the function that generates a random number.
+	Rand() string
+	// MaxPlaceholders returns the maximum number of placeholders in one query.
+	MaxPlaceholders() int
+}
+
+var (
+	MYSQL    Dialect = MysqlDialect{}
+	POSTGRES Dialect = PostgresDialect{}
+	SQLITE   Dialect = SqliteDialect{}
+)
+
+// DefaultDialect the dialect that is used by new [QB] and [TableQB] instances.
+// It can be changed once at application startup if only one database is used.
+var DefaultDialect = MYSQL
+
+// Rebind replaces all "?" placeholders in the query with placeholders of the selected dialect.
+// Question marks inside quoted strings and identifiers are not replaced.
+func Rebind(dialect Dialect, query string) string {
+	if dialect.Placeholder(1) == "?" {
+		return query
+	}
+	var builder strings.Builder
+	var quote rune
+	n := 0
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '?':
+			n++
+			builder.WriteString(dialect.Placeholder(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// quoteIdentWith quotes each part of the name separated by a dot.
+// The "*" value is not quoted.
+func quoteIdentWith(name string, quote string) string {
+	parts := strings.Split(name, ".")
+	for i := 0; i < len(parts); i++ {
+		if parts[i] == "*" {
+			continue
+		}
+		parts[i] = quote + strings.ReplaceAll(parts[i], quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
+
+// columnNull returns the NULL or NOT NULL part of the column.
+func columnNull(c *Col) string {
+	if c.Null {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// columnTail adds the default value and the primary key to the column definition.
+func columnTail(def string, c *Col) string {
+	if c.Default != "" {
+		def += " " + c.Default
+	}
+	if c.PK {
+		def += " PRIMARY KEY"
+	}
+	return def
+}
+
+// excludedColumns creates the list "col = <prefix>col" for the upsert query.
+func excludedColumns(updateColumns []string, value func(col string) string) string {
+	sets := make([]string, len(updateColumns))
+	for i := 0; i < len(updateColumns); i++ {
+		sets[i] = fmt.Sprintf("%s = %s", updateColumns[i], value(updateColumns[i]))
+	}
+	return strings.Join(sets, ", ")
+}
+
+// MysqlDialect dialect of the MySQL database.
+type MysqlDialect struct{}
+
+func (d MysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (d MysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (d MysqlDialect) QuoteIdent(name string) string {
+	return quoteIdentWith(name, "`")
+}
+
+func (d MysqlDialect) Limit(number int) string {
+	return fmt.Sprintf("LIMIT %v", number)
+}
+
+func (d MysqlDialect) Offset(number int) string {
+	return fmt.Sprintf("OFFSET %v", number)
+}
+
+// Upsert uses the VALUES(col) function, which is deprecated since MySQL 8.0.20 in favor of the row alias,
+// but is still supported by it, by older versions and by MariaDB, which has no row alias.
+// MySQL does not use conflictColumns, but if updateColumns are empty, the first conflict column is
+// assigned to itself, so the existing row is left unchanged. If both are empty, the [ErrNoConflictColumns] error is returned.
+func (d MysqlDialect) Upsert(conflictColumns []string, updateColumns []string) (string, error) {
+	if len(updateColumns) == 0 {
+		if len(conflictColumns) == 0 {
+			return "", ErrNoConflictColumns{Dialect: d.Name()}
+		}
+		return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", conflictColumns[0], conflictColumns[0]), nil
+	}
+	return "ON DUPLICATE KEY UPDATE " + excludedColumns(updateColumns, func(col string) string {
+		return fmt.Sprintf("VALUES(%s)", col)
+	}), nil
+}
+
+func (d MysqlDialect) TypeName(t T) string {
+	switch t.kind {
+	case typeInt:
+		return fmt.Sprintf("INT(%v)", t.size[0])
+	case typeEnum:
+		return fmt.Sprintf("ENUM(%s)", quoteValues(t.values))
+	case typeUUID:
+		return "CHAR(36)"
+	default:
+		return commonTypeName(t)
+	}
+}
+
+func (d MysqlDialect) Column(c *Col) string {
+	def := fmt.Sprintf("%s %s %s", c.Name, d.TypeName(c.Type), columnNull(c))
+	if c.AI {
+		def += " AUTO_INCREMENT"
+	}
+	return columnTail(def, c)
+}
+
+func (d MysqlDialect) ModifyColumn(tableName string, c *Col) (string, error) {
+	return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", tableName, d.Column(c)), nil
+}
+
+func (d MysqlDialect) DropIndex(tableName string, indexName string) string {
+	return fmt.Sprintf("DROP INDEX %s ON %s;", indexName, tableName)
+}
+
+func (d MysqlDialect) Truncate(tables []string) string {
+	queries := make([]string, len(tables))
+	for i := 0; i < len(tables); i++ {
+		queries[i] = fmt.Sprintf("TRUNCATE TABLE %s;", tables[i])
+	}
+	return strings.Join(queries, " ")
+}
+
+func (d MysqlDialect) Rand() string {
+	return "RAND()"
+}
+
+func (d MysqlDialect) MaxPlaceholders() int {
+	return 65535
+}
+
+// PostgresDialect dialect of the PostgreSQL database.
+type PostgresDialect struct{}
+
+func (d PostgresDialect) Name() string {
+	return "postgres"
+}
+
+func (d PostgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%v", n)
+}
+
+func (d PostgresDialect) QuoteIdent(name string) string {
+	return quoteIdentWith(name, `"`)
+}
+
+func (d PostgresDialect) Limit(number int) string {
+	return fmt.Sprintf("LIMIT %v", number)
+}
+
+func (d PostgresDialect) Offset(number int) string {
+	return fmt.Sprintf("OFFSET %v", number)
+}
+
+func (d PostgresDialect) Upsert(conflictColumns []string, updateColumns []string) (string, error) {
+	return onConflictUpsert(d, conflictColumns, updateColumns)
+}
+
+func (d PostgresDialect) TypeName(t T) string {
+	switch t.kind {
+	case typeInt:
+		return "INTEGER"
+	case typeDateTime:
+		return "TIMESTAMP"
+	case typeBlob:
+		return "BYTEA"
+	case typeEnum:
+		return fmt.Sprintf("VARCHAR(%v)", maxLen(t.values))
+	default:
+		return commonTypeName(t)
+	}
+}
+
+// Column creates the column definition.
+// For an auto-increment column an identity column is used.
+func (d PostgresDialect) Column(c *Col) string {
+	def := fmt.Sprintf("%s %s %s", c.Name, d.TypeName(c.Type), columnNull(c))
+	if c.AI {
+		def += " GENERATED BY DEFAULT AS IDENTITY"
+	}
+	return columnTail(def, c)
+}
+
+// ModifyColumn changes the type and nullability of the column.
+// The default value is not changed.
+func (d PostgresDialect) ModifyColumn(tableName string, c *Col) (string, error) {
+	null := "SET NOT NULL"
+	if c.Null {
+		null = "DROP NOT NULL"
+	}
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s, ALTER COLUMN %s %s;", tableName, c.Name, d.TypeName(c.Type), c.Name, null), nil
+}
+
+func (d PostgresDialect) DropIndex(tableName string, indexName string) string {
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s;", indexName)
+}
+
+func (d PostgresDialect) Truncate(tables []string) string {
+	return fmt.Sprintf("TRUNCATE TABLE %s;", strings.Join(tables, ", "))
+}
+
+func (d PostgresDialect) Rand() string {
+	return "RANDOM()"
+}
+
+func (d PostgresDialect) MaxPlaceholders() int {
+	return 65535
+}
+
+// SqliteDialect dialect of the sqlite database.
+type SqliteDialect struct{}
+
+func (d SqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (d SqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (d SqliteDialect) QuoteIdent(name string) string {
+	return quoteIdentWith(name, `"`)
+}
+
+func (d SqliteDialect) Limit(number int) string {
+	return fmt.Sprintf("LIMIT %v", number)
+}
+
+func (d SqliteDialect) Offset(number int) string {
+	return fmt.Sprintf("OFFSET %v", number)
+}
+
+func (d SqliteDialect) Upsert(conflictColumns []string, updateColumns []string) (string, error) {
+	return onConflictUpsert(d, conflictColumns, updateColumns)
+}
+
+func (d SqliteDialect) TypeName(t T) string {
+	switch t.kind {
+	case typeInt, typeBigInt:
+		return "INTEGER"
+	case typeDecimal:
+		return "NUMERIC"
+	case typeJSON, typeEnum, typeUUID:
+		return "TEXT"
+	default:
+		return commonTypeName(t)
+	}
+}
+
+// Column creates the column definition.
+// In sqlite, auto-increment is only possible for the INTEGER PRIMARY KEY column,
+// so such a column is always created as a primary key.
+func (d SqliteDialect) Column(c *Col) string {
+	def := fmt.Sprintf("%s %s %s", c.Name, d.TypeName(c.Type), columnNull(c))
+	if c.AI {
+		if c.Default != "" {
+			def += " " + c.Default
+		}
+		return def + " PRIMARY KEY AUTOINCREMENT"
+	}
+	return columnTail(def, c)
+}
+
+// ModifyColumn sqlite cannot change existing columns, the table must be recreated.
+// Therefore, this method returns the [ErrNotSupported] error.
+func (d SqliteDialect) ModifyColumn(tableName string, c *Col) (string, error) {
+	return "", ErrNotSupported{Dialect: d.Name(), Operation: "MODIFY COLUMN"}
+}
+
+func (d SqliteDialect) DropIndex(tableName string, indexName string) string {
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s;", indexName)
+}
+
+// Truncate sqlite has no TRUNCATE command, so the DELETE command is used.
+func (d SqliteDialect) Truncate(tables []string) string {
+	queries := make([]string, len(tables))
+	for i := 0; i < len(tables); i++ {
+		queries[i] = fmt.Sprintf("DELETE FROM %s;", tables[i])
+	}
+	return strings.Join(queries, " ")
+}
+
+func (d SqliteDialect) Rand() string {
+	return "RANDOM()"
+}
+
+// MaxPlaceholders the limit of sqlite since version 3.32.0.
+func (d SqliteDialect) MaxPlaceholders() int {
+	return 32766
+}
+
+// commonTypeName the name of the data type that is the same for all dialects.
+func commonTypeName(t T) string {
+	switch t.kind {
+	case typeVarchar:
+		return fmt.Sprintf("VARCHAR(%v)", t.size[0])
+	case typeDecimal:
+		return fmt.Sprintf("DECIMAL(%v, %v)", t.size[0], t.size[1])
+	default:
+		return t.kind
+	}
+}
+
+// quoteValues creates a list of sql string values.
+func quoteValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i := 0; i < len(values); i++ {
+		quoted[i] = "'" + strings.ReplaceAll(values[i], "'", "''") + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// maxLen returns the length of the longest value.
+func maxLen(values []string) int {
+	n := 1
+	for i := 0; i < len(values); i++ {
+		if len(values[i]) > n {
+			n = len(values[i])
+		}
+	}
+	return n
+}
+
+// onConflictUpsert returns the ON CONFLICT DO UPDATE clause, which is used by PostgreSQL and sqlite.
+// The conflict target is required, without it the clause is invalid.
+func onConflictUpsert(dialect Dialect, conflictColumns []string, updateColumns []string) (string, error) {
+	if len(conflictColumns) == 0 {
+		return "", ErrNoConflictColumns{Dialect: dialect.Name()}
+	}
+	if len(updateColumns) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(conflictColumns, ", ")), nil
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(conflictColumns, ", "),
+		excludedColumns(updateColumns, func(col string) string {
+			return "EXCLUDED." + col
+		})), nil
+}
+
+type ErrNoConflictColumns struct {
+	Dialect string
+}
+
+func (e ErrNoConflictColumns) Error() string {
+	return fmt.Sprintf("the %s dialect requires conflict columns for UPSERT", e.Dialect)
+}
+
+type ErrNotSupported struct {
+	Dialect   string
+	Operation string
+}
+
+func (e ErrNotSupported) Error() string {
+	return fmt.Sprintf("%s is not supported by the %s dialect", e.Operation, e.Dialect)
+}
@@ -61,19 +61,28 @@ func (fqb *filterQB) SetQB(qb *QB) {
 	fqb.mainQB = qb
 }
 
+// Where adds the WHERE condition.
+// Raw string values are inserted as is, user values must be passed through [Compare] and similar builders.
 func (fqb *filterQB) Where(values ...any) *QB {
 	if len(values) == 0 {
 		return fqb.mainQB
 	}
 	qString := "WHERE "
 	qArgs := []any{}
+	fqb.mainQB.setErr(subqueryErr(values...))
 	processingConditionValues(values, &qString, &qArgs)
 	fqb.mainQB.AppendPart(qString)
 	fqb.mainQB.AppendArgs(qArgs)
 	return fqb.mainQB
 }
 
+// OrderBy adds sorting. Nothing is added if there are no conditions.
+// IMPORTANT: the conditions are inserted as is. If the sorting comes from the user,
+// the conditions must be created with [SortAllowlist].
 func (fqb *filterQB) OrderBy(conditions ...string) *QB {
+	if len(conditions) == 0 {
+		return fqb.mainQB
+	}
 	var qString string
 	for i := 0; i < len(conditions); i++ {
 		qString = strings.Join(conditions, ", ")
@@ -82,6 +91,7 @@ func (fqb *filterQB) OrderBy(conditions ...string) *QB {
 	return fqb.mainQB
 }
 
+// GroupBy adds grouping. The values are inserted as is.
 func (fqb *filterQB) GroupBy(values ...string) *QB {
 	qString := "GROUP BY "
 	qString += strings.Join(values, ", ")
@@ -92,6 +102,7 @@ func (fqb *filterQB) GroupBy(values ...string) *QB {
 func (fqb *filterQB) Having(values ...any) *QB {
 	qString := "HAVING "
 	qArgs := []any{}
+	fqb.mainQB.setErr(subqueryErr(values...))
 	processingConditionValues(values, &qString, &qArgs)
 	fqb.mainQB.AppendPart(qString)
 	fqb.mainQB.AppendArgs(qArgs)
@@ -99,12 +110,12 @@ func (fqb *filterQB) Having(values ...any) *QB {
 }
 
 func (fqb *filterQB) Limit(number int) *QB {
-	fqb.mainQB.AppendPart(fmt.Sprintf("LIMIT %v", number))
+	fqb.mainQB.AppendPart(fqb.mainQB.dialect.Limit(number))
 	return fqb.mainQB
 }
 
 func (fqb *filterQB) Offset(number int) *QB {
-	fqb.mainQB.AppendPart(fmt.Sprintf("OFFSET %v", number))
+	fqb.mainQB.AppendPart(fqb.mainQB.dialect.Offset(number))
 	return fqb.mainQB
 }
 
@@ -299,17 +310,18 @@ func Array(values ...any) *array {
 }
 
 func (arr *array) Build() {
-	arr.qString = "("
+	arr.qArgs = []any{}
+	items := make([]string, len(arr.values))
 	for i := 0; i < len(arr.values); i++ {
 		var q string
 		if processingConditionBuilder(arr.values[i], &q, &arr.qArgs) {
-			arr.qString += " " + q
+			items[i] = q
 			continue
 		}
 		arr.qArgs = append(arr.qArgs, arr.values[i])
-		arr.qString += " ?"
+		items[i] = "?"
 	}
-	arr.qString += " )"
+	arr.qString = fmt.Sprintf("(%s)", strings.Join(items, ", "))
 }
 
 func (arr *array) QString() string {
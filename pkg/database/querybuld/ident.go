@@ -0,0 +1,161 @@
+package qb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// identPattern safe part of a table or column name.
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// ValidIdent checks whether the name is a safe table or column name.
+// The name can contain several parts separated by a dot, the last part can be "*".
+func ValidIdent(name string) bool {
+	parts := strings.Split(name, ".")
+	for i := 0; i < len(parts); i++ {
+		if parts[i] == "*" && i == len(parts)-1 && i != 0 {
+			continue
+		}
+		if !identPattern.MatchString(parts[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckIdent returns an error if the name is not a safe table or column name.
+func CheckIdent(name string) error {
+	if !ValidIdent(name) {
+		return ErrInvalidIdent{Name: name}
+	}
+	return nil
+}
+
+// checkIdents returns the error of the first name that is not a safe table or column name.
+// It is used for the names that are inserted into the query as is.
+func checkIdents(names ...string) error {
+	for i := 0; i < len(names); i++ {
+		if err := CheckIdent(names[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteIdents quotes the names with the dialect.
+func quoteIdents(dialect Dialect, names []string) []string {
+	quoted := make([]string, len(names))
+	for i := 0; i < len(names); i++ {
+		quoted[i] = Ident(names[i]).Quote(dialect)
+	}
+	return quoted
+}
+
+// Ident name of a table or column that is validated and quoted with the dialect of the query.
+// It can be passed to the [QB.Select] and [QB.SelectFrom] methods instead of a raw string.
+// An invalid name is not added to the query, the query returns the [ErrInvalidIdent] error.
+type Ident string
+
+// Quote quotes the name with the dialect. The quotes inside the name are escaped,
+// the name is validated by the [QB] methods that accept [Ident].
+func (i Ident) Quote(dialect Dialect) string {
+	return dialect.QuoteIdent(string(i))
+}
+
+// parseIdent writes the quoted name if the value is [Ident].
+// If the name is invalid, the error is saved in the qb and an empty string is written.
+func parseIdent(value any, qb *QB, outString *string) bool {
+	ident, ok := value.(Ident)
+	if !ok {
+		return false
+	}
+	if qb.setErr(CheckIdent(string(ident))) {
+		*outString = ""
+		return true
+	}
+	*outString = ident.Quote(qb.dialect)
+	return true
+}
+
+// SortAllowlist list of columns by which the user is allowed to sort the result.
+// It is used when the sorting comes from the request, for example "?sort=-price,name".
+// Only the columns from the list get into the query, so the value cannot be used for sql injection.
+type SortAllowlist struct {
+	columns     map[string]string
+	defaultSort []string
+}
+
+// NewSortAllowlist creates a list of allowed columns. The name of the sort field matches the column name.
+// An invalid column name causes a panic.
+func NewSortAllowlist(columns ...string) *SortAllowlist {
+	s := &SortAllowlist{columns: map[string]string{}}
+	for i := 0; i < len(columns); i++ {
+		s.Alias(columns[i], columns[i])
+	}
+	return s
+}
+
+// Alias allows sorting by the column using a different field name.
+// For example, the "created" field can sort by the "users.created_at" column.
+func (s *SortAllowlist) Alias(field string, column string) *SortAllowlist {
+	if err := CheckIdent(column); err != nil {
+		panic(err)
+	}
+	s.columns[field] = column
+	return s
+}
+
+// Default sets the sorting that is used if the value is empty.
+// The values must be in the same format as for the [SortAllowlist.Parse] method.
+func (s *SortAllowlist) Default(sort ...string) *SortAllowlist {
+	s.defaultSort = sort
+	return s
+}
+
+// Parse converts the value from the user into the conditions of the [QB.OrderBy] method.
+// The fields are separated by a comma, the "-" prefix means descending order.
+// For example, "-price,name" becomes "price DESC", "name ASC".
+// If at least one field is not allowed, an [ErrSortNotAllowed] error is returned.
+func (s *SortAllowlist) Parse(value string) ([]string, error) {
+	fields := []string{}
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		fields = s.defaultSort
+	}
+	conditions := make([]string, len(fields))
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		desc := strings.HasPrefix(field, "-")
+		column, ok := s.columns[strings.TrimPrefix(field, "-")]
+		if !ok {
+			return nil, ErrSortNotAllowed{Field: field}
+		}
+		if desc {
+			conditions[i] = DESC(column)
+		} else {
+			conditions[i] = ASC(column)
+		}
+	}
+	return conditions, nil
+}
+
+type ErrInvalidIdent struct {
+	Name string
+}
+
+func (e ErrInvalidIdent) Error() string {
+	return fmt.Sprintf("invalid sql identifier %q", e.Name)
+}
+
+type ErrSortNotAllowed struct {
+	Field string
+}
+
+func (e ErrSortNotAllowed) Error() string {
+	return fmt.Sprintf("sorting by the %s field is not allowed", e.Field)
+}
@@ -1,10 +1,12 @@
 package qb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/uwine4850/foozy/pkg/database/dbutils"
 	"github.com/uwine4850/foozy/pkg/interfaces"
@@ -21,24 +23,9 @@ type Col struct {
 }
 
 // String the string value of a sql column in a table.
+// The [DefaultDialect] is used.
 func (c *Col) String() string {
-	var null string
-	if c.Null {
-		null = "NULL"
-	} else {
-		null = "NOT NULL"
-	}
-	res := fmt.Sprintf("%s %s %s", c.Name, c.Type.Value(), null)
-	if c.AI {
-		res += " AUTO_INCREMENT"
-	}
-	if c.Default != "" {
-		res += " " + c.Default
-	}
-	if c.PK {
-		res += " PRIMARY KEY"
-	}
-	return res
+	return DefaultDialect.Column(c)
 }
 
 // TableQB query build sql table.
@@ -46,20 +33,67 @@ func (c *Col) String() string {
 type TableQB struct {
 	parts       []string
 	queryString string
+	dialect     Dialect
+	err         error
 }
 
 func NewTableQB() *TableQB {
-	return &TableQB{}
+	return &TableQB{dialect: DefaultDialect}
+}
+
+// SetDialect sets the sql dialect that is used to create the query.
+func (tqb *TableQB) SetDialect(dialect Dialect) *TableQB {
+	tqb.dialect = dialect
+	return tqb
+}
+
+// Constraint table constraint, such as a composite primary key or a unique constraint.
+type Constraint string
+
+// PrimaryKey primary key constraint. Allows to create a composite primary key.
+func PrimaryKey(cols ...string) Constraint {
+	return Constraint(fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(cols, ", ")))
+}
+
+// Unique named unique constraint for one or more columns.
+func Unique(name string, cols ...string) Constraint {
+	return Constraint(fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", name, strings.Join(cols, ", ")))
+}
+
+// Check named check constraint. The condition is inserted into the query as is.
+func Check(name string, condition string) Constraint {
+	return Constraint(fmt.Sprintf("CONSTRAINT %s CHECK (%s)", name, condition))
+}
+
+// ForeignKey named foreign key constraint. The refCol is the referenced column with its table, for example "users(id)".
+// The onUpdate and onDelete actions are optional.
+func ForeignKey(name string, fkCol string, refCol string, onUpdate string, onDelete string) Constraint {
+	constraint := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s", name, fkCol, refCol)
+	if onDelete != "" {
+		constraint += " ON DELETE " + onDelete
+	}
+	if onUpdate != "" {
+		constraint += " ON UPDATE " + onUpdate
+	}
+	return Constraint(constraint)
 }
 
 // Create creates a table.
-func (tqb *TableQB) Create(tableName string, cols []Col) *TableQB {
+// Table constraints are added after the columns.
+func (tqb *TableQB) Create(tableName string, cols []Col, constraints ...Constraint) *TableQB {
 	var colsString string
 	for i := 0; i < len(cols); i++ {
-		if len(cols)-1 == i {
-			colsString += cols[i].String() + " "
+		if len(cols)-1 == i && len(constraints) == 0 {
+			colsString += tqb.dialect.Column(&cols[i]) + " "
+		} else {
+			colsString += tqb.dialect.Column(&cols[i]) + ", "
+		}
+	}
+	for i := 0; i < len(constraints); i++ {
+		if len(constraints)-1 == i {
+			colsString += string(constraints[i]) + " "
 		} else {
-			colsString += cols[i].String() + ", "
+			colsString += string(constraints[i]) + ", "
 		}
 	}
 	qString := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", tableName, colsString)
@@ -73,9 +107,10 @@ func (tqb *TableQB) Drop(tables ...string) *TableQB {
 	return tqb
 }
 
-// Drop executes the TRUNCATE command for tables.
+// Truncate deletes all rows from tables.
+// The command depends on the dialect.
 func (tqb *TableQB) Truncate(tables ...string) *TableQB {
-	tqb.parts = append(tqb.parts, fmt.Sprintf("TRUNCATE TABLE IF EXISTS %s;", strings.Join(tables, ", ")))
+	tqb.parts = append(tqb.parts, tqb.dialect.Truncate(tables))
 	return tqb
 }
 
@@ -94,11 +129,88 @@ func (tqb *TableQB) FK(tableName string, constraintName string, fkCol string, re
 	return tqb
 }
 
+// CreateIndex creates an index on the table columns.
+// If unique is true, a unique index is created.
+func (tqb *TableQB) CreateIndex(tableName string, indexName string, unique bool, cols ...string) *TableQB {
+	var _unique string
+	if unique {
+		_unique = "UNIQUE "
+	}
+	q := fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);", _unique, indexName, tableName, strings.Join(cols, ", "))
+	tqb.parts = append(tqb.parts, q)
+	return tqb
+}
+
+// AddColumn adds a new column to the table.
+func (tqb *TableQB) AddColumn(tableName string, col Col) *TableQB {
+	tqb.parts = append(tqb.parts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", tableName, tqb.dialect.Column(&col)))
+	return tqb
+}
+
+// DropColumn removes a column from the table.
+func (tqb *TableQB) DropColumn(tableName string, colName string) *TableQB {
+	tqb.parts = append(tqb.parts, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, colName))
+	return tqb
+}
+
+// ModifyColumn changes the definition of an existing column.
+// The query depends on the dialect. Sqlite does not support this operation,
+// in this case the part is not added and the [ErrNotSupported] error is returned by the Err method.
+func (tqb *TableQB) ModifyColumn(tableName string, col Col) *TableQB {
+	q, err := tqb.dialect.ModifyColumn(tableName, &col)
+	if err != nil {
+		if tqb.err == nil {
+			tqb.err = err
+		}
+		return tqb
+	}
+	tqb.parts = append(tqb.parts, q)
+	return tqb
+}
+
+// RenameColumn renames the table column.
+func (tqb *TableQB) RenameColumn(tableName string, oldName string, newName string) *TableQB {
+	tqb.parts = append(tqb.parts, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", tableName, oldName, newName))
+	return tqb
+}
+
+// RenameTable renames the table.
+func (tqb *TableQB) RenameTable(oldName string, newName string) *TableQB {
+	tqb.parts = append(tqb.parts, fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", oldName, newName))
+	return tqb
+}
+
+// DropIndex removes the index.
+func (tqb *TableQB) DropIndex(tableName string, indexName string) *TableQB {
+	tqb.parts = append(tqb.parts, tqb.dialect.DropIndex(tableName, indexName))
+	return tqb
+}
+
+// AddConstraint adds a constraint to an existing table.
+// Sqlite does not support this operation.
+func (tqb *TableQB) AddConstraint(tableName string, constraint Constraint) *TableQB {
+	tqb.parts = append(tqb.parts, fmt.Sprintf("ALTER TABLE %s ADD %s;", tableName, constraint))
+	return tqb
+}
+
+// DropConstraint removes the named constraint from the table.
+// Sqlite does not support this operation.
+func (tqb *TableQB) DropConstraint(tableName string, constraintName string) *TableQB {
+	tqb.parts = append(tqb.parts, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", tableName, constraintName))
+	return tqb
+}
+
 // String value of sql command to create table.
 func (tqb *TableQB) String() string {
 	return tqb.queryString
 }
 
+// Err returns the first error that occurred while building the query, for example [ErrNotSupported].
+// The query must not be executed if there is an error.
+func (tqb *TableQB) Err() error {
+	return tqb.err
+}
+
 func (tqb *TableQB) Build() *TableQB {
 	tqb.queryString = strings.Join(tqb.parts, " ")
 	return tqb
@@ -117,10 +229,15 @@ type QB struct {
 	syncQ       interfaces.SyncQ
 	asyncQ      interfaces.AsyncQ
 	asyncKey    string
+	dialect     Dialect
+	cacheTTL    time.Duration
+	err         error
 }
 
 func NewNoDbQB() *QB {
-	qb := &QB{}
+	qb := &QB{
+		dialect: DefaultDialect,
+	}
 	qb.dataOperationQB.SetQB(qb)
 	qb.filterQB.SetQB(qb)
 	qb.joinQB.SetQB(qb)
@@ -130,7 +247,8 @@ func NewNoDbQB() *QB {
 
 func NewSyncQB(syncQ interfaces.SyncQ) *QB {
 	qb := &QB{
-		syncQ: syncQ,
+		syncQ:   syncQ,
+		dialect: DefaultDialect,
 	}
 	qb.dataOperationQB.SetQB(qb)
 	qb.filterQB.SetQB(qb)
@@ -143,6 +261,7 @@ func NewAsyncQB(asyncQ interfaces.AsyncQ, key string) *QB {
 	qb := &QB{
 		asyncQ:   asyncQ,
 		asyncKey: key,
+		dialect:  DefaultDialect,
 	}
 	qb.dataOperationQB.SetQB(qb)
 	qb.filterQB.SetQB(qb)
@@ -151,6 +270,39 @@ func NewAsyncQB(asyncQ interfaces.AsyncQ, key string) *QB {
 	return qb
 }
 
+// SetDialect sets the sql dialect that is used to create the query.
+func (qb *QB) SetDialect(dialect Dialect) *QB {
+	qb.dialect = dialect
+	return qb
+}
+
+// Dialect returns the sql dialect of the query.
+func (qb *QB) Dialect() Dialect {
+	return qb.dialect
+}
+
+// Cache stores the result of the Query method in the cache for ttl.
+// The synchronous queries must implement the [interfaces.CacheQuery] interface and have the cache enabled,
+// otherwise the query is executed as usual.
+func (qb *QB) Cache(ttl time.Duration) *QB {
+	qb.cacheTTL = ttl
+	return qb
+}
+
+// Err returns the error that occurred while building the query, for example [ErrInvalidIdent].
+// The same error is returned by the Query, QueryIter and Exec methods, the query is not executed.
+func (qb *QB) Err() error {
+	return qb.err
+}
+
+// setErr saves the error of building the query. Only the first error is saved.
+func (qb *QB) setErr(err error) bool {
+	if err != nil && qb.err == nil {
+		qb.err = err
+	}
+	return err != nil
+}
+
 // AppendPart adds a part of the sql query to the overall slice.
 func (qb *QB) AppendPart(part string) {
 	qb.queryParts = append(qb.queryParts, part)
@@ -168,8 +320,9 @@ func (qb *QB) Merge() {
 }
 
 // String outputs sql string created with Build function.
+// The placeholders are converted according to the dialect.
 func (qb *QB) String() string {
-	return qb.queryString
+	return Rebind(qb.dialect, qb.queryString)
 }
 
 // Args outputs positional sql arguments.
@@ -180,8 +333,14 @@ func (qb *QB) Args() []any {
 // Query executes a query against the database.
 // Returns data if necessary, e.g. SELECT command.
 func (qb *QB) Query() ([]map[string]interface{}, error) {
+	if qb.err != nil {
+		return nil, qb.err
+	}
 	qb.Merge()
 	if qb.syncQ != nil {
+		if cacheQuery, ok := qb.syncQ.(interfaces.CacheQuery); ok && qb.cacheTTL > 0 {
+			return cacheQuery.QueryCache(qb.cacheTTL, qb.String(), qb.Args()...)
+		}
 		return qb.syncQ.Query(qb.String(), qb.Args()...)
 	}
 	if qb.asyncQ != nil {
@@ -191,11 +350,33 @@ func (qb *QB) Query() ([]map[string]interface{}, error) {
 	return nil, errors.New("no synchronous or asynchronous QB handler found")
 }
 
+// QueryIter executes a query and returns a cursor that reads the rows one by one.
+// Works only with a synchronous QB that implements the [interfaces.IterQuery] interface.
+// The rows are closed when the context is canceled.
+// IMPORTANT: the cursor must be closed after use.
+func (qb *QB) QueryIter(ctx context.Context) (*dbutils.Cursor, error) {
+	if qb.err != nil {
+		return nil, qb.err
+	}
+	qb.Merge()
+	if qb.syncQ == nil {
+		return nil, errors.New("QueryIter is only available for synchronous QB")
+	}
+	iterQuery, ok := qb.syncQ.(interfaces.IterQuery)
+	if !ok {
+		return nil, errors.New("the synchronous queries do not implement the interfaces.IterQuery interface")
+	}
+	return iterQuery.QueryIter(ctx, qb.String(), qb.Args()...)
+}
+
 // Exec executes a query against a database and returns the result of the query.
 // Returns the following data:
 // Key "insertID" is the identifier of the inserted row using INSERT.
 // Key "rowsAffected" - Returns the number of rows affected by INSERT, UPDATE, DELETE.
 func (qb *QB) Exec() (map[string]interface{}, error) {
+	if qb.err != nil {
+		return nil, qb.err
+	}
 	qb.Merge()
 	if qb.syncQ != nil {
 		return qb.syncQ.Exec(qb.String(), qb.Args()...)
@@ -209,13 +390,16 @@ func (qb *QB) Exec() (map[string]interface{}, error) {
 
 // mergeTwoQB merges two QB instances into one.
 func mergeTwoQB(qb1 *QB, qb2 *QB) *QB {
-	var newQB *QB
+	newQB := NewNoDbQB()
 	if qb1.syncQ != nil {
 		newQB = NewSyncQB(qb1.syncQ)
 	}
 	if qb1.asyncQ != nil {
 		newQB = NewAsyncQB(qb1.asyncQ, qb1.asyncKey)
 	}
+	newQB.SetDialect(qb1.dialect)
+	newQB.setErr(qb1.err)
+	newQB.setErr(qb2.err)
 	qb1.Merge()
 	qb2.Merge()
 	return newQB
@@ -224,10 +408,10 @@ func mergeTwoQB(qb1 *QB, qb2 *QB) *QB {
 // Union adds a UNION command for two sql queries passed using QB.
 func Union(qb1 *QB, qb2 *QB) *QB {
 	newQB := mergeTwoQB(qb1, qb2)
-	newQB.AppendPart(fmt.Sprintf("(%s)", qb1.String()))
+	newQB.AppendPart(fmt.Sprintf("(%s)", qb1.queryString))
 	newQB.AppendArgs(qb1.Args())
 	newQB.AppendPart("UNION")
-	newQB.AppendPart(fmt.Sprintf("(%s)", qb2.String()))
+	newQB.AppendPart(fmt.Sprintf("(%s)", qb2.queryString))
 	newQB.AppendArgs(qb2.Args())
 	return newQB
 }
@@ -235,10 +419,10 @@ func Union(qb1 *QB, qb2 *QB) *QB {
 // UnionAll adds a UNION ALL command for two sql queries passed using QB.
 func UnionAll(qb1 *QB, qb2 *QB) *QB {
 	newQB := mergeTwoQB(qb1, qb2)
-	newQB.AppendPart(fmt.Sprintf("(%s)", qb1.String()))
+	newQB.AppendPart(fmt.Sprintf("(%s)", qb1.queryString))
 	newQB.AppendArgs(qb1.Args())
 	newQB.AppendPart("UNION ALL")
-	newQB.AppendPart(fmt.Sprintf("(%s)", qb2.String()))
+	newQB.AppendPart(fmt.Sprintf("(%s)", qb2.queryString))
 	newQB.AppendArgs(qb2.Args())
 	return newQB
 }
@@ -246,10 +430,10 @@ func UnionAll(qb1 *QB, qb2 *QB) *QB {
 // Intersect adds a INTERSECT command for two sql queries passed using QB.
 func Intersect(qb1 *QB, qb2 *QB) *QB {
 	newQB := mergeTwoQB(qb1, qb2)
-	newQB.AppendPart(fmt.Sprintf("(%s)", qb1.String()))
+	newQB.AppendPart(fmt.Sprintf("(%s)", qb1.queryString))
 	newQB.AppendArgs(qb1.Args())
 	newQB.AppendPart("INTERSECT")
-	newQB.AppendPart(fmt.Sprintf("(%s)", qb2.String()))
+	newQB.AppendPart(fmt.Sprintf("(%s)", qb2.queryString))
 	newQB.AppendArgs(qb2.Args())
 	return newQB
 }
@@ -257,10 +441,10 @@ func Intersect(qb1 *QB, qb2 *QB) *QB {
 // Except adds a EXCEPT command for two sql queries passed using QB.
 func Except(qb1 *QB, qb2 *QB) *QB {
 	newQB := mergeTwoQB(qb1, qb2)
-	newQB.AppendPart(fmt.Sprintf("(%s)", qb1.String()))
+	newQB.AppendPart(fmt.Sprintf("(%s)", qb1.queryString))
 	newQB.AppendArgs(qb1.Args())
 	newQB.AppendPart("EXCEPT")
-	newQB.AppendPart(fmt.Sprintf("(%s)", qb2.String()))
+	newQB.AppendPart(fmt.Sprintf("(%s)", qb2.queryString))
 	newQB.AppendArgs(qb2.Args())
 	return newQB
 }
@@ -269,51 +453,102 @@ func Except(qb1 *QB, qb2 *QB) *QB {
 // Metgod name corresponds to the corresponding sql operation.
 type dataOperationQB struct {
 	qb *QB
+	// withPart is the number of query parts after the last WITH clause was added.
+	// Used to join consecutive common table expressions into one clause.
+	withPart int
 }
 
 func (doQB *dataOperationQB) SetQB(qb *QB) {
 	doQB.qb = qb
 }
 
+// Custom adds a raw part of the sql query.
+// IMPORTANT: the value is inserted as is, so it must not contain user input.
+// User values must be passed only as positional arguments.
 func (doQB *dataOperationQB) Custom(value string, args ...any) *QB {
 	doQB.qb.AppendPart(value)
 	doQB.qb.AppendArgs(args)
 	return doQB.qb
 }
 
+// Select adds the SELECT command.
+// The values can be raw strings, [Ident], [subquery] or [IConditionBuilder] instances.
+// Raw strings are inserted as is, so user input must be passed as [Ident] or positional arguments.
 func (doQB *dataOperationQB) Select(values ...any) *QB {
+	doQB.qb.setErr(subqueryErr(values...))
 	var qString string
 	qArgs := []any{}
 	for i := 0; i < len(values); i++ {
-		if !ParseSubQuery(values[i], &qString, &qArgs) && !processingConditionBuilder(values[i], &qString, &qArgs) {
+		var valueString string
+		if !parseIdent(values[i], doQB.qb, &valueString) &&
+			!ParseSubQuery(values[i], &valueString, &qArgs) && !processingConditionBuilder(values[i], &valueString, &qArgs) {
 			if reflect.TypeOf(values[i]).Kind() == reflect.String {
-				qString += " " + strings.Trim(values[i].(string), " ")
+				valueString = values[i].(string)
 			} else {
 				panic(fmt.Sprintf("%s data type is not supported", reflect.TypeOf(values[i])))
 			}
 		}
+		qString += " " + strings.Trim(valueString, " ")
 	}
 	doQB.qb.AppendPart(fmt.Sprintf("SELECT %s", strings.Trim(qString, " ")))
 	doQB.qb.AppendArgs(qArgs)
 	return doQB.qb
 }
 
+// With adds a common table expression that can be used in the following query by name.
+// The name can contain a list of columns, for example "tree(id, parent_id)".
+// Consecutive calls are combined into one WITH clause.
+func (doQB *dataOperationQB) With(name string, sq *subquery) *QB {
+	doQB.with(name, sq, false)
+	return doQB.qb
+}
+
+// WithRecursive adds a recursive common table expression.
+// The subquery usually consists of a base query and a recursive query joined with UNION ALL.
+// If it is combined with other expressions, the whole WITH clause becomes recursive.
+func (doQB *dataOperationQB) WithRecursive(name string, sq *subquery) *QB {
+	doQB.with(name, sq, true)
+	return doQB.qb
+}
+
+func (doQB *dataOperationQB) with(name string, sq *subquery, recursive bool) {
+	doQB.qb.setErr(subqueryErr(sq))
+	cte := fmt.Sprintf("%s AS (%s)", name, sq.qb.queryString)
+	last := len(doQB.qb.queryParts) - 1
+	if doQB.withPart != 0 && doQB.withPart == len(doQB.qb.queryParts) {
+		doQB.qb.queryParts[last] += ", " + cte
+		if recursive && !strings.HasPrefix(doQB.qb.queryParts[last], "WITH RECURSIVE ") {
+			doQB.qb.queryParts[last] = "WITH RECURSIVE " + strings.TrimPrefix(doQB.qb.queryParts[last], "WITH ")
+		}
+	} else if recursive {
+		doQB.qb.AppendPart("WITH RECURSIVE " + cte)
+	} else {
+		doQB.qb.AppendPart("WITH " + cte)
+	}
+	doQB.qb.AppendArgs(sq.Args())
+	doQB.withPart = len(doQB.qb.queryParts)
+}
+
 func (doQB *dataOperationQB) As(name string) *QB {
 	doQB.qb.AppendPart(fmt.Sprintf("AS %s", name))
 	return doQB.qb
 }
 
+// SelectFrom adds the SELECT ... FROM command.
+// The target and from values can be raw strings, [Ident] or [subquery] instances.
+// Raw strings are inserted as is, so user input must be passed as [Ident].
 func (doQB *dataOperationQB) SelectFrom(target any, from any) *QB {
+	doQB.qb.setErr(subqueryErr(target, from))
 	var targetValue any
 	var fromValue any
 	qArgs := []any{}
 	var sqQString string
-	if ok := ParseSubQuery(target, &sqQString, &qArgs); ok {
+	if parseIdent(target, doQB.qb, &sqQString) || ParseSubQuery(target, &sqQString, &qArgs) {
 		targetValue = sqQString
 	} else {
 		targetValue = target
 	}
-	if ok := ParseSubQuery(from, &sqQString, &qArgs); ok {
+	if parseIdent(from, doQB.qb, &sqQString) || ParseSubQuery(from, &sqQString, &qArgs) {
 		fromValue = sqQString
 	} else {
 		fromValue = from
@@ -323,22 +558,99 @@ func (doQB *dataOperationQB) SelectFrom(target any, from any) *QB {
 	return doQB.qb
 }
 
+// Insert adds the INSERT command. The table name and the keys of the params
+// must be valid identifiers, otherwise the query returns the [ErrInvalidIdent] error.
+// The names are quoted with the dialect.
 func (doQB *dataOperationQB) Insert(tableName string, params map[string]any) *QB {
 	keys, values := dbutils.ParseParams(params)
-	doQB.qb.AppendPart(fmt.Sprintf("INSERT INTO %s ( %s ) VALUES ( %s )", tableName, strings.Join(keys, ", "), dbutils.RepeatValues(len(values), ",")))
+	if doQB.qb.setErr(checkIdents(append([]string{tableName}, keys...)...)) {
+		return doQB.qb
+	}
+	dialect := doQB.qb.dialect
+	doQB.qb.AppendPart(fmt.Sprintf("INSERT INTO %s ( %s ) VALUES ( %s )", Ident(tableName).Quote(dialect),
+		strings.Join(quoteIdents(dialect, keys), ", "), dbutils.RepeatValues(len(values), ",")))
 	doQB.qb.AppendArgs(values)
 	return doQB.qb
 }
 
+// InsertMany inserts several rows with one query.
+// Each row must contain values for all columns in the same order as columns.
+// The number of placeholders in the query is limited by the dialect, so for a large number
+// of rows it is better to use the [BulkInsert] function, which splits the rows into chunks.
+// If there are no columns or rows, the query returns the [ErrEmptyInsert] error, if the length
+// of a row does not match the columns, the [ErrRowLength] error.
+func (doQB *dataOperationQB) InsertMany(tableName string, columns []string, rows [][]any) *QB {
+	if len(columns) == 0 || len(rows) == 0 {
+		doQB.qb.setErr(ErrEmptyInsert{Table: tableName})
+		return doQB.qb
+	}
+	if doQB.qb.setErr(checkIdents(append([]string{tableName}, columns...)...)) {
+		return doQB.qb
+	}
+	values := make([]string, len(rows))
+	args := make([]any, 0, len(rows)*len(columns))
+	rowValues := fmt.Sprintf("( %s )", dbutils.RepeatValues(len(columns), ","))
+	for i := 0; i < len(rows); i++ {
+		if len(rows[i]) != len(columns) {
+			doQB.qb.setErr(ErrRowLength{Row: i, Expected: len(columns), Got: len(rows[i])})
+			return doQB.qb
+		}
+		values[i] = rowValues
+		args = append(args, rows[i]...)
+	}
+	doQB.qb.AppendPart(fmt.Sprintf("INSERT INTO %s ( %s ) VALUES %s", Ident(tableName).Quote(doQB.qb.dialect),
+		strings.Join(quoteIdents(doQB.qb.dialect, columns), ", "), strings.Join(values, ", ")))
+	doQB.qb.AppendArgs(args)
+	return doQB.qb
+}
+
+// Upsert is added after the INSERT query. If a row with the same conflictColumns already exists,
+// the updateColumns are updated with the new values.
+// The syntax depends on the dialect, for example ON DUPLICATE KEY UPDATE for MySQL
+// or ON CONFLICT DO UPDATE for PostgreSQL and sqlite. MySQL does not use conflictColumns,
+// for other dialects empty conflictColumns make the query return the [ErrNoConflictColumns] error.
+// If updateColumns are empty, the existing row is left unchanged, for example ON CONFLICT DO NOTHING is used.
+// IMPORTANT: the dialect must be set before calling this method.
+func (doQB *dataOperationQB) Upsert(conflictColumns []string, updateColumns []string) *QB {
+	if doQB.qb.setErr(checkIdents(conflictColumns...)) || doQB.qb.setErr(checkIdents(updateColumns...)) {
+		return doQB.qb
+	}
+	dialect := doQB.qb.dialect
+	upsert, err := dialect.Upsert(quoteIdents(dialect, conflictColumns), quoteIdents(dialect, updateColumns))
+	if doQB.qb.setErr(err) {
+		return doQB.qb
+	}
+	doQB.qb.AppendPart(upsert)
+	return doQB.qb
+}
+
+// Update adds the UPDATE command. The table name and the keys of the params
+// must be valid identifiers, otherwise the query returns the [ErrInvalidIdent] error.
+// The names are quoted with the dialect.
 func (doQB *dataOperationQB) Update(tableName string, params map[string]any) *QB {
-	strVal, args := dbutils.ParseMapAsEquals(&params)
-	doQB.qb.AppendPart(fmt.Sprintf("UPDATE %s SET %s", tableName, strVal))
+	keys := dbutils.SortedKeys(params)
+	if doQB.qb.setErr(checkIdents(append([]string{tableName}, keys...)...)) {
+		return doQB.qb
+	}
+	dialect := doQB.qb.dialect
+	sets := make([]string, len(keys))
+	args := make([]any, len(keys))
+	for i := 0; i < len(keys); i++ {
+		sets[i] = Ident(keys[i]).Quote(dialect) + " = ?"
+		args[i] = params[keys[i]]
+	}
+	doQB.qb.AppendPart(fmt.Sprintf("UPDATE %s SET %s", Ident(tableName).Quote(dialect), strings.Join(sets, ", ")))
 	doQB.qb.AppendArgs(args)
 	return doQB.qb
 }
 
+// Delete adds the DELETE command. The table name must be a valid identifier,
+// otherwise the query returns the [ErrInvalidIdent] error. The name is quoted with the dialect.
 func (doQB *dataOperationQB) Delete(tableName string) *QB {
-	doQB.qb.AppendPart(fmt.Sprintf("DELETE FROM %s", tableName))
+	if doQB.qb.setErr(CheckIdent(tableName)) {
+		return doQB.qb
+	}
+	doQB.qb.AppendPart(fmt.Sprintf("DELETE FROM %s", Ident(tableName).Quote(doQB.qb.dialect)))
 	return doQB.qb
 }
 
@@ -356,6 +668,7 @@ func (jqb *joinQB) InnerJoin(tableName string, values ...any) *QB {
 	var qString string
 	qArgs := []any{}
 	if len(values) != 0 {
+		jqb.qb.setErr(subqueryErr(values...))
 		processingConditionValues(values, &qString, &qArgs)
 		jqb.qb.AppendPart(fmt.Sprintf("INNER JOIN %s ON %s", tableName, qString))
 	} else {
@@ -369,6 +682,7 @@ func (jqb *joinQB) LeftJoin(tableName string, values ...any) *QB {
 	var qString string
 	qArgs := []any{}
 	if len(values) != 0 {
+		jqb.qb.setErr(subqueryErr(values...))
 		processingConditionValues(values, &qString, &qArgs)
 		jqb.qb.AppendPart(fmt.Sprintf("LEFT JOIN %s ON %s", tableName, qString))
 	} else {
@@ -382,6 +696,7 @@ func (jqb *joinQB) RightJoin(tableName string, values ...any) *QB {
 	var qString string
 	qArgs := []any{}
 	if len(values) != 0 {
+		jqb.qb.setErr(subqueryErr(values...))
 		processingConditionValues(values, &qString, &qArgs)
 		jqb.qb.AppendPart(fmt.Sprintf("RIGHT JOIN %s ON %s", tableName, qString))
 	} else {
@@ -403,6 +718,7 @@ func (cf *customFunc) SetQB(qb *QB) {
 
 // Func runs a [subquery] in the selected [QB] query fragment.
 func (cf *customFunc) Func(_subquery *subquery) *QB {
+	cf.qb.setErr(subqueryErr(_subquery))
 	var qString string
 	qArgs := []any{}
 	if ParseSubQuery(_subquery, &qString, &qArgs) {
@@ -423,6 +739,8 @@ type subquery struct {
 	bracket bool
 }
 
+// SQ creates a subquery from the query. If building the query failed, the error is passed
+// to the main query, which returns it when the query is executed.
 func SQ(bracket bool, qb *QB) *subquery {
 	qb.Merge()
 	return &subquery{
@@ -431,11 +749,13 @@ func SQ(bracket bool, qb *QB) *subquery {
 	}
 }
 
+// String returns the sql string of the subquery.
+// The placeholders are not converted, this is done by the main query.
 func (sq *subquery) String() string {
 	if sq.bracket {
-		return fmt.Sprintf("(%s)", sq.qb.String())
+		return fmt.Sprintf("(%s)", sq.qb.queryString)
 	} else {
-		return fmt.Sprintf("%s", sq.qb.String())
+		return sq.qb.queryString
 	}
 }
 
@@ -451,6 +771,36 @@ func IsSubQuery(val any) bool {
 	return false
 }
 
+// subqueryErr returns the first error of building the subqueries among the values,
+// including the subqueries used as operands of the conditions.
+func subqueryErr(values ...any) error {
+	for i := 0; i < len(values); i++ {
+		var err error
+		switch value := values[i].(type) {
+		case *subquery:
+			if value != nil {
+				err = value.qb.err
+			}
+		case *compare:
+			err = subqueryErr(value.leftOperand, value.rightOperand)
+		case *noArgsCompare:
+			err = subqueryErr(value.leftOperand, value.rightOperand)
+		case *between:
+			err = subqueryErr(value.leftOperand, value.rightOperand)
+		case *notBetween:
+			err = subqueryErr(value.leftOperand, value.rightOperand)
+		case *array:
+			err = subqueryErr(value.values...)
+		case *exists:
+			err = subqueryErr(value.sq)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ParseSubQuery processes an instance of subQuery.
 // outQueryString — sql string of the subquery.
 // outQueryArgs — position arguments of sql subquery.
@@ -463,3 +813,21 @@ func ParseSubQuery(sq any, outQueryString *string, outQueryArgs *[]any) bool {
 	}
 	return false
 }
+
+type ErrEmptyInsert struct {
+	Table string
+}
+
+func (e ErrEmptyInsert) Error() string {
+	return fmt.Sprintf("no columns or rows to insert into the %s table", e.Table)
+}
+
+type ErrRowLength struct {
+	Row      int
+	Expected int
+	Got      int
+}
+
+func (e ErrRowLength) Error() string {
+	return fmt.Sprintf("row %d has %d values, expected %d", e.Row, e.Got, e.Expected)
+}
@@ -1,7 +1,5 @@
 package qb
 
-import "fmt"
-
 // Comparison operator. It is used to compare values in the Compare structure.
 type CompareOperator string
 
@@ -53,37 +51,122 @@ const (
 	FALSE SpecialType = "False"
 )
 
+const (
+	typeText      = "TEXT"
+	typeInt       = "INT"
+	typeBigInt    = "BIGINT"
+	typeVarchar   = "VARCHAR"
+	typeDecimal   = "DECIMAL"
+	typeDate      = "DATE"
+	typeDateTime  = "DATETIME"
+	typeTimestamp = "TIMESTAMP"
+	typeBoolean   = "BOOLEAN"
+	typeJSON      = "JSON"
+	typeBlob      = "BLOB"
+	typeEnum      = "ENUM"
+	typeUUID      = "UUID"
+)
+
 // Sql standard field data type.
+// The type stores only its kind and size, the final name of the type
+// is created by the [Dialect].
 type T struct {
-	value string
+	kind   string
+	size   []int
+	values []string
 }
 
 // Value string value of the selected data type.
+// The [DefaultDialect] is used.
 func (t T) Value() string {
-	return t.value
+	return DefaultDialect.TypeName(t)
+}
+
+// Kind returns the kind of data type, for example "VARCHAR".
+func (t T) Kind() string {
+	return t.kind
+}
+
+// Size returns the size parameters of the data type.
+func (t T) Size() []int {
+	return t.size
+}
+
+// Values returns the allowed values of the ENUM data type.
+func (t T) Values() []string {
+	return t.values
 }
 
 func (t T) Text() T {
-	t.value = "TEXT"
+	t.kind = typeText
 	return t
 }
 
 func (t T) Int(lenght int) T {
-	t.value = fmt.Sprintf("INT(%v)", lenght)
+	t.kind = typeInt
+	t.size = []int{lenght}
 	return t
 }
 
 func (t T) Varchar(lenght int) T {
-	t.value = fmt.Sprintf("VARCHAR(%v)", lenght)
+	t.kind = typeVarchar
+	t.size = []int{lenght}
 	return t
 }
 
 func (t T) Date() T {
-	t.value = "DATE"
+	t.kind = typeDate
 	return t
 }
 
 func (t T) Boolean() T {
-	t.value = "BOOLEAN"
+	t.kind = typeBoolean
+	return t
+}
+
+func (t T) BigInt() T {
+	t.kind = typeBigInt
+	return t
+}
+
+// Decimal exact number with the given precision and scale.
+func (t T) Decimal(precision int, scale int) T {
+	t.kind = typeDecimal
+	t.size = []int{precision, scale}
+	return t
+}
+
+func (t T) DateTime() T {
+	t.kind = typeDateTime
+	return t
+}
+
+func (t T) Timestamp() T {
+	t.kind = typeTimestamp
+	return t
+}
+
+func (t T) JSON() T {
+	t.kind = typeJSON
+	return t
+}
+
+func (t T) Blob() T {
+	t.kind = typeBlob
+	return t
+}
+
+// Enum a string that can only have one of the given values.
+// Databases without the ENUM type use a string type.
+func (t T) Enum(values ...string) T {
+	t.kind = typeEnum
+	t.values = values
+	return t
+}
+
+// UUID universally unique identifier.
+// Databases without the UUID type use a string type.
+func (t T) UUID() T {
+	t.kind = typeUUID
 	return t
 }
@@ -21,8 +21,14 @@ func NullsLast(fieldName string) string {
 	return fmt.Sprintf("%s NULLS LAST", fieldName)
 }
 
-func Rand() string {
-	return "RAND()"
+// Rand returns the function for generating a random number of the dialect,
+// for example RAND() for MySQL and RANDOM() for PostgreSQL and SQLite.
+// If the dialect is not passed, [DefaultDialect] is used.
+func Rand(dialect ...Dialect) string {
+	if len(dialect) != 0 && dialect[0] != nil {
+		return dialect[0].Rand()
+	}
+	return DefaultDialect.Rand()
 }
 
 func Now() string {
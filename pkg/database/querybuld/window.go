@@ -0,0 +1,122 @@
+package qb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WindowPart part of the OVER clause of the window function.
+type WindowPart string
+
+// PartitionBy divides the rows into groups to which the window function is applied.
+func PartitionBy(cols ...string) WindowPart {
+	return WindowPart(fmt.Sprintf("PARTITION BY %s", strings.Join(cols, ", ")))
+}
+
+// OrderBy sets the order of the rows inside the window.
+// Values can be formed with the [ASC] and [DESC] functions.
+func OrderBy(cols ...string) WindowPart {
+	return WindowPart(fmt.Sprintf("ORDER BY %s", strings.Join(cols, ", ")))
+}
+
+// Frame sets the window frame, for example "ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW".
+func Frame(frame string) WindowPart {
+	return WindowPart(frame)
+}
+
+// windowFunc sql window function.
+// It implements [IConditionBuilder], so it can be passed to the [QB.Select] method.
+// The values of the function that are not column names are passed as positional arguments.
+type windowFunc struct {
+	name    string
+	exprs   []string
+	values  []any
+	over    []WindowPart
+	hasOver bool
+	alias   string
+	qString string
+	qArgs   []any
+}
+
+func newWindowFunc(name string, exprs []string, values ...any) *windowFunc {
+	return &windowFunc{
+		name:   name,
+		exprs:  exprs,
+		values: values,
+	}
+}
+
+// RowNumber sequential number of the row inside the window.
+func RowNumber() *windowFunc {
+	return newWindowFunc("ROW_NUMBER", nil)
+}
+
+// Rank rank of the row inside the window with gaps.
+func Rank() *windowFunc {
+	return newWindowFunc("RANK", nil)
+}
+
+// DenseRank rank of the row inside the window without gaps.
+func DenseRank() *windowFunc {
+	return newWindowFunc("DENSE_RANK", nil)
+}
+
+// Lag value of the expression from the row that is offset rows before the current one.
+// If defaultValue is passed, it is used when there is no such row.
+func Lag(expr string, offset int, defaultValue ...any) *windowFunc {
+	return newWindowFunc("LAG", []string{expr, fmt.Sprint(offset)}, defaultValue...)
+}
+
+// Lead value of the expression from the row that is offset rows after the current one.
+// If defaultValue is passed, it is used when there is no such row.
+func Lead(expr string, offset int, defaultValue ...any) *windowFunc {
+	return newWindowFunc("LEAD", []string{expr, fmt.Sprint(offset)}, defaultValue...)
+}
+
+// Sum sum of the expression values.
+// Without the [windowFunc.Over] method it works as a normal aggregate function.
+func Sum(expr string) *windowFunc {
+	return newWindowFunc("SUM", []string{expr})
+}
+
+// Over turns the function into a window function.
+// Without parts the function is applied to all rows of the result.
+func (w *windowFunc) Over(parts ...WindowPart) *windowFunc {
+	w.over = parts
+	w.hasOver = true
+	return w
+}
+
+// As sets the name of the result column.
+func (w *windowFunc) As(alias string) *windowFunc {
+	w.alias = alias
+	return w
+}
+
+func (w *windowFunc) Build() {
+	w.qArgs = []any{}
+	params := append([]string{}, w.exprs...)
+	for i := 0; i < len(w.values); i++ {
+		params = append(params, "?")
+		w.qArgs = append(w.qArgs, w.values[i])
+	}
+	w.qString = fmt.Sprintf("%s(%s)", w.name, strings.Join(params, ", "))
+	if w.hasOver {
+		over := make([]string, len(w.over))
+		for i := 0; i < len(w.over); i++ {
+			over[i] = string(w.over[i])
+		}
+		w.qString += fmt.Sprintf(" OVER (%s)", strings.Join(over, " "))
+	}
+	if w.alias != "" {
+		w.qString += fmt.Sprintf(" AS %s", w.alias)
+	}
+}
+
+func (w *windowFunc) QString() string {
+	return w.qString
+}
+
+func (w *windowFunc) QArgs() []any {
+	return w.qArgs
+}
@@ -0,0 +1,288 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+	"github.com/uwine4850/foozy/pkg/mapper"
+	"github.com/uwine4850/foozy/pkg/namelib"
+)
+
+const (
+	BELONGS_TO   = "belongs_to"
+	HAS_MANY     = "has_many"
+	MANY_TO_MANY = "many_to_many"
+)
+
+// preloadKey the name of the column with the key of the parent row in the many-to-many query.
+const preloadKey = "foozy_preload_key"
+
+// relation description of the relation field that is read from the dbrel tag.
+type relation struct {
+	kind  string
+	field reflect.StructField
+	// related type of the related structure.
+	related reflect.Type
+	// fk for belongs-to it is the column of the parent structure, for has-many it is the column of the related table.
+	// For many-to-many it is the column of the join table that refers to the parent row.
+	fk string
+	// joinTable and ref are used only for many-to-many.
+	joinTable string
+	ref       string
+}
+
+// parseRelation reads the relation of the field.
+// Tag formats:
+//   - dbrel:"belongs_to:<column of the parent structure>" — the field is Related or *Related.
+//   - dbrel:"has_many:<column of the related table>" — the field is []Related or []*Related.
+//   - dbrel:"many_to_many:<join table>,<column referring to the parent>,<column referring to the related>" — the field is []Related or []*Related.
+func parseRelation(typ reflect.Type, name string) (*relation, error) {
+	field, ok := typ.FieldByName(name)
+	if !ok {
+		return nil, ErrRelationNotFound{Type: typ.String(), Relation: name}
+	}
+	tag := field.Tag.Get(namelib.TAGS.DB_RELATION)
+	kind, params, _ := strings.Cut(tag, ":")
+	invalid := ErrInvalidRelation{Type: typ.String(), Relation: name, Tag: tag}
+	rel := &relation{kind: kind, field: field, related: field.Type}
+	var args []string
+	if params != "" {
+		args = strings.Split(params, ",")
+	}
+	switch kind {
+	case BELONGS_TO:
+		if len(args) != 1 {
+			return nil, invalid
+		}
+		rel.fk = args[0]
+	case HAS_MANY:
+		if len(args) != 1 || rel.related.Kind() != reflect.Slice {
+			return nil, invalid
+		}
+		rel.fk = args[0]
+		rel.related = rel.related.Elem()
+	case MANY_TO_MANY:
+		if len(args) != 3 || rel.related.Kind() != reflect.Slice {
+			return nil, invalid
+		}
+		rel.joinTable, rel.fk, rel.ref = args[0], args[1], args[2]
+		rel.related = rel.related.Elem()
+		if err := qb.CheckIdent(rel.joinTable); err != nil {
+			return nil, err
+		}
+		if err := qb.CheckIdent(rel.ref); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, invalid
+	}
+	if err := qb.CheckIdent(rel.fk); err != nil {
+		return nil, err
+	}
+	if rel.related.Kind() == reflect.Pointer {
+		rel.related = rel.related.Elem()
+	}
+	return rel, nil
+}
+
+// Preload loads the relations of the items and writes them into the relation fields.
+// One query with the IN (...) condition is executed for each relation, regardless of the number of items.
+// The names of the relations are the names of the fields with the dbrel tag.
+// The related structures must have the dbtable tag, as for [Repository]. Soft deleted related rows are skipped.
+func Preload[T any](db interfaces.SyncAsyncQuery, dialect qb.Dialect, items []T, relations ...string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	meta, err := loadTableMeta(typ)
+	if err != nil {
+		return err
+	}
+	values := reflect.ValueOf(items)
+	for i := 0; i < len(relations); i++ {
+		rel, err := parseRelation(typ, relations[i])
+		if err != nil {
+			return err
+		}
+		relatedMeta, err := loadTableMeta(rel.related)
+		if err != nil {
+			return err
+		}
+		switch rel.kind {
+		case BELONGS_TO:
+			err = preloadBelongsTo(db, dialect, values, meta, rel, relatedMeta)
+		case HAS_MANY:
+			err = preloadMany(db, dialect, values, meta, rel, relatedMeta)
+		case MANY_TO_MANY:
+			err = preloadMany(db, dialect, values, meta, rel, relatedMeta)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preloadBelongsTo loads the related rows by the column of the parent structure.
+func preloadBelongsTo(db interfaces.SyncAsyncQuery, dialect qb.Dialect, items reflect.Value, meta *tableMeta, rel *relation, relatedMeta *tableMeta) error {
+	fkIndex, ok := meta.columns[rel.fk]
+	if !ok {
+		return ErrUnknownColumn{Table: meta.table, Column: rel.fk}
+	}
+	keys := make([]any, items.Len())
+	for i := 0; i < items.Len(); i++ {
+		keys[i] = relationValue(items.Index(i).Field(fkIndex).Interface())
+	}
+	q := func(keys []any) *qb.QB {
+		return qb.NewSyncQB(db.SyncQ()).SetDialect(dialect).SelectFrom("*", relatedMeta.table).
+			Where(relatedConditions(relatedMeta, "", qb.Compare(relatedMeta.pk, qb.IN, qb.Array(keys...)))...)
+	}
+	related, err := queryRelated(dialect, keys, q, rel.related, relatedMeta.pk)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < items.Len(); i++ {
+		if keys[i] == nil {
+			continue
+		}
+		values := related[relationKey(keys[i])]
+		if len(values) == 0 {
+			continue
+		}
+		field := items.Index(i).FieldByIndex(rel.field.Index)
+		if field.Kind() == reflect.Pointer {
+			field.Set(values[0].Addr())
+		} else {
+			field.Set(values[0])
+		}
+	}
+	return nil
+}
+
+// preloadMany loads the related rows of the has-many and many-to-many relations by the primary key of the parent structure.
+func preloadMany(db interfaces.SyncAsyncQuery, dialect qb.Dialect, items reflect.Value, meta *tableMeta, rel *relation, relatedMeta *tableMeta) error {
+	keys := make([]any, items.Len())
+	for i := 0; i < items.Len(); i++ {
+		keys[i] = relationValue(items.Index(i).Field(meta.pkIndex).Interface())
+	}
+	var q func(keys []any) *qb.QB
+	keyColumn := rel.fk
+	if rel.kind == MANY_TO_MANY {
+		keyColumn = preloadKey
+		q = func(keys []any) *qb.QB {
+			return qb.NewSyncQB(db.SyncQ()).SetDialect(dialect).
+				SelectFrom(fmt.Sprintf("%s.*, %s.%s AS %s", relatedMeta.table, rel.joinTable, rel.fk, preloadKey), relatedMeta.table).
+				InnerJoin(rel.joinTable, qb.NoArgsCompare(rel.joinTable+"."+rel.ref, qb.EQUAL, relatedMeta.table+"."+relatedMeta.pk)).
+				Where(relatedConditions(relatedMeta, relatedMeta.table+".", qb.Compare(rel.joinTable+"."+rel.fk, qb.IN, qb.Array(keys...)))...)
+		}
+	} else {
+		q = func(keys []any) *qb.QB {
+			return qb.NewSyncQB(db.SyncQ()).SetDialect(dialect).SelectFrom("*", relatedMeta.table).
+				Where(relatedConditions(relatedMeta, "", qb.Compare(rel.fk, qb.IN, qb.Array(keys...)))...)
+		}
+	}
+	related, err := queryRelated(dialect, keys, q, rel.related, keyColumn)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < items.Len(); i++ {
+		values := related[relationKey(keys[i])]
+		field := items.Index(i).FieldByIndex(rel.field.Index)
+		slice := reflect.MakeSlice(field.Type(), 0, len(values))
+		for j := 0; j < len(values); j++ {
+			if field.Type().Elem().Kind() == reflect.Pointer {
+				slice = reflect.Append(slice, values[j].Addr())
+			} else {
+				slice = reflect.Append(slice, values[j])
+			}
+		}
+		field.Set(slice)
+	}
+	return nil
+}
+
+// relatedConditions adds the condition that skips soft deleted related rows.
+func relatedConditions(relatedMeta *tableMeta, prefix string, condition any) []any {
+	if notDeleted := relatedMeta.notDeleted(prefix); notDeleted != nil {
+		return []any{condition, qb.AND, notDeleted}
+	}
+	return []any{condition}
+}
+
+// queryRelated executes the queries for the unique keys and groups the related structures by the value of the keyColumn.
+// The keys are divided into chunks so as not to exceed the limit of placeholders of the dialect.
+func queryRelated(dialect qb.Dialect, keys []any, query func(keys []any) *qb.QB, relatedType reflect.Type, keyColumn string) (map[string][]reflect.Value, error) {
+	unique := []any{}
+	seen := map[string]bool{}
+	for i := 0; i < len(keys); i++ {
+		key := relationKey(keys[i])
+		if keys[i] == nil || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, keys[i])
+	}
+	related := map[string][]reflect.Value{}
+	size := dialect.MaxPlaceholders()
+	for start := 0; start < len(unique); start += size {
+		end := start + size
+		if end > len(unique) {
+			end = len(unique)
+		}
+		rows, err := query(unique[start:end]).Query()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(rows); i++ {
+			value := reflect.New(relatedType).Elem()
+			if err := mapper.FillStructFromDb(&value, &rows[i]); err != nil {
+				return nil, err
+			}
+			key := relationKey(relationValue(rows[i][keyColumn]))
+			related[key] = append(related[key], value)
+		}
+	}
+	return related, nil
+}
+
+// relationValue converts the key value in the same way as the driver does, so nullable keys,
+// such as *int64 or [sql.NullInt64], are replaced by their value or by nil if they are NULL.
+func relationValue(value any) any {
+	converted, err := driver.DefaultParameterConverter.ConvertValue(value)
+	if err != nil {
+		return value
+	}
+	return converted
+}
+
+// relationKey converts the key value into a string, so that the values of different
+// integer types and the []byte values of the driver can be compared.
+func relationKey(value any) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+type ErrRelationNotFound struct {
+	Type     string
+	Relation string
+}
+
+func (e ErrRelationNotFound) Error() string {
+	return fmt.Sprintf("the %s structure does not have the %s relation", e.Type, e.Relation)
+}
+
+type ErrInvalidRelation struct {
+	Type     string
+	Relation string
+	Tag      string
+}
+
+func (e ErrInvalidRelation) Error() string {
+	return fmt.Sprintf("invalid %s tag %q of the %s.%s field", namelib.TAGS.DB_RELATION, e.Tag, e.Type, e.Relation)
+}
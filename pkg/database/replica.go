@@ -0,0 +1,391 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// ReplicaStrategy the way to select a read replica.
+type ReplicaStrategy int
+
+const (
+	// ROUND_ROBIN replicas are selected in turn.
+	ROUND_ROBIN ReplicaStrategy = iota
+	// LEAST_LATENCY the replica with the lowest average query time is selected.
+	LEAST_LATENCY
+)
+
+// DEFAULT_REPLICA_RETRY_DELAY the time after which an unavailable replica is tried again by a reading query.
+const DEFAULT_REPLICA_RETRY_DELAY = 30 * time.Second
+
+type replica struct {
+	db      interfaces.Database
+	healthy atomic.Bool
+	// Average query time in nanoseconds.
+	latency atomic.Int64
+	// The time when the replica became unavailable or was last tried, in nanoseconds.
+	downAt atomic.Int64
+}
+
+// markDown excludes the replica from routing.
+func (r *replica) markDown() {
+	r.downAt.Store(time.Now().UnixNano())
+	r.healthy.Store(false)
+}
+
+// claimRetry returns true if the replica is unavailable and the delay has passed since it was last tried.
+// Only one query gets the right to try the replica, the next one can try it only after the delay.
+func (r *replica) claimRetry(delay time.Duration) bool {
+	if delay <= 0 || r.healthy.Load() {
+		return false
+	}
+	downAt := r.downAt.Load()
+	now := time.Now().UnixNano()
+	return now-downAt >= int64(delay) && r.downAt.CompareAndSwap(downAt, now)
+}
+
+// observe updates the average query time.
+func (r *replica) observe(d time.Duration) {
+	old := r.latency.Load()
+	if old == 0 {
+		r.latency.Store(int64(d))
+		return
+	}
+	r.latency.Store(old + (int64(d)-old)/5)
+}
+
+// ReplicaGroup a group of connections consisting of a primary database and read replicas.
+// The group implements the [interfaces.Database] interface, so it can be added to the [DatabasePool]
+// like a normal connection.
+//
+// Reading queries (Query, QueryRows, QueryIter) are sent to a healthy replica, which is selected
+// by [ReplicaStrategy]. If there are no healthy replicas, or the replica returned a connection error,
+// the query is sent to the primary database. Queries passed to these methods that change data or lock rows,
+// for example INSERT ... RETURNING or SELECT ... FOR UPDATE, as well as Exec queries and transactions
+// are always executed by the primary database.
+//
+// An unavailable replica is returned to routing in two ways. Without the health check, one reading query
+// tries the replica again after the retry delay ([DEFAULT_REPLICA_RETRY_DELAY], changed by SetRetryDelay),
+// and the replica becomes healthy if the query succeeds. The health check started by StartHealthCheck
+// checks all replicas with its own interval and restores them without waiting for the delay.
+//
+// To read your own writes, use the [UsePrimary] function or the [WithPrimary] context.
+// The context is available only for QueryRowsContext and QueryIter, the Query and QueryRows methods
+// do not accept it, so for them UsePrimary must be used.
+type ReplicaGroup struct {
+	primary    interfaces.Database
+	replicas   []*replica
+	strategy   ReplicaStrategy
+	retryDelay time.Duration
+	next       atomic.Uint64
+	syncQ      *replicaSyncQ
+	asyncQ     interfaces.AsyncQ
+	stopCheck  chan struct{}
+	checkWg    sync.WaitGroup
+}
+
+func NewReplicaGroup(primary interfaces.Database, replicas []interfaces.Database, strategy ReplicaStrategy) *ReplicaGroup {
+	g := &ReplicaGroup{primary: primary, strategy: strategy, retryDelay: DEFAULT_REPLICA_RETRY_DELAY}
+	for i := 0; i < len(replicas); i++ {
+		r := &replica{db: replicas[i]}
+		r.healthy.Store(true)
+		g.replicas = append(g.replicas, r)
+	}
+	g.syncQ = &replicaSyncQ{group: g}
+	g.asyncQ = NewAsyncQueries(g.syncQ)
+	return g
+}
+
+// Open opens the primary database and all replicas.
+func (g *ReplicaGroup) Open() error {
+	if err := g.primary.Open(); err != nil {
+		return err
+	}
+	for i := 0; i < len(g.replicas); i++ {
+		if err := g.replicas[i].db.Open(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the health check and closes all databases of the group.
+func (g *ReplicaGroup) Close() error {
+	g.StopHealthCheck()
+	err := g.primary.Close()
+	for i := 0; i < len(g.replicas); i++ {
+		err = errors.Join(err, g.replicas[i].db.Close())
+	}
+	return err
+}
+
+// SetRetryDelay sets the time after which an unavailable replica is tried again by a reading query.
+// If delay is not greater than zero, the replicas are restored only by the health check.
+// Must be called before the queries are executed.
+func (g *ReplicaGroup) SetRetryDelay(delay time.Duration) {
+	g.retryDelay = delay
+}
+
+// Primary returns the primary database.
+func (g *ReplicaGroup) Primary() interfaces.Database {
+	return g.primary
+}
+
+// PingContext checks the primary database. The replicas are checked by the CheckHealth method.
+func (g *ReplicaGroup) PingContext(ctx context.Context) error {
+	return PingDatabase(ctx, g.primary)
+}
+
+// Stats returns the statistics of the connection pool of the primary database.
+// If the primary database does not provide statistics, empty statistics are returned.
+func (g *ReplicaGroup) Stats() sql.DBStats {
+	if s, ok := g.primary.(StatsDatabase); ok {
+		return s.Stats()
+	}
+	return sql.DBStats{}
+}
+
+// NewTransaction creates a transaction of the primary database.
+func (g *ReplicaGroup) NewTransaction() (interfaces.DatabaseTransaction, error) {
+	return g.primary.NewTransaction()
+}
+
+// SyncQ getting access to synchronous requests that are routed between the databases of the group.
+func (g *ReplicaGroup) SyncQ() interfaces.SyncQ {
+	return g.syncQ
+}
+
+func (g *ReplicaGroup) NewAsyncQ() (interfaces.AsyncQ, error) {
+	aq, err := g.asyncQ.New()
+	if err != nil {
+		return nil, err
+	}
+	return aq.(interfaces.AsyncQ), nil
+}
+
+// StartHealthCheck starts checking the replicas with the given interval.
+// An unavailable replica is excluded from routing until the check finds it available again.
+// The check is stopped by the StopHealthCheck or Close methods.
+func (g *ReplicaGroup) StartHealthCheck(interval time.Duration) {
+	g.StopHealthCheck()
+	g.stopCheck = make(chan struct{})
+	g.checkWg.Add(1)
+	go func(stop chan struct{}) {
+		defer g.checkWg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				g.CheckHealth()
+			}
+		}
+	}(g.stopCheck)
+}
+
+// StopHealthCheck stops the health check if it is running.
+func (g *ReplicaGroup) StopHealthCheck() {
+	if g.stopCheck != nil {
+		close(g.stopCheck)
+		g.checkWg.Wait()
+		g.stopCheck = nil
+	}
+}
+
+// CheckHealth checks the availability of each replica once.
+func (g *ReplicaGroup) CheckHealth() {
+	for i := 0; i < len(g.replicas); i++ {
+		r := g.replicas[i]
+		start := time.Now()
+		if _, err := r.db.SyncQ().Query("SELECT 1"); err != nil {
+			r.markDown()
+			continue
+		}
+		r.observe(time.Since(start))
+		r.healthy.Store(true)
+	}
+}
+
+// HealthyReplicas returns the number of replicas that are available for reading.
+func (g *ReplicaGroup) HealthyReplicas() int {
+	n := 0
+	for i := 0; i < len(g.replicas); i++ {
+		if g.replicas[i].healthy.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+// selectReplica selects a healthy replica by strategy. If the retry delay of an unavailable replica
+// has passed, this replica is selected to try it again. If there are no healthy replicas, nil is returned.
+func (g *ReplicaGroup) selectReplica() *replica {
+	if len(g.replicas) == 0 {
+		return nil
+	}
+	for i := 0; i < len(g.replicas); i++ {
+		if g.replicas[i].claimRetry(g.retryDelay) {
+			return g.replicas[i]
+		}
+	}
+	switch g.strategy {
+	case LEAST_LATENCY:
+		var best *replica
+		for i := 0; i < len(g.replicas); i++ {
+			r := g.replicas[i]
+			if r.healthy.Load() && (best == nil || r.latency.Load() < best.latency.Load()) {
+				best = r
+			}
+		}
+		return best
+	default:
+		start := g.next.Add(1)
+		for i := 0; i < len(g.replicas); i++ {
+			r := g.replicas[(start+uint64(i))%uint64(len(g.replicas))]
+			if r.healthy.Load() {
+				return r
+			}
+		}
+		return nil
+	}
+}
+
+// read executes a reading query on a replica. If the replica is unavailable,
+// it is marked as unhealthy and the query is executed by the primary database.
+// A successful query marks the replica as healthy, which restores a replica that was tried again.
+// A query that does not only read data is always executed by the primary database.
+func (g *ReplicaGroup) read(ctx context.Context, query string, fn func(syncQ interfaces.SyncQ) error) error {
+	if !isPrimaryContext(ctx) && cacheableQuery(query) {
+		if r := g.selectReplica(); r != nil {
+			start := time.Now()
+			err := fn(r.db.SyncQ())
+			if err == nil {
+				r.observe(time.Since(start))
+				r.healthy.Store(true)
+				return nil
+			}
+			if !IsTransientError(err) {
+				return err
+			}
+			r.markDown()
+		}
+	}
+	return fn(g.primary.SyncQ())
+}
+
+// IsTransientError returns true if the error is related to the connection, not to the query,
+// for example [driver.ErrBadConn] or "connection refused". Such a query can be repeated.
+// A canceled context or an expired deadline is not transient, even though [context.DeadlineExceeded]
+// implements [net.Error], because repeating the query with the same context fails again.
+func IsTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr)
+}
+
+type primaryContextKey struct{}
+
+// WithPrimary returns a context for which reading queries of the [ReplicaGroup]
+// are executed by the primary database. Used with QueryRowsContext and QueryIter,
+// the Query and QueryRows methods do not accept a context and ignore it.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+func isPrimaryContext(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryContextKey{}).(bool)
+	return v
+}
+
+// UsePrimary returns the primary database if db is a [ReplicaGroup], otherwise db itself.
+// It is used when the request must read its own writes.
+func UsePrimary(db interfaces.DatabaseInteraction) interfaces.DatabaseInteraction {
+	if group, ok := db.(*ReplicaGroup); ok {
+		return group.primary
+	}
+	return db
+}
+
+// replicaSyncQ synchronous queries that are routed between the databases of the [ReplicaGroup].
+// If the SetDB method is called, all queries are executed by the set object.
+type replicaSyncQ struct {
+	group *ReplicaGroup
+	qe    interfaces.QueryExec
+}
+
+func (q *replicaSyncQ) New() (interface{}, error) {
+	return &replicaSyncQ{group: q.group, qe: q.qe}, nil
+}
+
+func (q *replicaSyncQ) SetDB(db interfaces.QueryExec) {
+	q.qe = db
+}
+
+func (q *replicaSyncQ) Query(query string, args ...any) ([]map[string]interface{}, error) {
+	if q.qe != nil {
+		return q.qe.Query(query, args...)
+	}
+	var res []map[string]interface{}
+	err := q.group.read(context.Background(), query, func(syncQ interfaces.SyncQ) error {
+		var err error
+		res, err = syncQ.Query(query, args...)
+		return err
+	})
+	return res, err
+}
+
+func (q *replicaSyncQ) QueryRows(query string, args ...any) (*sql.Rows, error) {
+	return q.QueryRowsContext(context.Background(), query, args...)
+}
+
+func (q *replicaSyncQ) QueryRowsContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if q.qe != nil {
+		rowsQuery, ok := q.qe.(interfaces.RowsQuery)
+		if !ok {
+			return nil, ErrRowsQueryNotSupported{}
+		}
+		return rowsQuery.QueryRowsContext(ctx, query, args...)
+	}
+	var rows *sql.Rows
+	err := q.group.read(ctx, query, func(syncQ interfaces.SyncQ) error {
+		rowsQuery, ok := syncQ.(interfaces.RowsQuery)
+		if !ok {
+			return ErrRowsQueryNotSupported{}
+		}
+		var err error
+		rows, err = rowsQuery.QueryRowsContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (q *replicaSyncQ) QueryIter(ctx context.Context, query string, args ...any) (*dbutils.Cursor, error) {
+	rows, err := q.QueryRowsContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return dbutils.NewCursor(ctx, rows), nil
+}
+
+// Exec is always executed by the primary database.
+func (q *replicaSyncQ) Exec(query string, args ...any) (map[string]interface{}, error) {
+	if q.qe != nil {
+		return q.qe.Exec(query, args...)
+	}
+	return q.group.primary.SyncQ().Exec(query, args...)
+}
@@ -0,0 +1,552 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+	"github.com/uwine4850/foozy/pkg/mapper"
+	"github.com/uwine4850/foozy/pkg/namelib"
+	"github.com/uwine4850/foozy/pkg/typeopr"
+)
+
+// Repository performs basic CRUD operations with the table described by the T structure.
+//
+// The structure is configured with tags:
+//   - db:"<column name>" — the table column, fields without this tag are skipped.
+//   - dbtable:"<table name>" — the table name, usually set on an empty field: _ struct{} `dbtable:"users"`.
+//   - dbpk:"true" — the primary key. If it is not set, the "id" column is used.
+//   - dbai:"true" — the auto-increment primary key. It is also considered auto-increment if it has an integer type.
+//   - dbrel — the relation that can be loaded with the [Repository.Preload] method, see [Preload].
+//   - dbauto — the column that is filled automatically:
+//     dbauto:"created" and dbauto:"updated" — the time of creation and update;
+//     dbauto:"deleted" — the time of soft deletion, such rows are skipped by default;
+//     the time fields have the time.Time, *time.Time or sql.NullTime type;
+//     dbauto:"version" — the version for optimistic locking, the field has an integer type.
+//
+// The repository works over any database or transaction, since only the [interfaces.SyncAsyncQuery] is used.
+type Repository[T any] struct {
+	db      interfaces.SyncAsyncQuery
+	dialect qb.Dialect
+	meta    *tableMeta
+	preload []string
+	trashed trashedScope
+}
+
+const (
+	AUTO_CREATED = "created"
+	AUTO_UPDATED = "updated"
+	AUTO_DELETED = "deleted"
+	AUTO_VERSION = "version"
+)
+
+// trashedScope determines which rows are selected if the table has a soft delete column.
+type trashedScope int
+
+const (
+	withoutTrashed trashedScope = iota
+	withTrashed
+	onlyTrashed
+)
+
+// tableMeta table description of the structure that is read from the tags.
+type tableMeta struct {
+	table   string
+	pk      string
+	pkIndex int
+	ai      bool
+	// columns index of the field for each column.
+	columns map[string]int
+	// auto column name for each kind of the dbauto tag.
+	auto map[string]string
+}
+
+// field returns the value of the column field if the structure has it.
+func (m *tableMeta) field(item reflect.Value, kind string) (reflect.Value, bool) {
+	col, ok := m.auto[kind]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return item.Field(m.columns[col]), true
+}
+
+// notDeleted returns the condition that skips soft deleted rows.
+// The column can be prefixed with the table name. If the table has no soft delete column, nil is returned.
+func (m *tableMeta) notDeleted(prefix string) any {
+	col, ok := m.auto[AUTO_DELETED]
+	if !ok {
+		return nil
+	}
+	return qb.Compare(prefix+col, qb.IS, qb.NULL)
+}
+
+// tableMetaCache stores table descriptions.
+// Key - reflect.Type.
+// Value - *tableMeta.
+var tableMetaCache sync.Map
+
+// loadTableMeta loads the table description of the structure from the cache or reads it from the tags.
+func loadTableMeta(typ reflect.Type) (*tableMeta, error) {
+	if meta, ok := tableMetaCache.Load(typ); ok {
+		return meta.(*tableMeta), nil
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, typeopr.ErrParameterNotStruct{Param: typ.String()}
+	}
+	meta := &tableMeta{pk: "id", pkIndex: -1, columns: map[string]int{}, auto: map[string]string{}}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if table := field.Tag.Get(namelib.TAGS.DB_TABLE); table != "" {
+			meta.table = table
+		}
+		col := field.Tag.Get(namelib.TAGS.DB_MAPPER_NAME)
+		if col == "" {
+			continue
+		}
+		meta.columns[col] = i
+		if kind := field.Tag.Get(namelib.TAGS.DB_AUTO); kind != "" {
+			if err := checkAutoField(&field, kind); err != nil {
+				return nil, err
+			}
+			meta.auto[kind] = col
+		}
+		if field.Tag.Get(namelib.TAGS.DB_SCHEMA_PK) == "true" {
+			meta.pk = col
+			meta.pkIndex = i
+		} else if meta.pkIndex == -1 && col == "id" {
+			meta.pkIndex = i
+		}
+	}
+	if meta.table == "" {
+		return nil, ErrRepositoryTable{Type: typ.String()}
+	}
+	if err := qb.CheckIdent(meta.table); err != nil {
+		return nil, err
+	}
+	if meta.pkIndex == -1 {
+		return nil, ErrRepositoryPK{Type: typ.String()}
+	}
+	pkField := typ.Field(meta.pkIndex)
+	meta.ai = isIntKind(pkField.Type.Kind()) || pkField.Tag.Get(namelib.TAGS.DB_SCHEMA_AI) == "true"
+	tableMetaCache.Store(typ, meta)
+	return meta, nil
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+var (
+	typeTime     = reflect.TypeOf(time.Time{})
+	typeTimePtr  = reflect.TypeOf((*time.Time)(nil))
+	typeNullTime = reflect.TypeOf(sql.NullTime{})
+)
+
+// setTime writes the time to a time.Time, *time.Time or sql.NullTime field.
+func setTime(field reflect.Value, t time.Time) {
+	switch field.Type() {
+	case typeTimePtr:
+		field.Set(reflect.ValueOf(&t))
+	case typeNullTime:
+		field.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+	default:
+		field.Set(reflect.ValueOf(t))
+	}
+}
+
+// checkAutoField checks that the type of the field matches the kind of the dbauto tag.
+func checkAutoField(field *reflect.StructField, kind string) error {
+	switch kind {
+	case AUTO_CREATED, AUTO_UPDATED, AUTO_DELETED:
+		if field.Type == typeTime || field.Type == typeTimePtr || field.Type == typeNullTime {
+			return nil
+		}
+	case AUTO_VERSION:
+		if isIntKind(field.Type.Kind()) {
+			return nil
+		}
+	}
+	return ErrInvalidAutoField{Field: field.Name, Kind: kind}
+}
+
+// NewRepository creates a repository for the T structure.
+// Returns an error if the structure does not have the table name or the primary key column.
+func NewRepository[T any](db interfaces.SyncAsyncQuery) (*Repository[T], error) {
+	meta, err := loadTableMeta(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return &Repository[T]{db: db, dialect: qb.DefaultDialect, meta: meta}, nil
+}
+
+// SetDialect sets the sql dialect of the repository queries.
+func (r *Repository[T]) SetDialect(dialect qb.Dialect) *Repository[T] {
+	r.dialect = dialect
+	return r
+}
+
+// WithTx returns a copy of the repository that executes queries in the transaction.
+func (r *Repository[T]) WithTx(tx interfaces.DatabaseTransaction) *Repository[T] {
+	repository := *r
+	repository.db = tx
+	return &repository
+}
+
+// Table returns the name of the table.
+func (r *Repository[T]) Table() string {
+	return r.meta.table
+}
+
+// PK returns the name of the primary key column.
+func (r *Repository[T]) PK() string {
+	return r.meta.pk
+}
+
+func (r *Repository[T]) newQB() *qb.QB {
+	return qb.NewSyncQB(r.db.SyncQ()).SetDialect(r.dialect)
+}
+
+// WithTrashed returns a copy of the repository that also selects soft deleted rows.
+func (r *Repository[T]) WithTrashed() *Repository[T] {
+	repository := *r
+	repository.trashed = withTrashed
+	return &repository
+}
+
+// OnlyTrashed returns a copy of the repository that selects only soft deleted rows.
+func (r *Repository[T]) OnlyTrashed() *Repository[T] {
+	repository := *r
+	repository.trashed = onlyTrashed
+	return &repository
+}
+
+// where adds the soft delete condition to the conditions of the query.
+// The conditions of the user are enclosed in brackets so that OR does not change the meaning of the query.
+func (r *Repository[T]) where(conditions ...any) []any {
+	col, ok := r.meta.auto[AUTO_DELETED]
+	if !ok || r.trashed == withTrashed {
+		return conditions
+	}
+	scope := qb.Compare(col, qb.IS, qb.NULL)
+	if r.trashed == onlyTrashed {
+		scope = qb.Compare(col, qb.IS_NOT, qb.NULL)
+	}
+	if len(conditions) == 0 {
+		return []any{scope}
+	}
+	return append(append(append([]any{"("}, conditions...), ")", qb.AND), scope)
+}
+
+// Preload returns a copy of the repository that loads the relations for the [Repository.Get]
+// and [Repository.Find] methods. The relations are loaded by the [Preload] function.
+func (r *Repository[T]) Preload(relations ...string) *Repository[T] {
+	repository := *r
+	repository.preload = append(append([]string{}, r.preload...), relations...)
+	return &repository
+}
+
+// query executes the query and scans the result into a slice of structures.
+// After that, the relations are loaded if they were selected by the [Repository.Preload] method.
+func (r *Repository[T]) query(q *qb.QB) ([]T, error) {
+	q.Merge()
+	items, err := QueryInto[T](r.db.SyncQ(), q.String(), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.preload) != 0 {
+		if err := Preload(r.db, r.dialect, items, r.preload...); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Get returns the row with the primary key id.
+// If the row does not exist, an [ErrNotFound] error is returned.
+func (r *Repository[T]) Get(id any) (*T, error) {
+	items, err := r.query(r.newQB().SelectFrom("*", r.meta.table).Where(r.where(qb.Compare(r.meta.pk, qb.EQUAL, id))...).Limit(1))
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrNotFound{Table: r.meta.table, ID: id}
+	}
+	return &items[0], nil
+}
+
+// Find returns the rows that match the conditions. The conditions are the same as for [qb.QB.Where].
+// Without conditions all rows of the table are returned.
+func (r *Repository[T]) Find(conditions ...any) ([]T, error) {
+	return r.query(r.newQB().SelectFrom("*", r.meta.table).Where(r.where(conditions...)...))
+}
+
+// Count returns the number of rows that match the conditions.
+func (r *Repository[T]) Count(conditions ...any) (int64, error) {
+	res, err := r.newQB().SelectFrom("COUNT(*) AS total", r.meta.table).Where(r.where(conditions...)...).Query()
+	if err != nil {
+		return 0, err
+	}
+	if err := dbutils.DatabaseResultNotEmpty(res); err != nil {
+		return 0, err
+	}
+	count, err := dbutils.ParseInt(res[0]["total"])
+	return int64(count), err
+}
+
+// Create inserts the structure into the table.
+// If the primary key is auto-increment and not set, it is skipped and then set from the "insertID" value.
+// Empty creation and update times are set to the current time, an empty version is set to 1.
+func (r *Repository[T]) Create(item *T) error {
+	value := reflect.ValueOf(item).Elem()
+	now := time.Now()
+	for _, kind := range []string{AUTO_CREATED, AUTO_UPDATED} {
+		if field, ok := r.meta.field(value, kind); ok && field.IsZero() {
+			setTime(field, now)
+		}
+	}
+	if field, ok := r.meta.field(value, AUTO_VERSION); ok && field.IsZero() {
+		setInt(field, 1)
+	}
+	var nilIfEmpty []string
+	if col, ok := r.meta.auto[AUTO_DELETED]; ok {
+		nilIfEmpty = append(nilIfEmpty, col)
+	}
+	params, err := mapper.ParamsValueFromDbStruct(typeopr.Ptr{}.New(item), nilIfEmpty)
+	if err != nil {
+		return err
+	}
+	pkValue := value.Field(r.meta.pkIndex)
+	setID := r.meta.ai && pkValue.IsZero()
+	if setID {
+		delete(params, r.meta.pk)
+	}
+	res, err := r.newQB().Insert(r.meta.table, params).Exec()
+	if err != nil {
+		return err
+	}
+	if setID {
+		if id, ok := res["insertID"].(int64); ok && id != 0 {
+			setInt(pkValue, id)
+		}
+	}
+	return nil
+}
+
+// Update updates the row with the primary key of the structure.
+// If fields are passed, only these columns are updated, otherwise all columns except the primary key
+// and the soft delete column. The update time and version columns are always updated.
+//
+// If the table has a version column, the row is updated only if its version has not changed since it was read.
+// Otherwise, an [ErrVersionConflict] error is returned and the structure is not changed.
+// Without the version column a missing row is not an error, since MySQL does not count rows whose values have not changed.
+//
+// Like [Repository.Find], the soft deleted rows are not updated unless [Repository.WithTrashed] is used.
+// If such a row has a version column, an [ErrNotFound] error is returned instead of the version conflict.
+func (r *Repository[T]) Update(item *T, fields ...string) error {
+	value := reflect.ValueOf(item).Elem()
+	var updatedField reflect.Value
+	var oldUpdated reflect.Value
+	if field, ok := r.meta.field(value, AUTO_UPDATED); ok {
+		updatedField = field
+		oldUpdated = reflect.ValueOf(field.Interface())
+		setTime(field, time.Now())
+	}
+	versionField, hasVersion := r.meta.field(value, AUTO_VERSION)
+	var oldVersion int64
+	if hasVersion {
+		oldVersion = getInt(versionField)
+		setInt(versionField, oldVersion+1)
+	}
+	// restore returns the previous values if the update fails.
+	restore := func() {
+		if updatedField.IsValid() {
+			updatedField.Set(oldUpdated)
+		}
+		if hasVersion {
+			setInt(versionField, oldVersion)
+		}
+	}
+	params, err := mapper.ParamsValueFromDbStruct(typeopr.Ptr{}.New(item), nil)
+	if err != nil {
+		restore()
+		return err
+	}
+	id := params[r.meta.pk]
+	delete(params, r.meta.pk)
+	delete(params, r.meta.auto[AUTO_DELETED])
+	if len(fields) != 0 {
+		selected := make(map[string]any, len(fields))
+		for i := 0; i < len(fields); i++ {
+			value, ok := params[fields[i]]
+			if !ok {
+				restore()
+				return ErrUnknownColumn{Table: r.meta.table, Column: fields[i]}
+			}
+			selected[fields[i]] = value
+		}
+		for _, kind := range []string{AUTO_UPDATED, AUTO_VERSION} {
+			if col, ok := r.meta.auto[kind]; ok {
+				selected[col] = params[col]
+			}
+		}
+		params = selected
+	}
+	conditions := []any{qb.Compare(r.meta.pk, qb.EQUAL, id)}
+	if hasVersion {
+		conditions = append(conditions, qb.AND, qb.Compare(r.meta.auto[AUTO_VERSION], qb.EQUAL, oldVersion))
+	}
+	res, err := r.newQB().Update(r.meta.table, params).Where(r.where(conditions...)...).Exec()
+	if err != nil {
+		restore()
+		return err
+	}
+	if hasVersion {
+		if affected, ok := res["rowsAffected"].(int64); ok && affected == 0 {
+			restore()
+			count, err := r.Count(qb.Compare(r.meta.pk, qb.EQUAL, id))
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrNotFound{Table: r.meta.table, ID: id}
+			}
+			return ErrVersionConflict{Table: r.meta.table, ID: id, Version: oldVersion}
+		}
+	}
+	return nil
+}
+
+// Delete deletes the row with the primary key id.
+// If the table has a soft delete column, the deletion time is set instead of deleting the row.
+// If the row does not exist, an [ErrNotFound] error is returned.
+func (r *Repository[T]) Delete(id any) error {
+	col, ok := r.meta.auto[AUTO_DELETED]
+	if !ok {
+		return r.ForceDelete(id)
+	}
+	res, err := r.newQB().Update(r.meta.table, map[string]any{col: time.Now()}).
+		Where(qb.Compare(r.meta.pk, qb.EQUAL, id), qb.AND, qb.Compare(col, qb.IS, qb.NULL)).Exec()
+	if err != nil {
+		return err
+	}
+	return r.checkFound(res, id)
+}
+
+// ForceDelete deletes the row with the primary key id from the table, even if the table has a soft delete column.
+// If the row does not exist, an [ErrNotFound] error is returned.
+func (r *Repository[T]) ForceDelete(id any) error {
+	res, err := r.newQB().Delete(r.meta.table).Where(qb.Compare(r.meta.pk, qb.EQUAL, id)).Exec()
+	if err != nil {
+		return err
+	}
+	return r.checkFound(res, id)
+}
+
+// Restore restores the soft deleted row with the primary key id.
+// If there is no such deleted row, an [ErrNotFound] error is returned.
+func (r *Repository[T]) Restore(id any) error {
+	col, ok := r.meta.auto[AUTO_DELETED]
+	if !ok {
+		return ErrNoSoftDelete{Table: r.meta.table}
+	}
+	res, err := r.newQB().Update(r.meta.table, map[string]any{col: nil}).
+		Where(qb.Compare(r.meta.pk, qb.EQUAL, id), qb.AND, qb.Compare(col, qb.IS_NOT, qb.NULL)).Exec()
+	if err != nil {
+		return err
+	}
+	return r.checkFound(res, id)
+}
+
+// checkFound returns an [ErrNotFound] error if the query has not changed any rows.
+func (r *Repository[T]) checkFound(res map[string]interface{}, id any) error {
+	if affected, ok := res["rowsAffected"].(int64); ok && affected == 0 {
+		return ErrNotFound{Table: r.meta.table, ID: id}
+	}
+	return nil
+}
+
+func getInt(value reflect.Value) int64 {
+	if value.CanInt() {
+		return value.Int()
+	}
+	return int64(value.Uint())
+}
+
+func setInt(value reflect.Value, n int64) {
+	if value.CanInt() {
+		value.SetInt(n)
+	} else if value.CanUint() {
+		value.SetUint(uint64(n))
+	}
+}
+
+type ErrNotFound struct {
+	Table string
+	ID    any
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("row with id %v not found in the %s table", e.ID, e.Table)
+}
+
+type ErrRepositoryTable struct {
+	Type string
+}
+
+func (e ErrRepositoryTable) Error() string {
+	return fmt.Sprintf("the %s structure does not have the %s tag with the table name", e.Type, namelib.TAGS.DB_TABLE)
+}
+
+type ErrRepositoryPK struct {
+	Type string
+}
+
+func (e ErrRepositoryPK) Error() string {
+	return fmt.Sprintf("the %s structure does not have a primary key column", e.Type)
+}
+
+type ErrUnknownColumn struct {
+	Table  string
+	Column string
+}
+
+func (e ErrUnknownColumn) Error() string {
+	return fmt.Sprintf("the %s table does not have the %s column", e.Table, e.Column)
+}
+
+type ErrVersionConflict struct {
+	Table   string
+	ID      any
+	Version int64
+}
+
+func (e ErrVersionConflict) Error() string {
+	return fmt.Sprintf("row with id %v in the %s table was changed by another query, expected version %d", e.ID, e.Table, e.Version)
+}
+
+type ErrNoSoftDelete struct {
+	Table string
+}
+
+func (e ErrNoSoftDelete) Error() string {
+	return fmt.Sprintf("the %s table does not have a soft delete column", e.Table)
+}
+
+type ErrInvalidAutoField struct {
+	Field string
+	Kind  string
+}
+
+func (e ErrInvalidAutoField) Error() string {
+	return fmt.Sprintf("the %s field has an invalid type or %s tag %q", e.Field, namelib.TAGS.DB_AUTO, e.Kind)
+}
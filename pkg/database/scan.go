@@ -0,0 +1,32 @@
+package database
+
+import (
+	"github.com/uwine4850/foozy/pkg/interfaces"
+	"github.com/uwine4850/foozy/pkg/mapper"
+)
+
+// QueryInto executes the query and scans the result directly into a slice of structures.
+// Unlike the combination of [SyncQueries.Query] and [mapper.FillStructSliceFromDb], no intermediate
+// maps are created, so this function is preferable for large results.
+// The structure fields must have the `db:"<column name>"` tag.
+// The synchronous queries must implement the [interfaces.RowsQuery] interface,
+// otherwise the [ErrRowsQueryNotSupported] error is returned.
+func QueryInto[T any](syncQ interfaces.SyncQ, query string, args ...any) ([]T, error) {
+	rowsQuery, ok := syncQ.(interfaces.RowsQuery)
+	if !ok {
+		return nil, ErrRowsQueryNotSupported{}
+	}
+	rows, err := rowsQuery.QueryRows(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []T
+	if err := mapper.ScanRowsInto(rows, func(item *T) error {
+		res = append(res, *item)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,229 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	"github.com/uwine4850/foozy/pkg/database/migrate"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// LiveColumn a column that exists in the database.
+type LiveColumn struct {
+	Name string
+	Type string
+	Null bool
+}
+
+// ReadColumns reads the columns of a table from the database.
+// For MySQL and PostgreSQL information_schema is used, for sqlite — the table_info pragma.
+// If the table does not exist, an empty slice is returned.
+func ReadColumns(syncQ interfaces.SyncQ, dialect qb.Dialect, tableName string) ([]LiveColumn, error) {
+	var query string
+	switch dialect.Name() {
+	case qb.MYSQL.Name():
+		query = "SELECT column_name AS name, column_type AS type, is_nullable AS nullable FROM information_schema.columns " +
+			"WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position"
+	case qb.POSTGRES.Name():
+		query = "SELECT column_name AS name, data_type AS type, is_nullable AS nullable FROM information_schema.columns " +
+			"WHERE table_schema = current_schema() AND table_name = ? ORDER BY ordinal_position"
+	case qb.SQLITE.Name():
+		query = "SELECT name, type, CASE WHEN \"notnull\" = 0 THEN 'YES' ELSE 'NO' END AS nullable FROM pragma_table_info(?) ORDER BY cid"
+	default:
+		return nil, ErrDialectNotSupported{Dialect: dialect.Name()}
+	}
+	res, err := syncQ.Query(qb.Rebind(dialect, query), tableName)
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]LiveColumn, len(res))
+	for i := 0; i < len(res); i++ {
+		cols[i] = LiveColumn{
+			Name: liveString(res[i], "name"),
+			Type: liveString(res[i], "type"),
+			Null: strings.EqualFold(liveString(res[i], "nullable"), "YES"),
+		}
+	}
+	return cols, nil
+}
+
+// ReadForeignKeys reads the names of the tables that the foreign keys of the table refer to.
+// Each table is returned once, the order is not defined.
+func ReadForeignKeys(syncQ interfaces.SyncQ, dialect qb.Dialect, tableName string) ([]string, error) {
+	var query string
+	switch dialect.Name() {
+	case qb.MYSQL.Name():
+		query = "SELECT DISTINCT referenced_table_name AS name FROM information_schema.key_column_usage " +
+			"WHERE table_schema = DATABASE() AND table_name = ? AND referenced_table_name IS NOT NULL"
+	case qb.POSTGRES.Name():
+		query = "SELECT DISTINCT ccu.table_name AS name FROM information_schema.table_constraints tc " +
+			"JOIN information_schema.constraint_column_usage ccu ON tc.constraint_name = ccu.constraint_name AND tc.table_schema = ccu.table_schema " +
+			"WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = current_schema() AND tc.table_name = ?"
+	case qb.SQLITE.Name():
+		query = "SELECT DISTINCT \"table\" AS name FROM pragma_foreign_key_list(?)"
+	default:
+		return nil, ErrDialectNotSupported{Dialect: dialect.Name()}
+	}
+	res, err := syncQ.Query(qb.Rebind(dialect, query), tableName)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]string, len(res))
+	for i := 0; i < len(res); i++ {
+		tables[i] = liveString(res[i], "name")
+	}
+	return tables, nil
+}
+
+// liveString reads a string value of the column. Some drivers return
+// upper case names of the information_schema columns.
+func liveString(row map[string]interface{}, key string) string {
+	value, ok := row[key]
+	if !ok {
+		value = row[strings.ToUpper(key)]
+	}
+	return dbutils.ParseString(value)
+}
+
+// Changes sql queries that bring the database in line with the table description.
+// The existence of the table and its columns and the type and nullability of the columns are compared.
+// Changes of the default value, indexes, unique constraints and foreign keys are not tracked,
+// they must be written to the migration manually.
+type Changes struct {
+	Up   []string
+	Down []string
+	// Extra the columns that exist in the database but are not in the description.
+	// They are removed only if [DiffOptions.DropExtra] is true, otherwise they are only listed here.
+	Extra []string
+	// Modified the columns whose type or nullability differs from the description.
+	// Sqlite cannot change columns, so for it they are only listed here.
+	Modified []string
+}
+
+// Empty returns true if there are no changes.
+func (c *Changes) Empty() bool {
+	return len(c.Up) == 0
+}
+
+// WriteMigration writes the changes to new sql migration files.
+// Returns the paths to the created files.
+func (c *Changes) WriteMigration(dir string, name string) (upPath string, downPath string, err error) {
+	return migrate.WriteSQLFiles(dir, name, strings.Join(c.Up, "\n")+"\n", strings.Join(c.Down, "\n")+"\n")
+}
+
+// DiffOptions settings of the [Diff] function.
+type DiffOptions struct {
+	// DropExtra removes the columns that exist in the database but are not in the description.
+	// Removing a column deletes its data, so it is disabled by default.
+	DropExtra bool
+}
+
+// Diff compares the table description with the table in the database and creates the changes.
+// If the table does not exist, it will be created. If the table exists, the missing columns
+// will be added and the columns with a different type or nullability will be changed.
+// The columns that are not in the description are saved in [Changes.Extra]
+// and are removed only with the [DiffOptions.DropExtra] option.
+// Changes of the default value, indexes, unique constraints and foreign keys are not tracked.
+func Diff(syncQ interfaces.SyncQ, dialect qb.Dialect, table *Table, opts DiffOptions) (*Changes, error) {
+	liveCols, err := ReadColumns(syncQ, dialect, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	changes := &Changes{}
+	if len(liveCols) == 0 {
+		changes.Up = append(changes.Up, table.TableQB(dialect).String())
+		changes.Down = append(changes.Down, qb.NewTableQB().SetDialect(dialect).Drop(table.Name).Build().String())
+		return changes, nil
+	}
+	live := make(map[string]LiveColumn, len(liveCols))
+	for i := 0; i < len(liveCols); i++ {
+		live[liveCols[i].Name] = liveCols[i]
+	}
+	for i := 0; i < len(table.Cols); i++ {
+		if liveCol, ok := live[table.Cols[i].Name]; ok {
+			if sameType(dialect.TypeName(table.Cols[i].Type), liveCol.Type) && table.Cols[i].Null == liveCol.Null {
+				continue
+			}
+			changes.Modified = append(changes.Modified, liveCol.Name)
+			modifyQB := qb.NewTableQB().SetDialect(dialect).ModifyColumn(table.Name, table.Cols[i]).Build()
+			if modifyQB.Err() != nil {
+				continue
+			}
+			changes.Up = append(changes.Up, modifyQB.String())
+			changes.Down = append(changes.Down, fmt.Sprintf("-- restore the type %s and nullability of the %s column of the %s table manually", liveCol.Type, liveCol.Name, table.Name))
+			continue
+		}
+		changes.Up = append(changes.Up, qb.NewTableQB().SetDialect(dialect).AddColumn(table.Name, table.Cols[i]).Build().String())
+		changes.Down = append(changes.Down, qb.NewTableQB().SetDialect(dialect).DropColumn(table.Name, table.Cols[i].Name).Build().String())
+	}
+	for i := 0; i < len(liveCols); i++ {
+		if _, ok := table.Col(liveCols[i].Name); ok {
+			continue
+		}
+		changes.Extra = append(changes.Extra, liveCols[i].Name)
+		if !opts.DropExtra {
+			continue
+		}
+		changes.Up = append(changes.Up, qb.NewTableQB().SetDialect(dialect).DropColumn(table.Name, liveCols[i].Name).Build().String())
+		changes.Down = append(changes.Down, fmt.Sprintf("-- restore the %s column (%s) of the %s table manually", liveCols[i].Name, liveCols[i].Type, table.Name))
+	}
+	// Changes are rolled back in reverse order.
+	for i, j := 0, len(changes.Down)-1; i < j; i, j = i+1, j-1 {
+		changes.Down[i], changes.Down[j] = changes.Down[j], changes.Down[i]
+	}
+	return changes, nil
+}
+
+// typeAliases names of the data types that are returned by the database instead of the names used by the dialects.
+var typeAliases = map[string]string{
+	"integer":                     "int",
+	"character varying":           "varchar",
+	"character":                   "char",
+	"numeric":                     "decimal",
+	"bool":                        "boolean",
+	"timestamp without time zone": "timestamp",
+}
+
+// sameType compares the data type of the description with the data type read from the database.
+// The databases return the types in different forms, for example MySQL returns BOOLEAN as tinyint(1),
+// and PostgreSQL returns VARCHAR(255) as "character varying" without the size. Therefore, the sizes
+// are compared only if both types have them, and the display width of integer types is ignored.
+func sameType(expected string, live string) bool {
+	expectedName, expectedSize := splitType(expected)
+	liveName, liveSize := splitType(live)
+	if liveName == "tinyint" && liveSize == "1" {
+		liveName, liveSize = "boolean", ""
+	}
+	if expectedName != liveName {
+		return false
+	}
+	switch expectedName {
+	case "int", "bigint", "smallint", "tinyint":
+		return true
+	}
+	return expectedSize == "" || liveSize == "" || expectedSize == liveSize
+}
+
+// splitType splits the type into a normalized name and the parameters without spaces.
+func splitType(typeName string) (name string, size string) {
+	typeName = strings.ToLower(strings.TrimSpace(typeName))
+	if start := strings.Index(typeName, "("); start != -1 && strings.HasSuffix(typeName, ")") {
+		size = strings.ReplaceAll(typeName[start+1:len(typeName)-1], " ", "")
+		typeName = typeName[:start]
+	}
+	name = strings.Join(strings.Fields(typeName), " ")
+	if alias, ok := typeAliases[name]; ok {
+		name = alias
+	}
+	return name, size
+}
+
+type ErrDialectNotSupported struct {
+	Dialect string
+}
+
+func (e ErrDialectNotSupported) Error() string {
+	return fmt.Sprintf("the %s dialect is not supported", e.Dialect)
+}
@@ -0,0 +1,311 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/namelib"
+)
+
+// Index table index description.
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// ForeignKey table foreign key description.
+type ForeignKey struct {
+	Name      string
+	Column    string
+	RefTable  string
+	RefColumn string
+}
+
+// Table description of a database table created from a structure.
+type Table struct {
+	Name        string
+	Cols        []qb.Col
+	Indexes     []Index
+	ForeignKeys []ForeignKey
+}
+
+// TableQB creates a [qb.TableQB] that creates the table, its indexes and foreign keys.
+// Foreign keys are created together with the table, because sqlite cannot add them with the ALTER TABLE command.
+func (t *Table) TableQB(dialect qb.Dialect) *qb.TableQB {
+	constraints := make([]qb.Constraint, len(t.ForeignKeys))
+	for i := 0; i < len(t.ForeignKeys); i++ {
+		fk := t.ForeignKeys[i]
+		constraints[i] = qb.ForeignKey(fk.Name, fk.Column, fmt.Sprintf("%s(%s)", fk.RefTable, fk.RefColumn), "", "")
+	}
+	tableQB := qb.NewTableQB().SetDialect(dialect).Create(t.Name, t.Cols, constraints...)
+	for i := 0; i < len(t.Indexes); i++ {
+		tableQB.CreateIndex(t.Name, t.Indexes[i].Name, t.Indexes[i].Unique, t.Indexes[i].Columns...)
+	}
+	return tableQB.Build()
+}
+
+// Col returns the column by name.
+func (t *Table) Col(name string) (qb.Col, bool) {
+	for i := 0; i < len(t.Cols); i++ {
+		if t.Cols[i].Name == name {
+			return t.Cols[i], true
+		}
+	}
+	return qb.Col{}, false
+}
+
+// FromStruct creates a table description from a structure.
+// Only fields with the `db:"<column name>"` tag are used. Column settings are set with tags:
+//   - dbtype — data type, for example "varchar", "decimal(10,2)" or "enum(a,b)". If the tag is missing,
+//     the type is chosen by the type of the field.
+//   - dbsize — size of the data type, for example "100" or "10,2".
+//   - dbnull:"true" — the column can be NULL. Columns of pointer and sql.Null* fields can always be NULL.
+//   - dbpk:"true" — primary key.
+//   - dbai:"true" — auto increment.
+//   - dbindex:"<index name>" — the column is part of the index. If several columns have the same name, a composite index is created.
+//   - dbunique:"<index name>" — the same as dbindex, but the index is unique.
+//   - dbdefault — default value, for example dbdefault:"0" or dbdefault:"'text'".
+//   - dbfk:"<table>.<column>" — foreign key.
+func FromStruct(tableName string, model any) (*Table, error) {
+	modelType := reflect.TypeOf(model)
+	if modelType.Kind() == reflect.Pointer {
+		modelType = modelType.Elem()
+	}
+	if modelType.Kind() != reflect.Struct {
+		return nil, ErrModelNotStruct{Type: modelType.String()}
+	}
+	table := &Table{Name: tableName}
+	indexes := map[string]*Index{}
+	var indexNames []string
+	addIndex := func(name string, col string, unique bool) {
+		index, ok := indexes[name]
+		if !ok {
+			index = &Index{Name: name, Unique: unique}
+			indexes[name] = index
+			indexNames = append(indexNames, name)
+		}
+		index.Columns = append(index.Columns, col)
+	}
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		name := field.Tag.Get(namelib.TAGS.DB_MAPPER_NAME)
+		if name == "" {
+			continue
+		}
+		col, err := colFromField(name, &field)
+		if err != nil {
+			return nil, err
+		}
+		table.Cols = append(table.Cols, col)
+		if index := field.Tag.Get(namelib.TAGS.DB_SCHEMA_INDEX); index != "" {
+			addIndex(index, name, false)
+		}
+		if unique := field.Tag.Get(namelib.TAGS.DB_SCHEMA_UNIQUE); unique != "" {
+			addIndex(unique, name, true)
+		}
+		if fk := field.Tag.Get(namelib.TAGS.DB_SCHEMA_FK); fk != "" {
+			ref := strings.SplitN(fk, ".", 2)
+			if len(ref) != 2 {
+				return nil, ErrInvalidTag{Field: field.Name, Tag: namelib.TAGS.DB_SCHEMA_FK, Value: fk}
+			}
+			table.ForeignKeys = append(table.ForeignKeys, ForeignKey{
+				Name:      fmt.Sprintf("fk_%s_%s", tableName, name),
+				Column:    name,
+				RefTable:  ref[0],
+				RefColumn: ref[1],
+			})
+		}
+	}
+	for i := 0; i < len(indexNames); i++ {
+		table.Indexes = append(table.Indexes, *indexes[indexNames[i]])
+	}
+	return table, nil
+}
+
+// colFromField creates a column from the structure field and its tags.
+func colFromField(name string, field *reflect.StructField) (qb.Col, error) {
+	col := qb.Col{
+		Name: name,
+		Null: field.Tag.Get(namelib.TAGS.DB_SCHEMA_NULL) == "true",
+		PK:   field.Tag.Get(namelib.TAGS.DB_SCHEMA_PK) == "true",
+		AI:   field.Tag.Get(namelib.TAGS.DB_SCHEMA_AI) == "true",
+	}
+	if def := field.Tag.Get(namelib.TAGS.DB_SCHEMA_DEFAULT); def != "" {
+		col.Default = "DEFAULT " + def
+	}
+	fieldTypeName, nullable := typeNameByField(field.Type)
+	if nullable {
+		col.Null = true
+	}
+	typeName := field.Tag.Get(namelib.TAGS.DB_SCHEMA_TYPE)
+	if typeName == "" {
+		typeName = fieldTypeName
+	}
+	var params []string
+	if start := strings.Index(typeName, "("); start != -1 && strings.HasSuffix(typeName, ")") {
+		params = strings.Split(typeName[start+1:len(typeName)-1], ",")
+		typeName = typeName[:start]
+	}
+	if sizeTag := field.Tag.Get(namelib.TAGS.DB_SCHEMA_SIZE); sizeTag != "" {
+		params = strings.Split(sizeTag, ",")
+	}
+	for i := 0; i < len(params); i++ {
+		params[i] = strings.TrimSpace(params[i])
+	}
+	t, err := typeByName(strings.ToLower(strings.TrimSpace(typeName)), params)
+	if err != nil {
+		return qb.Col{}, ErrInvalidType{Field: field.Name, Err: err}
+	}
+	col.Type = t
+	return col, nil
+}
+
+var (
+	typeTime    = reflect.TypeOf(time.Time{})
+	typeBytes   = reflect.TypeOf([]byte{})
+	typeDecimal = reflect.TypeOf(decimal.Decimal{})
+)
+
+// nullTypeNames the data types of the sql.Null* types.
+var nullTypeNames = map[reflect.Type]string{
+	reflect.TypeOf(sql.NullString{}):      "varchar",
+	reflect.TypeOf(sql.NullInt64{}):       "bigint",
+	reflect.TypeOf(sql.NullInt32{}):       "int",
+	reflect.TypeOf(sql.NullInt16{}):       "int",
+	reflect.TypeOf(sql.NullByte{}):        "int",
+	reflect.TypeOf(sql.NullBool{}):        "boolean",
+	reflect.TypeOf(sql.NullTime{}):        "datetime",
+	reflect.TypeOf(decimal.NullDecimal{}): "decimal",
+}
+
+// typeNameByField selects the data type of the column by the type of the structure field.
+// Returns true if the field can hold NULL, that is, it is a pointer or a sql.Null* type.
+func typeNameByField(fieldType reflect.Type) (string, bool) {
+	if fieldType.Kind() == reflect.Pointer {
+		typeName, _ := typeNameByField(fieldType.Elem())
+		return typeName, true
+	}
+	if typeName, ok := nullTypeNames[fieldType]; ok {
+		return typeName, true
+	}
+	switch fieldType {
+	case typeTime:
+		return "datetime", false
+	case typeBytes:
+		return "blob", false
+	case typeDecimal:
+		return "decimal", false
+	}
+	switch fieldType.Kind() {
+	case reflect.Int64, reflect.Uint64:
+		return "bigint", false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "int", false
+	case reflect.String:
+		return "varchar", false
+	case reflect.Bool:
+		return "boolean", false
+	case reflect.Map:
+		return "json", false
+	default:
+		return "", false
+	}
+}
+
+// typeByName creates a data type by its name and parameters.
+func typeByName(name string, params []string) (qb.T, error) {
+	size := func(i int, def int) (int, error) {
+		if len(params) > i {
+			return strconv.Atoi(params[i])
+		}
+		return def, nil
+	}
+	switch name {
+	case "int":
+		n, err := size(0, 11)
+		if err != nil {
+			return qb.T{}, err
+		}
+		return qb.T{}.Int(n), nil
+	case "bigint":
+		return qb.T{}.BigInt(), nil
+	case "varchar":
+		n, err := size(0, 255)
+		if err != nil {
+			return qb.T{}, err
+		}
+		return qb.T{}.Varchar(n), nil
+	case "decimal":
+		precision, err := size(0, 10)
+		if err != nil {
+			return qb.T{}, err
+		}
+		scale, err := size(1, 2)
+		if err != nil {
+			return qb.T{}, err
+		}
+		return qb.T{}.Decimal(precision, scale), nil
+	case "text":
+		return qb.T{}.Text(), nil
+	case "date":
+		return qb.T{}.Date(), nil
+	case "datetime":
+		return qb.T{}.DateTime(), nil
+	case "timestamp":
+		return qb.T{}.Timestamp(), nil
+	case "boolean":
+		return qb.T{}.Boolean(), nil
+	case "json":
+		return qb.T{}.JSON(), nil
+	case "blob":
+		return qb.T{}.Blob(), nil
+	case "enum":
+		if len(params) == 0 {
+			return qb.T{}, fmt.Errorf("enum values are not specified")
+		}
+		return qb.T{}.Enum(params...), nil
+	case "uuid":
+		return qb.T{}.UUID(), nil
+	default:
+		return qb.T{}, fmt.Errorf("unknown type %s", name)
+	}
+}
+
+type ErrModelNotStruct struct {
+	Type string
+}
+
+func (e ErrModelNotStruct) Error() string {
+	return fmt.Sprintf("the model must be a structure, got %s", e.Type)
+}
+
+type ErrInvalidTag struct {
+	Field string
+	Tag   string
+	Value string
+}
+
+func (e ErrInvalidTag) Error() string {
+	return fmt.Sprintf("invalid value \"%s\" of the %s tag in the %s field", e.Value, e.Tag, e.Field)
+}
+
+type ErrInvalidType struct {
+	Field string
+	Err   error
+}
+
+func (e ErrInvalidType) Error() string {
+	return fmt.Sprintf("invalid column type of the %s field: %s", e.Field, e.Err.Error())
+}
+
+func (e ErrInvalidType) Unwrap() error {
+	return e.Err
+}
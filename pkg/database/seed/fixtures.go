@@ -0,0 +1,374 @@
+package seed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/database/schema"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture rows of one table.
+type Fixture struct {
+	Table string
+	Rows  []map[string]any
+}
+
+// ParseFixtures reads the fixtures from YAML or JSON data.
+// The data is a map where the key is the name of the table and the value is a list of rows:
+//
+//	users:
+//	  - id: 1
+//	    name: alice
+//	posts:
+//	  - id: 1
+//	    user_id: 1
+//
+// The order of the tables in the file is kept.
+func ParseFixtures(data []byte) ([]Fixture, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, ErrInvalidFixture{Reason: "the root must be a map of tables"}
+	}
+	fixtures := make([]Fixture, 0, len(doc.Content)/2)
+	for i := 0; i < len(doc.Content); i += 2 {
+		table := doc.Content[i].Value
+		if err := qb.CheckIdent(table); err != nil {
+			return nil, err
+		}
+		var rows []map[string]any
+		if err := doc.Content[i+1].Decode(&rows); err != nil {
+			return nil, ErrInvalidFixture{Reason: fmt.Sprintf("the %s table must contain a list of rows: %s", table, err.Error())}
+		}
+		for j := 0; j < len(rows); j++ {
+			for column := range rows[j] {
+				if err := qb.CheckIdent(column); err != nil {
+					return nil, err
+				}
+			}
+		}
+		fixtures = append(fixtures, Fixture{Table: table, Rows: rows})
+	}
+	return fixtures, nil
+}
+
+// LoadFile reads the fixtures from a .yaml, .yml or .json file.
+func LoadFile(path string) ([]Fixture, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil, ErrInvalidFixture{Reason: fmt.Sprintf("unsupported file %s", path)}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFixtures(data)
+}
+
+// LoadDir reads the fixtures from all .yaml, .yml and .json files of the directory.
+// The files are read in the order of their names.
+func LoadDir(dir string) ([]Fixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for i := 0; i < len(entries); i++ {
+		switch strings.ToLower(filepath.Ext(entries[i].Name())) {
+		case ".yaml", ".yml", ".json":
+			if !entries[i].IsDir() {
+				names = append(names, entries[i].Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	var fixtures []Fixture
+	for i := 0; i < len(names); i++ {
+		f, err := LoadFile(filepath.Join(dir, names[i]))
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, f...)
+	}
+	return fixtures, nil
+}
+
+// Loader loads the fixtures into the database.
+// The rows are inserted with [qb.BulkInsert], so the loader works with every driver.
+// The tables are cleared and filled in the order of their foreign keys, which are read from the database.
+type Loader struct {
+	db                 interfaces.SyncAsyncQuery
+	dialect            qb.Dialect
+	resetAutoIncrement bool
+}
+
+// NewLoader creates a loader. The db can be a database or a transaction.
+// If a database is passed, each loading is performed in a new transaction.
+// If a transaction is passed, the loading is part of it, so the test helper can roll it back.
+func NewLoader(db interfaces.SyncAsyncQuery, dialect qb.Dialect) *Loader {
+	return &Loader{db: db, dialect: dialect}
+}
+
+// ResetAutoIncrement enables the reset of the auto increment counters after loading and clearing,
+// so that they continue after the largest existing value.
+// IMPORTANT: in MySQL the counter is changed by the ALTER TABLE command, which implicitly commits
+// the transaction. Therefore, in MySQL the counters are reset after the loading transaction is committed,
+// and if the loader works with a transaction of the caller, the reset is skipped so that it can be rolled back.
+func (l *Loader) ResetAutoIncrement() *Loader {
+	l.resetAutoIncrement = true
+	return l
+}
+
+// LoadFiles loads the fixtures from the files.
+func (l *Loader) LoadFiles(paths ...string) error {
+	var fixtures []Fixture
+	for i := 0; i < len(paths); i++ {
+		f, err := LoadFile(paths[i])
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, f...)
+	}
+	return l.Load(fixtures...)
+}
+
+// Load clears the tables of the fixtures and inserts the rows.
+// The tables that are referenced by foreign keys are filled first and cleared last.
+func (l *Loader) Load(fixtures ...Fixture) error {
+	tables := []string{}
+	rows := map[string][]map[string]any{}
+	for i := 0; i < len(fixtures); i++ {
+		if err := qb.CheckIdent(fixtures[i].Table); err != nil {
+			return err
+		}
+		if _, ok := rows[fixtures[i].Table]; !ok {
+			tables = append(tables, fixtures[i].Table)
+		}
+		rows[fixtures[i].Table] = append(rows[fixtures[i].Table], fixtures[i].Rows...)
+	}
+	return l.apply(tables, func(syncQ interfaces.SyncQ, order []string) error {
+		if err := l.clear(syncQ, order); err != nil {
+			return err
+		}
+		for i := 0; i < len(order); i++ {
+			if err := l.insert(syncQ, order[i], rows[order[i]]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Truncate deletes all rows from the tables.
+// The DELETE command is used, so the clearing can be rolled back together with the transaction.
+func (l *Loader) Truncate(tables ...string) error {
+	for i := 0; i < len(tables); i++ {
+		if err := qb.CheckIdent(tables[i]); err != nil {
+			return err
+		}
+	}
+	return l.apply(tables, l.clear)
+}
+
+// apply sorts the tables by foreign keys and calls fn in the transaction.
+// If enabled, the auto increment counters are reset in the same transaction,
+// and for MySQL after the commit, because ALTER TABLE commits the transaction.
+func (l *Loader) apply(tables []string, fn func(syncQ interfaces.SyncQ, order []string) error) error {
+	var order []string
+	mysqlReset := l.resetAutoIncrement && l.dialect.Name() == qb.MYSQL.Name()
+	err := l.transaction(func(syncQ interfaces.SyncQ) error {
+		var err error
+		if order, err = l.sortTables(syncQ, tables); err != nil {
+			return err
+		}
+		if err := fn(syncQ, order); err != nil {
+			return err
+		}
+		if l.resetAutoIncrement && !mysqlReset {
+			return l.resetCounters(syncQ, order)
+		}
+		return nil
+	})
+	if err != nil || !mysqlReset {
+		return err
+	}
+	// The transaction of the caller must not be committed by the loader.
+	if _, ok := l.db.(interfaces.DatabaseInteraction); !ok {
+		return nil
+	}
+	return l.resetCounters(l.db.SyncQ(), order)
+}
+
+// transaction executes fn in a new transaction if the loader works with a database.
+func (l *Loader) transaction(fn func(syncQ interfaces.SyncQ) error) error {
+	if db, ok := l.db.(interfaces.DatabaseInteraction); ok {
+		return database.WithTransactionOptions(db, database.TxOptions{}, func(tx interfaces.DatabaseTransaction) error {
+			return fn(tx.SyncQ())
+		})
+	}
+	return fn(l.db.SyncQ())
+}
+
+// clear deletes the rows of the tables in reverse order.
+func (l *Loader) clear(syncQ interfaces.SyncQ, order []string) error {
+	for i := len(order) - 1; i >= 0; i-- {
+		if _, err := syncQ.Exec("DELETE FROM " + order[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert inserts the rows of the table. Rows with the same set of columns are inserted by one query.
+func (l *Loader) insert(syncQ interfaces.SyncQ, table string, rows []map[string]any) error {
+	for start := 0; start < len(rows); {
+		columns := dbutils.SortedKeys(rows[start])
+		end := start + 1
+		for end < len(rows) && sameColumns(columns, rows[end]) {
+			end++
+		}
+		values := make([][]any, 0, end-start)
+		for i := start; i < end; i++ {
+			row := make([]any, len(columns))
+			for j := 0; j < len(columns); j++ {
+				row[j] = rows[i][columns[j]]
+			}
+			values = append(values, row)
+		}
+		if _, err := qb.BulkInsert(syncQ, table, columns, values, qb.BulkOptions{Dialect: l.dialect}); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// resetCounters sets the auto increment counters of the tables so that
+// they continue after the largest existing value.
+func (l *Loader) resetCounters(syncQ interfaces.SyncQ, tables []string) error {
+	for i := 0; i < len(tables); i++ {
+		table := tables[i]
+		switch l.dialect.Name() {
+		case qb.MYSQL.Name():
+			// MySQL does not allow a value less than the largest one, so it is set to the next value.
+			if _, err := syncQ.Exec(fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT = 1", table)); err != nil {
+				return err
+			}
+		case qb.SQLITE.Name():
+			// Without a row in sqlite_sequence, sqlite uses the largest existing value.
+			res, err := syncQ.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'")
+			if err != nil {
+				return err
+			}
+			if len(res) == 0 {
+				continue
+			}
+			if _, err := syncQ.Exec("DELETE FROM sqlite_sequence WHERE name = ?", table); err != nil {
+				return err
+			}
+		case qb.POSTGRES.Name():
+			res, err := syncQ.Query(qb.Rebind(l.dialect, "SELECT column_name AS name FROM information_schema.columns "+
+				"WHERE table_schema = current_schema() AND table_name = ? AND column_default LIKE 'nextval%'"), table)
+			if err != nil {
+				return err
+			}
+			for j := 0; j < len(res); j++ {
+				column := fmt.Sprint(res[j]["name"])
+				query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence(?, ?), COALESCE(MAX(%s), 0) + 1, false) FROM %s", column, table)
+				if _, err := syncQ.Query(qb.Rebind(l.dialect, query), table, column); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sortTables sorts the tables so that the tables referenced by foreign keys come first.
+// Tables without dependencies keep their order. References to tables that are not in the list are ignored.
+func (l *Loader) sortTables(syncQ interfaces.SyncQ, tables []string) ([]string, error) {
+	deps := map[string][]string{}
+	for i := 0; i < len(tables); i++ {
+		refs, err := schema.ReadForeignKeys(syncQ, l.dialect, tables[i])
+		if err != nil {
+			return nil, err
+		}
+		deps[tables[i]] = refs
+	}
+	order := make([]string, 0, len(tables))
+	done := map[string]bool{}
+	for len(order) < len(tables) {
+		added := false
+		for i := 0; i < len(tables); i++ {
+			if done[tables[i]] || !depsDone(tables[i], deps, done) {
+				continue
+			}
+			done[tables[i]] = true
+			order = append(order, tables[i])
+			added = true
+		}
+		if !added {
+			rest := []string{}
+			for i := 0; i < len(tables); i++ {
+				if !done[tables[i]] {
+					rest = append(rest, tables[i])
+				}
+			}
+			return nil, ErrForeignKeyCycle{Tables: rest}
+		}
+	}
+	return order, nil
+}
+
+// depsDone returns true if all the tables that the table refers to are already processed.
+func depsDone(table string, deps map[string][]string, done map[string]bool) bool {
+	for _, ref := range deps[table] {
+		if _, ok := deps[ref]; ok && ref != table && !done[ref] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameColumns(columns []string, row map[string]any) bool {
+	if len(columns) != len(row) {
+		return false
+	}
+	for i := 0; i < len(columns); i++ {
+		if _, ok := row[columns[i]]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type ErrInvalidFixture struct {
+	Reason string
+}
+
+func (e ErrInvalidFixture) Error() string {
+	return fmt.Sprintf("invalid fixture: %s", e.Reason)
+}
+
+type ErrForeignKeyCycle struct {
+	Tables []string
+}
+
+func (e ErrForeignKeyCycle) Error() string {
+	return fmt.Sprintf("the foreign keys of the tables %s form a cycle", strings.Join(e.Tables, ", "))
+}
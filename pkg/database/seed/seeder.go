@@ -0,0 +1,114 @@
+package seed
+
+import (
+	"fmt"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// Seeder fills the database with data using go code.
+// The Run function receives the transaction in which the seeder is performed.
+type Seeder struct {
+	Name string
+	Run  func(tx interfaces.DatabaseTransaction) error
+}
+
+// Runner runs the seeders in the order in which they were added.
+// Each seeder is performed in a separate transaction.
+type Runner struct {
+	db      interfaces.DatabaseInteraction
+	dialect qb.Dialect
+	seeders []Seeder
+}
+
+func NewRunner(db interfaces.DatabaseInteraction, dialect qb.Dialect) *Runner {
+	return &Runner{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// Add adds seeders to the runner. The name of each seeder must be unique.
+func (r *Runner) Add(seeders ...Seeder) error {
+	for i := 0; i < len(seeders); i++ {
+		if _, ok := r.find(seeders[i].Name); ok {
+			return ErrDuplicateSeeder{Name: seeders[i].Name}
+		}
+		r.seeders = append(r.seeders, seeders[i])
+	}
+	return nil
+}
+
+// Seeders returns all added seeders.
+func (r *Runner) Seeders() []Seeder {
+	return append([]Seeder{}, r.seeders...)
+}
+
+// Loader creates a fixture loader for the database of the runner.
+func (r *Runner) Loader() *Loader {
+	return NewLoader(r.db, r.dialect)
+}
+
+// Run runs the seeders with the passed names. If no names are passed, all seeders are run.
+// Returns the names of the seeders that were performed successfully.
+func (r *Runner) Run(names ...string) ([]string, error) {
+	seeders := r.seeders
+	if len(names) > 0 {
+		seeders = make([]Seeder, len(names))
+		for i := 0; i < len(names); i++ {
+			seeder, ok := r.find(names[i])
+			if !ok {
+				return nil, ErrSeederNotFound{Name: names[i]}
+			}
+			seeders[i] = seeder
+		}
+	}
+	done := []string{}
+	for i := 0; i < len(seeders); i++ {
+		if err := database.WithTransactionOptions(r.db, database.TxOptions{}, seeders[i].Run); err != nil {
+			return done, ErrSeederFailed{Name: seeders[i].Name, Err: err}
+		}
+		done = append(done, seeders[i].Name)
+	}
+	return done, nil
+}
+
+func (r *Runner) find(name string) (Seeder, bool) {
+	for i := 0; i < len(r.seeders); i++ {
+		if r.seeders[i].Name == name {
+			return r.seeders[i], true
+		}
+	}
+	return Seeder{}, false
+}
+
+type ErrDuplicateSeeder struct {
+	Name string
+}
+
+func (e ErrDuplicateSeeder) Error() string {
+	return fmt.Sprintf("seeder %s already exists", e.Name)
+}
+
+type ErrSeederNotFound struct {
+	Name string
+}
+
+func (e ErrSeederNotFound) Error() string {
+	return fmt.Sprintf("seeder %s not found", e.Name)
+}
+
+type ErrSeederFailed struct {
+	Name string
+	Err  error
+}
+
+func (e ErrSeederFailed) Error() string {
+	return fmt.Sprintf("seeder %s failed: %s", e.Name, e.Err.Error())
+}
+
+func (e ErrSeederFailed) Unwrap() error {
+	return e.Err
+}
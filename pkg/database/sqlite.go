@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// SQLITE_MEMORY path that opens a database that exists only in memory.
+const SQLITE_MEMORY = ":memory:"
+
+// SqliteDatabase structure for accessing the sqlite database.
+// It works in the same way as [MysqlDatabase], but does not need
+// a running database server, so it is convenient for local development and tests.
+// The path can be a path to a database file or [SQLITE_MEMORY].
+//
+// IMPORTANT: an in-memory database exists only within one connection, so for [SQLITE_MEMORY]
+// the connection pool is limited to one connection. Because of this, while a transaction
+// is running, queries outside the transaction will wait for it to finish.
+// After the end of work it is necessary to close the connection using Close method.
+type SqliteDatabase struct {
+	path     string
+	db       *sql.DB
+	syncQ    interfaces.SyncQ
+	asyncQ   interfaces.AsyncQ
+	pool     PoolArgs
+	stmtSize int
+	stmts    *StmtCache
+}
+
+// NewSqliteDatabase creates a sqlite database for the path to a database file or [SQLITE_MEMORY].
+// The path can contain driver parameters after "?", for example "app.db?_busy_timeout=5000".
+// The connection is opened by the Open method.
+func NewSqliteDatabase(path string, syncQ interfaces.SyncQ, asyncQ interfaces.AsyncQ) *SqliteDatabase {
+	return &SqliteDatabase{
+		path:   path,
+		syncQ:  syncQ,
+		asyncQ: asyncQ,
+	}
+}
+
+// Open opens the sqlite database. If the database file does not exist, it will be created.
+// Foreign key support is enabled for each connection.
+func (d *SqliteDatabase) Open() error {
+	db, err := sql.Open("sqlite3", sqliteDSN(d.path))
+	if err != nil {
+		return err
+	}
+	d.pool.Apply(db)
+	if d.path == SQLITE_MEMORY {
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		return err
+	}
+	d.db = db
+	if d.stmtSize > 0 {
+		d.stmts = NewStmtCache(db, d.stmtSize)
+	}
+	d.syncQ.SetDB(&DbQuery{DB: db, Stmts: d.stmts})
+	return nil
+}
+
+// SetPool sets the settings of the connection pool. Must be called before the Open method.
+// For [SQLITE_MEMORY] the settings are ignored and the pool always keeps exactly one connection,
+// because closing it by ConnMaxLifetime or ConnMaxIdleTime would lose all the data.
+func (d *SqliteDatabase) SetPool(pool PoolArgs) {
+	if d.path == SQLITE_MEMORY {
+		pool = PoolArgs{MaxOpenConns: 1, MaxIdleConns: 1}
+	}
+	d.pool = pool
+}
+
+// EnableStmtCache enables caching of prepared statements with the given capacity.
+// Must be called before the Open method.
+func (d *SqliteDatabase) EnableStmtCache(capacity int) {
+	if capacity <= 0 {
+		capacity = DEFAULT_STMT_CACHE_SIZE
+	}
+	d.stmtSize = capacity
+}
+
+// StmtCache returns the cache of prepared statements.
+// If the cache is not enabled, nil is returned.
+func (d *SqliteDatabase) StmtCache() *StmtCache {
+	return d.stmts
+}
+
+// PingContext checks that the database is available.
+func (d *SqliteDatabase) PingContext(ctx context.Context) error {
+	if d.db == nil {
+		return ErrConnectionNotOpen{}
+	}
+	return d.db.PingContext(ctx)
+}
+
+// Stats returns the statistics of the connection pool.
+func (d *SqliteDatabase) Stats() sql.DBStats {
+	if d.db == nil {
+		return sql.DBStats{}
+	}
+	return d.db.Stats()
+}
+
+// Close closes the connection to the database.
+// For an in-memory database all data is lost.
+func (d *SqliteDatabase) Close() error {
+	if d.db == nil {
+		return ErrConnectionNotOpen{}
+	}
+	if d.stmts != nil {
+		d.stmts.Clear()
+	}
+	return d.db.Close()
+}
+
+// NewTransaction creates a new transaction instance.
+// The [MysqlTransaction] object is used because it works with any [*sql.DB].
+func (d *SqliteDatabase) NewTransaction() (interfaces.DatabaseTransaction, error) {
+	tx, err := NewMysqlTransaction(d.db, d.syncQ, d.asyncQ)
+	if err != nil {
+		return nil, err
+	}
+	tx.SetStmtCache(d.stmts)
+	return tx, nil
+}
+
+// SyncQ getting access to synchronous requests.
+func (d *SqliteDatabase) SyncQ() interfaces.SyncQ {
+	return d.syncQ
+}
+
+func (d *SqliteDatabase) NewAsyncQ() (interfaces.AsyncQ, error) {
+	aq, err := d.asyncQ.New()
+	if err != nil {
+		return nil, err
+	}
+	return aq.(interfaces.AsyncQ), nil
+}
+
+// sqliteDSN adds the connection parameters to the database path.
+// If the path already contains parameters, the new ones are appended to them.
+func sqliteDSN(path string) string {
+	if path == SQLITE_MEMORY {
+		return "file::memory:?_foreign_keys=on"
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return "file:" + path + separator + "_foreign_keys=on"
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+)
+
+// DEFAULT_STMT_CACHE_SIZE the number of prepared statements that are stored
+// in the cache if the size is not specified.
+const DEFAULT_STMT_CACHE_SIZE = 100
+
+// StmtCacheStats statistics of the prepared statement cache.
+type StmtCacheStats struct {
+	// Number of statements in the cache.
+	Size int
+	// Maximum number of statements in the cache.
+	Capacity int
+	// Number of queries for which a statement was found in the cache.
+	Hits uint64
+	// Number of queries for which a new statement was prepared.
+	Misses uint64
+	// Number of statements removed because the cache is full.
+	Evictions uint64
+	// Number of statements removed because of connection errors.
+	Invalidations uint64
+}
+
+type stmtEntry struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	removed bool
+}
+
+// StmtCache a cache of prepared statements. The key is the text of the sql query.
+// When the cache is full, the least recently used statement is removed.
+// If the query fails with the [driver.ErrBadConn] error, the statement is removed
+// from the cache and the query is repeated with a new statement.
+//
+// The cache is safe for concurrent use. A removed statement is closed only
+// after all queries that use it have finished.
+type StmtCache struct {
+	mu            sync.Mutex
+	db            *sql.DB
+	capacity      int
+	list          *list.List
+	items         map[string]*list.Element
+	hits          uint64
+	misses        uint64
+	evictions     uint64
+	invalidations uint64
+}
+
+// NewStmtCache creates a cache that stores up to capacity statements.
+// If capacity is not greater than zero, [DEFAULT_STMT_CACHE_SIZE] is used.
+func NewStmtCache(db *sql.DB, capacity int) *StmtCache {
+	if capacity <= 0 {
+		capacity = DEFAULT_STMT_CACHE_SIZE
+	}
+	return &StmtCache{
+		db:       db,
+		capacity: capacity,
+		list:     list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+// Stats returns the current statistics of the cache.
+func (c *StmtCache) Stats() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return StmtCacheStats{
+		Size:          c.list.Len(),
+		Capacity:      c.capacity,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Evictions:     c.evictions,
+		Invalidations: c.invalidations,
+	}
+}
+
+// Invalidate removes the statement of the query from the cache.
+func (c *StmtCache) Invalidate(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[query]; ok {
+		c.remove(el)
+		c.invalidations++
+	}
+}
+
+// Clear removes all statements from the cache.
+func (c *StmtCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.list.Front(); el != nil; el = c.list.Front() {
+		c.remove(el)
+	}
+}
+
+// use executes fn with the prepared statement of the query.
+// If fn returns [driver.ErrBadConn], the statement is invalidated and fn is called once more with a new statement.
+func (c *StmtCache) use(ctx context.Context, query string, fn func(stmt *sql.Stmt) error) error {
+	err := c.useOnce(ctx, query, fn)
+	if errors.Is(err, driver.ErrBadConn) {
+		c.Invalidate(query)
+		return c.useOnce(ctx, query, fn)
+	}
+	return err
+}
+
+func (c *StmtCache) useOnce(ctx context.Context, query string, fn func(stmt *sql.Stmt) error) error {
+	entry, err := c.acquire(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer c.release(entry)
+	return fn(entry.stmt)
+}
+
+// acquire returns the statement of the query. If it is not in the cache, a new statement is prepared.
+// After use, the statement must be released using the release method.
+func (c *StmtCache) acquire(ctx context.Context, query string) (*stmtEntry, error) {
+	c.mu.Lock()
+	if el, ok := c.items[query]; ok {
+		c.hits++
+		entry := c.take(el)
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// The statement is prepared without a lock so as not to block other queries.
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The same statement may have been prepared by another query.
+	if el, ok := c.items[query]; ok {
+		go stmt.Close()
+		return c.take(el), nil
+	}
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.list.PushFront(entry)
+	for c.list.Len() > c.capacity {
+		c.remove(c.list.Back())
+		c.evictions++
+	}
+	return entry, nil
+}
+
+// get returns the cached statement of the query without preparing a new one.
+// After use, the statement must be released using the release method.
+func (c *StmtCache) get(query string) (*stmtEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[query]; ok {
+		c.hits++
+		return c.take(el), true
+	}
+	c.misses++
+	return nil, false
+}
+
+func (c *StmtCache) take(el *list.Element) *stmtEntry {
+	c.list.MoveToFront(el)
+	entry := el.Value.(*stmtEntry)
+	entry.refs++
+	return entry
+}
+
+func (c *StmtCache) release(entry *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.removed && entry.refs == 0 {
+		closeStmt(entry.stmt)
+	}
+}
+
+// remove removes the element from the cache. The statement is closed if it is not in use.
+func (c *StmtCache) remove(el *list.Element) {
+	entry := el.Value.(*stmtEntry)
+	c.list.Remove(el)
+	delete(c.items, entry.query)
+	entry.removed = true
+	if entry.refs == 0 {
+		closeStmt(entry.stmt)
+	}
+}
+
+// closeStmt closes the statement in a separate goroutine, because [sql.Stmt.Close]
+// waits until all rows received from the statement are closed.
+func closeStmt(stmt *sql.Stmt) {
+	go stmt.Close()
+}
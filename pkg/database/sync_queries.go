@@ -1,33 +1,237 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
 	"github.com/uwine4850/foozy/pkg/interfaces"
 )
 
 type SyncQueries struct {
-	qe interfaces.QueryExec
+	qe    interfaces.QueryExec
+	hooks []QueryHook
+	cache *QueryCache
+	// txTables tables changed in the transaction, they are invalidated again after the commit.
+	txTables []string
+	// txClear the transaction changed tables that could not be determined.
+	txClear bool
+	retry   RetryOptions
+}
+
+// RetryOptions settings of the repeated reading queries, see [SyncQueries.SetRetry].
+type RetryOptions struct {
+	// MaxRetries the number of repeated attempts if the query failed due to a connection error.
+	// 0 disables the retries.
+	MaxRetries int
+	// BaseDelay the delay before the first retry. Each next delay is doubled.
+	BaseDelay time.Duration
+	// MaxDelay the maximum delay between retries.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryOptions recommended settings of the repeated reading queries.
+var DefaultRetryOptions = RetryOptions{
+	MaxRetries: 3,
+	BaseDelay:  50 * time.Millisecond,
+	MaxDelay:   2 * time.Second,
 }
 
 func NewSyncQueries() *SyncQueries {
 	return &SyncQueries{}
 }
 
+// New creates a new instance with the same database object, hooks, cache and retry settings.
 func (q *SyncQueries) New() (interface{}, error) {
 	return &SyncQueries{
-		qe: q.qe,
+		qe:    q.qe,
+		hooks: append([]QueryHook(nil), q.hooks...),
+		cache: q.cache,
+		retry: q.retry,
 	}, nil
 }
 
+// AddHook adds hooks that are called before and after each query.
+// Instances created with the New method, for example for transactions, inherit the hooks.
+// Must be called before the queries are executed.
+func (q *SyncQueries) AddHook(hooks ...QueryHook) {
+	q.hooks = append(q.hooks, hooks...)
+}
+
+// SetCache enables the cache of query results. More details in [QueryCache].
+// Instances created with the New method, for example for transactions, use the same cache.
+// Must be called before the queries are executed.
+func (q *SyncQueries) SetCache(cache *QueryCache) {
+	q.cache = cache
+}
+
+// SetRetry enables the repetition of the reading queries that failed due to a connection error,
+// for example when the database server is restarted. More details about the errors in [IsTransientError].
+// Only SELECT and WITH queries outside a transaction are repeated, because they do not change data.
+// Instances created with the New method inherit the settings.
+// Must be called before the queries are executed.
+func (q *SyncQueries) SetRetry(opts RetryOptions) {
+	q.retry = opts
+}
+
 // Query wrapper for the IDbQuery.Query method.
+// If the cache is set with a ttl, the result of the SELECT query is taken from the cache.
 func (q *SyncQueries) Query(query string, args ...any) ([]map[string]interface{}, error) {
-	return q.qe.Query(query, args...)
+	if q.cache != nil && q.cache.TTL() > 0 {
+		return q.QueryCache(q.cache.TTL(), query, args...)
+	}
+	return q.query(query, args...)
+}
+
+// QueryCache works like Query, but the result of the SELECT query is taken from the cache if it is there.
+// The result is stored in the cache for ttl. Without the cache and inside a transaction the query is executed as usual.
+func (q *SyncQueries) QueryCache(ttl time.Duration, query string, args ...any) ([]map[string]interface{}, error) {
+	if q.cache == nil || ttl <= 0 || q.inTransaction() || !cacheableQuery(query) {
+		return q.query(query, args...)
+	}
+	return q.cache.Fetch(ttl, query, args, func() ([]map[string]interface{}, error) {
+		return q.query(query, args...)
+	})
+}
+
+func (q *SyncQueries) query(query string, args ...any) ([]map[string]interface{}, error) {
+	var res []map[string]interface{}
+	err := q.retryRead(query, func() error {
+		if len(q.hooks) == 0 {
+			var err error
+			res, err = q.qe.Query(query, args...)
+			return err
+		}
+		return q.runHooks(context.Background(), query, args, false, func() (int64, error) {
+			var err error
+			res, err = q.qe.Query(query, args...)
+			return int64(len(res)), err
+		})
+	})
+	return res, err
+}
+
+// QueryRows returns the raw rows of the query.
+// The database object must implement the [interfaces.RowsQuery] interface.
+func (q *SyncQueries) QueryRows(query string, args ...any) (*sql.Rows, error) {
+	return q.QueryRowsContext(context.Background(), query, args...)
+}
+
+// QueryRowsContext returns the raw rows of the query bound to the context.
+// The database object must implement the [interfaces.RowsQuery] interface.
+// For hooks, the number of rows is unknown, so it is equal to -1.
+func (q *SyncQueries) QueryRowsContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rowsQuery, ok := q.qe.(interfaces.RowsQuery)
+	if !ok {
+		return nil, ErrRowsQueryNotSupported{}
+	}
+	var rows *sql.Rows
+	err := q.retryRead(query, func() error {
+		if len(q.hooks) == 0 {
+			var err error
+			rows, err = rowsQuery.QueryRowsContext(ctx, query, args...)
+			return err
+		}
+		return q.runHooks(ctx, query, args, false, func() (int64, error) {
+			var err error
+			rows, err = rowsQuery.QueryRowsContext(ctx, query, args...)
+			return -1, err
+		})
+	})
+	return rows, err
+}
+
+// QueryIter executes the query and returns a cursor that reads the rows one by one.
+// The rows are closed when the context is canceled.
+// IMPORTANT: the cursor must be closed after use.
+func (q *SyncQueries) QueryIter(ctx context.Context, query string, args ...any) (*dbutils.Cursor, error) {
+	rows, err := q.QueryRowsContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return dbutils.NewCursor(ctx, rows), nil
 }
 
 // Exec wrapper for the IDbQuery.Exec method.
+// If the cache is set, the cached results of the changed tables become stale.
 func (q *SyncQueries) Exec(query string, args ...any) (map[string]interface{}, error) {
-	return q.qe.Exec(query, args...)
+	res, err := q.exec(query, args...)
+	if err == nil && q.cache != nil {
+		tables := QueryTables(query)
+		q.cache.invalidateQuery(tables)
+		if q.inTransaction() {
+			q.txTables = append(q.txTables, tables...)
+			q.txClear = q.txClear || len(tables) == 0
+		}
+	}
+	return res, err
+}
+
+func (q *SyncQueries) exec(query string, args ...any) (map[string]interface{}, error) {
+	if len(q.hooks) == 0 {
+		return q.qe.Exec(query, args...)
+	}
+	var res map[string]interface{}
+	err := q.runHooks(context.Background(), query, args, true, func() (int64, error) {
+		var err error
+		res, err = q.qe.Exec(query, args...)
+		return dbutils.RowsAffected(res), err
+	})
+	return res, err
+}
+
+// retryRead executes fn and repeats it on connection errors if the retries are enabled
+// and the query only reads data outside a transaction.
+func (q *SyncQueries) retryRead(query string, fn func() error) error {
+	if q.retry.MaxRetries <= 0 || q.inTransaction() || !cacheableQuery(query) {
+		return fn()
+	}
+	return retry(q.retry.MaxRetries, q.retry.BaseDelay, q.retry.MaxDelay, IsTransientError, fn)
+}
+
+// inTransaction returns true if the queries are executed by a transaction.
+func (q *SyncQueries) inTransaction() bool {
+	_, ok := q.qe.(*DbTxQuery)
+	return ok
+}
+
+// endTransaction is called after the end of the transaction. After the commit, the tables changed
+// by the transaction are invalidated again, because other queries could cache the old data before the commit.
+func (q *SyncQueries) endTransaction(committed bool) {
+	if committed && q.cache != nil {
+		if q.txClear {
+			q.cache.Clear()
+		} else if len(q.txTables) > 0 {
+			q.cache.Invalidate(q.txTables...)
+		}
+	}
+	q.txTables = nil
+	q.txClear = false
 }
 
 func (q *SyncQueries) SetDB(qe interfaces.QueryExec) {
 	q.qe = qe
 }
+
+// runHooks executes the query between the BeforeQuery and AfterQuery calls of the hooks.
+// AfterQuery is called in reverse order.
+func (q *SyncQueries) runHooks(ctx context.Context, query string, args []any, exec bool, fn func() (int64, error)) error {
+	event := &QueryEvent{Query: query, Args: args, Exec: exec}
+	for i := 0; i < len(q.hooks); i++ {
+		ctx = q.hooks[i].BeforeQuery(ctx, event)
+	}
+	start := time.Now()
+	event.Rows, event.Err = fn()
+	event.Duration = time.Since(start)
+	for i := len(q.hooks) - 1; i >= 0; i-- {
+		q.hooks[i].AfterQuery(ctx, event)
+	}
+	return event.Err
+}
+
+type ErrRowsQueryNotSupported struct{}
+
+func (e ErrRowsQueryNotSupported) Error() string {
+	return "the database object does not support raw rows queries"
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+// TxOptions settings of the [WithTransactionOptions] function.
+type TxOptions struct {
+	// MaxRetries the number of repeated attempts if the transaction failed
+	// due to a deadlock or serialization error.
+	MaxRetries int
+	// BaseDelay the delay before the first retry. Each next delay is doubled.
+	BaseDelay time.Duration
+	// MaxDelay the maximum delay between retries.
+	MaxDelay time.Duration
+}
+
+// DefaultTxOptions the settings used by the [WithTransaction] function.
+var DefaultTxOptions = TxOptions{
+	MaxRetries: 3,
+	BaseDelay:  10 * time.Millisecond,
+	MaxDelay:   time.Second,
+}
+
+// WithTransaction executes fn in a new transaction with the [DefaultTxOptions] settings.
+// More details in the [WithTransactionOptions] function.
+func WithTransaction(db interfaces.DatabaseInteraction, fn func(tx interfaces.DatabaseTransaction) error) error {
+	return WithTransactionOptions(db, DefaultTxOptions, fn)
+}
+
+// WithTransactionOptions executes fn in a new transaction.
+// If fn returns nil, the transaction is committed. If fn returns an error or panics,
+// the transaction is rolled back; the panic is passed on after the rollback.
+// If the transaction failed due to a deadlock or serialization error, it is repeated
+// from the beginning with an exponential delay, so fn must be safe to call again.
+func WithTransactionOptions(db interfaces.DatabaseInteraction, opts TxOptions, fn func(tx interfaces.DatabaseTransaction) error) error {
+	return retry(opts.MaxRetries, opts.BaseDelay, opts.MaxDelay, IsRetryableTxError, func() error {
+		return runTransaction(db, fn)
+	})
+}
+
+// retry calls fn until it succeeds or returns an error that cannot be retried.
+// The delay between attempts starts with baseDelay and is doubled each time, but not more than maxDelay.
+func retry(maxRetries int, baseDelay time.Duration, maxDelay time.Duration, retryable func(err error) bool, fn func() error) error {
+	delay := baseDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= maxRetries || !retryable(err) {
+			return err
+		}
+		// Jitter so that competing attempts do not repeat at the same time.
+		sleep := delay
+		if delay > 0 {
+			sleep += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		}
+		time.Sleep(sleep)
+		delay *= 2
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+func runTransaction(db interfaces.DatabaseInteraction, fn func(tx interfaces.DatabaseTransaction) error) error {
+	tx, err := db.NewTransaction()
+	if err != nil {
+		return err
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		return err
+	}
+	return completeTransaction(tx, func() error { return fn(tx) })
+}
+
+// WithSavepoint executes fn in a nested transaction of an already started transaction.
+// If fn returns an error or panics, only the changes made by fn are undone.
+func WithSavepoint(tx interfaces.DatabaseTransaction, fn func() error) error {
+	if err := tx.BeginTransaction(); err != nil {
+		return err
+	}
+	return completeTransaction(tx, fn)
+}
+
+// completeTransaction executes fn, then commits or rolls back the transaction.
+func completeTransaction(tx interfaces.DatabaseTransaction, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			tx.RollBackTransaction()
+			panic(r)
+		}
+	}()
+	if err := fn(); err != nil {
+		if rollbackErr := tx.RollBackTransaction(); rollbackErr != nil {
+			return errors.Join(err, ErrRollback{Err: rollbackErr})
+		}
+		return err
+	}
+	if err := tx.CommitTransaction(); err != nil {
+		tx.RollBackTransaction()
+		return err
+	}
+	return nil
+}
+
+// IsRetryableTxError returns true if the transaction failed due to a deadlock
+// or serialization error and can be repeated.
+// MySQL errors 1213 and 1205, SQLSTATE 40001 and 40P01 and sqlite busy errors are supported.
+func IsRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		state := stateErr.SQLState()
+		return state == "40001" || state == "40P01"
+	}
+	return false
+}
+
+type ErrRollback struct {
+	Err error
+}
+
+func (e ErrRollback) Error() string {
+	return fmt.Sprintf("rollback error: %s", e.Err.Error())
+}
+
+func (e ErrRollback) Unwrap() error {
+	return e.Err
+}
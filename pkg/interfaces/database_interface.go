@@ -1,6 +1,10 @@
 package interfaces
 
 import (
+	"context"
+	"database/sql"
+	"time"
+
 	"github.com/uwine4850/foozy/pkg/database/dbutils"
 	"github.com/uwine4850/foozy/pkg/interfaces/itypeopr"
 )
@@ -54,12 +58,43 @@ type QueryExec interface {
 	Exec(query string, args ...any) (map[string]interface{}, error)
 }
 
+// RowsQuery an interface represents an object that can return raw query rows.
+// It is used when the rows need to be processed without intermediate maps.
+type RowsQuery interface {
+	// QueryRows executes the query and returns the rows as is.
+	// IMPORTANT: the rows must be closed after use.
+	QueryRows(query string, args ...any) (*sql.Rows, error)
+	// QueryRowsContext works like QueryRows, but the query is bound to the context.
+	// When the context is canceled, the rows are closed.
+	QueryRowsContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// CacheQuery an interface represents an object that can cache the results of reading queries.
+type CacheQuery interface {
+	// QueryCache works like Query, but the result is taken from the cache if it is there.
+	// The result is stored in the cache for ttl.
+	QueryCache(ttl time.Duration, query string, args ...any) ([]map[string]interface{}, error)
+}
+
+// IterQuery an interface represents an object that can read the query rows one by one.
+type IterQuery interface {
+	// QueryIter executes the query and returns a cursor that reads the rows one by one.
+	// IMPORTANT: the cursor must be closed after use.
+	QueryIter(ctx context.Context, query string, args ...any) (*dbutils.Cursor, error)
+}
+
 type SyncQ interface {
 	itypeopr.NewInstance
 	QueryExec
 	SetDB(db QueryExec)
 }
 
+// AsyncErrQuery an interface represents asynchronous queries that can report the errors of the queries.
+type AsyncErrQuery interface {
+	// WaitErr waits for the queries and returns the first error.
+	WaitErr() error
+}
+
 type AsyncQ interface {
 	itypeopr.NewInstance
 	SetSyncQueries(queries SyncQ)
@@ -1,6 +1,7 @@
 package mapper
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -41,12 +42,40 @@ var (
 	typeMap     = reflect.TypeOf(map[string]interface{}{})
 	typeBytes   = reflect.TypeOf([]byte{})
 	typeDecimal = reflect.TypeOf(decimal.Decimal{})
+	typeScanner = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
 )
 
+// isNullType returns true for the types that have the form of [sql.NullString]: a structure that implements
+// [sql.Scanner] and consists of the value and the Valid field. These are all sql.Null* types,
+// the generic sql.Null[T] and, for example, decimal.NullDecimal.
+func isNullType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t.NumField() != 2 || !reflect.PointerTo(t).Implements(typeScanner) {
+		return false
+	}
+	valid := t.Field(1)
+	return valid.Name == "Valid" && valid.Type.Kind() == reflect.Bool
+}
+
+// isNullable returns true if the field can store NULL: pointers and the [isNullType] types.
+func isNullable(t reflect.Type) bool {
+	return t.Kind() == reflect.Pointer || isNullType(t)
+}
+
 func (dc DatabaseConverter) convertDBType(value *reflect.Value, structTag *reflect.StructTag, dataPtr *any) error {
 	data := *dataPtr
+	if value.Kind() == reflect.Pointer {
+		return dc.convertPointer(value, structTag, dataPtr)
+	}
+	if isNullType(value.Type()) {
+		return dc.convertNull(value, structTag, dataPtr)
+	}
 	switch value.Type() {
 	case typeTime:
+		// Some drivers, such as sqlite, already return the time.Time type.
+		if t, ok := data.(time.Time); ok {
+			value.Set(reflect.ValueOf(t))
+			return nil
+		}
 		val, err := dc.dbValueConversionToByte(data)
 		if err != nil {
 			return err
@@ -64,7 +93,11 @@ func (dc DatabaseConverter) convertDBType(value *reflect.Value, structTag *refle
 		value.SetBool(cVal)
 	case typeMap:
 		v := map[string]interface{}{}
-		if err := json.Unmarshal(data.([]byte), &v); err != nil {
+		val, err := dc.dbValueConversionToByte(data)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(val, &v); err != nil {
 			return err
 		}
 		value.Set(reflect.ValueOf(v))
@@ -84,6 +117,34 @@ func (dc DatabaseConverter) convertDBType(value *reflect.Value, structTag *refle
 	return nil
 }
 
+// convertPointer sets nil for NULL, otherwise converts the value to the type of the pointer element.
+func (dc DatabaseConverter) convertPointer(value *reflect.Value, structTag *reflect.StructTag, dataPtr *any) error {
+	if *dataPtr == nil {
+		value.Set(reflect.Zero(value.Type()))
+		return nil
+	}
+	elem := reflect.New(value.Type().Elem()).Elem()
+	if err := dc.convertDBType(&elem, structTag, dataPtr); err != nil {
+		return err
+	}
+	value.Set(elem.Addr())
+	return nil
+}
+
+// convertNull sets Valid=false for NULL, otherwise converts the value to the type of the first field and sets Valid=true.
+func (dc DatabaseConverter) convertNull(value *reflect.Value, structTag *reflect.StructTag, dataPtr *any) error {
+	if *dataPtr == nil {
+		value.Set(reflect.Zero(value.Type()))
+		return nil
+	}
+	inner := value.Field(0)
+	if err := dc.convertDBType(&inner, structTag, dataPtr); err != nil {
+		return err
+	}
+	value.Field(1).SetBool(true)
+	return nil
+}
+
 func (dc DatabaseConverter) convertTimeF(val *[]byte, dateFormat string) (time.Time, error) {
 	if dateFormat != "" {
 		parsedTime, err := time.Parse(dateFormat, string(*val))
@@ -119,8 +180,11 @@ func (dc DatabaseConverter) convertBoolean(dataPtr *any) (bool, error) {
 }
 
 func (dc DatabaseConverter) convertDecimal(dataPtr *any) (decimal.Decimal, error) {
-	data := *dataPtr
-	decimalString := string(data.([]byte))
+	val, err := dc.dbValueConversionToByte(*dataPtr)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	decimalString := string(val)
 	newDecimal, err := decimal.NewFromString(decimalString)
 	if err != nil {
 		return decimal.Decimal{}, err
@@ -45,34 +45,47 @@ func FillStructFromDb[T any](fillStruct *T, dbRes *map[string]interface{}) error
 		field := v.FieldByName(f.Name)
 		data, ok := (*dbRes)[name]
 		if ok {
-			// Processing DB_MAPPER_EMPTY tag.
-			if data == nil {
-				emptyVal := f.Tag.Get(namelib.TAGS.DB_MAPPER_EMPTY)
-				if emptyVal != "" {
-					if emptyVal == "-error" {
-						return typeopr.ErrValueIsEmpty{Value: name}
-					}
-					newByteData, err := DC.dbValueConversionToByte(emptyVal)
-					if err != nil {
-						return err
-					}
-					newData := reflect.ValueOf(newByteData).Interface()
-					if err := DC.convertDBType(&field, &f.Tag, &newData); err != nil {
-						return err
-					}
-				}
-			} else {
-				if err := DC.convertDBType(&field, &f.Tag, &data); err != nil {
-					return err
-				}
+			if err := fillDbField(&field, name, f.Tag, data); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+// fillDbField writes the database value to the structure field.
+// If the value is nil, the DB_MAPPER_EMPTY tag is processed. Without the tag,
+// a nullable field (pointer or sql.Null* type) is set to nil or Valid=false.
+func fillDbField(field *reflect.Value, name string, tag reflect.StructTag, data any) error {
+	// Processing DB_MAPPER_EMPTY tag.
+	if data == nil {
+		emptyVal := tag.Get(namelib.TAGS.DB_MAPPER_EMPTY)
+		if emptyVal == "" && isNullable(field.Type()) {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		if emptyVal != "" {
+			if emptyVal == "-error" {
+				return typeopr.ErrValueIsEmpty{Value: name}
+			}
+			newByteData, err := DC.dbValueConversionToByte(emptyVal)
+			if err != nil {
+				return err
+			}
+			newData := reflect.ValueOf(newByteData).Interface()
+			if err := DC.convertDBType(field, &tag, &newData); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return DC.convertDBType(field, &tag, &data)
+}
+
 // ParamsValueFromDbStruct creates a map from a structure that describes the table.
 // To work correctly, you need a completed structure, and the required fields must have the `db:"<column name>"` tag.
+// A nil pointer becomes NULL, a non-nil pointer is replaced by its value.
+// The sql.Null* types are passed as is, the database driver converts them to NULL if Valid is false.
 func ParamsValueFromDbStruct(filledStructurePtr typeopr.IPtr, nilIfEmpty []string) (map[string]any, error) {
 	structure := filledStructurePtr.Ptr()
 	if !typeopr.PtrIsStruct(structure) {
@@ -90,6 +103,12 @@ func ParamsValueFromDbStruct(filledStructurePtr typeopr.IPtr, nilIfEmpty []strin
 		}
 		if fslice.SliceContains(nilIfEmpty, dbColName) && fieldValue.IsZero() {
 			outputParamsMap[dbColName] = nil
+		} else if fieldValue.Kind() == reflect.Pointer {
+			if fieldValue.IsNil() {
+				outputParamsMap[dbColName] = nil
+			} else {
+				outputParamsMap[dbColName] = fieldValue.Elem().Interface()
+			}
 		} else {
 			outputParamsMap[dbColName] = fieldValue.Interface()
 		}
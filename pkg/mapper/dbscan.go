@@ -0,0 +1,222 @@
+package mapper
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/uwine4850/foozy/pkg/namelib"
+	"github.com/uwine4850/foozy/pkg/typeopr"
+)
+
+// scanPlanCache stores scan plans.
+// Key - scanPlanKey.
+// Value - *scanPlan.
+var scanPlanCache sync.Map
+
+type scanPlanKey struct {
+	typ     reflect.Type
+	columns string
+}
+
+// scanPlan describes how each column of the query result is written to the structure.
+// The plan depends on the structure type and the list of columns, so it is created
+// once for each query form and then taken from the cache.
+type scanPlan struct {
+	// Index of the field for each column. If the column has no field, the index is -1.
+	fields []int
+	tags   []reflect.StructTag
+}
+
+// loadScanPlan loads the plan from the cache or creates a new one.
+func loadScanPlan(typ reflect.Type, columns []string) *scanPlan {
+	key := scanPlanKey{typ: typ, columns: strings.Join(columns, ",")}
+	if plan, ok := scanPlanCache.Load(key); ok {
+		return plan.(*scanPlan)
+	}
+	byName := map[string]reflect.StructField{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if name := field.Tag.Get(namelib.TAGS.DB_MAPPER_NAME); name != "" {
+			byName[name] = field
+		}
+	}
+	plan := &scanPlan{
+		fields: make([]int, len(columns)),
+		tags:   make([]reflect.StructTag, len(columns)),
+	}
+	for i := 0; i < len(columns); i++ {
+		field, ok := byName[columns[i]]
+		if !ok {
+			plan.fields[i] = -1
+			continue
+		}
+		plan.fields[i] = field.Index[0]
+		plan.tags[i] = field.Tag
+	}
+	scanPlanCache.Store(key, plan)
+	return plan
+}
+
+// columnDest the destination of one column during scanning.
+// The destination is created once for the whole query and reused for each row.
+type columnDest interface {
+	// ptr pointer that is passed to [sql.Rows.Scan].
+	ptr() any
+	// set writes the scanned value to the structure field.
+	set(field reflect.Value) error
+}
+
+type stringDest struct{ v sql.NullString }
+
+func (d *stringDest) ptr() any { return &d.v }
+func (d *stringDest) set(field reflect.Value) error {
+	if d.v.Valid {
+		field.SetString(d.v.String)
+	}
+	return nil
+}
+
+type intDest struct{ v sql.NullInt64 }
+
+func (d *intDest) ptr() any { return &d.v }
+func (d *intDest) set(field reflect.Value) error {
+	if d.v.Valid {
+		if field.OverflowInt(d.v.Int64) {
+			return ErrValueOverflow{Value: strconv.FormatInt(d.v.Int64, 10), Type: field.Type().String()}
+		}
+		field.SetInt(d.v.Int64)
+	}
+	return nil
+}
+
+// uintDest scans the value through *uint64, so that values greater than math.MaxInt64 are not lost.
+// The pointer is nil if the value is NULL.
+type uintDest struct{ v *uint64 }
+
+func (d *uintDest) ptr() any { return &d.v }
+func (d *uintDest) set(field reflect.Value) error {
+	if d.v != nil {
+		if field.OverflowUint(*d.v) {
+			return ErrValueOverflow{Value: strconv.FormatUint(*d.v, 10), Type: field.Type().String()}
+		}
+		field.SetUint(*d.v)
+	}
+	return nil
+}
+
+type floatDest struct{ v sql.NullFloat64 }
+
+func (d *floatDest) ptr() any { return &d.v }
+func (d *floatDest) set(field reflect.Value) error {
+	if d.v.Valid {
+		field.SetFloat(d.v.Float64)
+	}
+	return nil
+}
+
+type boolDest struct{ v sql.NullBool }
+
+func (d *boolDest) ptr() any { return &d.v }
+func (d *boolDest) set(field reflect.Value) error {
+	if d.v.Valid {
+		field.SetBool(d.v.Bool)
+	}
+	return nil
+}
+
+// convertDest scans the value into an interface and converts it with [DatabaseConverter].
+// It is used for types that cannot be scanned directly, for example time.Time or decimal.Decimal,
+// and for fields with the DB_MAPPER_EMPTY and DB_MAPPER_DATE_F tags.
+type convertDest struct {
+	v    any
+	name string
+	tag  reflect.StructTag
+}
+
+func (d *convertDest) ptr() any { return &d.v }
+func (d *convertDest) set(field reflect.Value) error {
+	return fillDbField(&field, d.name, d.tag, d.v)
+}
+
+// newColumnDest selects the destination by the type of the field.
+func newColumnDest(fieldType reflect.Type, name string, tag reflect.StructTag) columnDest {
+	if tag.Get(namelib.TAGS.DB_MAPPER_EMPTY) == "" && tag.Get(namelib.TAGS.DB_MAPPER_DATE_F) == "" && fieldType.PkgPath() == "" {
+		switch fieldType.Kind() {
+		case reflect.String:
+			return &stringDest{}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return &intDest{}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return &uintDest{}
+		case reflect.Float32, reflect.Float64:
+			return &floatDest{}
+		case reflect.Bool:
+			return &boolDest{}
+		}
+	}
+	return &convertDest{name: name, tag: tag}
+}
+
+// ScanRowsInto scans the rows of the query result directly into the structures.
+// It works like [FillStructFromDb], but does not create a map for each row.
+// It needs the `db:"<column name>"` tag to work properly. Columns without a field are skipped.
+//
+// Simple types are scanned directly, other types, as well as fields with the
+// DB_MAPPER_EMPTY or DB_MAPPER_DATE_F tags, are converted in the same way as in [FillStructFromDb].
+// If an integer does not fit into the type of the field, the [ErrValueOverflow] error is returned.
+// For each combination of structure type and columns, a scan plan is created and cached.
+// IMPORTANT: the rows are not closed by this function.
+func ScanRowsInto[T any](rows *sql.Rows, fn func(item *T) error) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var zero T
+	typ := reflect.TypeOf(zero)
+	if typ.Kind() != reflect.Struct {
+		return typeopr.ErrParameterNotStruct{Param: "T"}
+	}
+	plan := loadScanPlan(typ, columns)
+	dests := make([]columnDest, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := 0; i < len(columns); i++ {
+		if plan.fields[i] == -1 {
+			ptrs[i] = new(any)
+			continue
+		}
+		dests[i] = newColumnDest(typ.Field(plan.fields[i]).Type, columns[i], plan.tags[i])
+		ptrs[i] = dests[i].ptr()
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		var item T
+		value := reflect.ValueOf(&item).Elem()
+		for i := 0; i < len(dests); i++ {
+			if dests[i] == nil {
+				continue
+			}
+			if err := dests[i].set(value.Field(plan.fields[i])); err != nil {
+				return err
+			}
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type ErrValueOverflow struct {
+	Value string
+	Type  string
+}
+
+func (e ErrValueOverflow) Error() string {
+	return fmt.Sprintf("value %s overflows the %s type", e.Value, e.Type)
+}
@@ -50,6 +50,18 @@ type TagNames struct {
 	DB_MAPPER_NAME        string
 	DB_MAPPER_EMPTY       string
 	DB_MAPPER_DATE_F      string
+	DB_SCHEMA_TYPE        string
+	DB_SCHEMA_SIZE        string
+	DB_SCHEMA_NULL        string
+	DB_SCHEMA_PK          string
+	DB_SCHEMA_AI          string
+	DB_SCHEMA_INDEX       string
+	DB_SCHEMA_UNIQUE      string
+	DB_SCHEMA_DEFAULT     string
+	DB_SCHEMA_FK          string
+	DB_TABLE              string
+	DB_RELATION           string
+	DB_AUTO               string
 	FORM_MAPPER_NAME      string
 	FORM_MAPPER_EMPTY     string
 	FORM_MAPPER_EXTENSION string
@@ -61,6 +73,18 @@ var TAGS = TagNames{
 	DB_MAPPER_NAME:        "db",
 	DB_MAPPER_EMPTY:       "empty",
 	DB_MAPPER_DATE_F:      "date-f",
+	DB_SCHEMA_TYPE:        "dbtype",
+	DB_SCHEMA_SIZE:        "dbsize",
+	DB_SCHEMA_NULL:        "dbnull",
+	DB_SCHEMA_PK:          "dbpk",
+	DB_SCHEMA_AI:          "dbai",
+	DB_SCHEMA_INDEX:       "dbindex",
+	DB_SCHEMA_UNIQUE:      "dbunique",
+	DB_SCHEMA_DEFAULT:     "dbdefault",
+	DB_SCHEMA_FK:          "dbfk",
+	DB_TABLE:              "dbtable",
+	DB_RELATION:           "dbrel",
+	DB_AUTO:               "dbauto",
 	FORM_MAPPER_NAME:      "form",
 	FORM_MAPPER_EMPTY:     "empty",
 	FORM_MAPPER_EXTENSION: "ext",
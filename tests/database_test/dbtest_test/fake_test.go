@@ -0,0 +1,132 @@
+package dbtest_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/database/dbtest"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+type user struct {
+	Id   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestQueryRows(t *testing.T) {
+	db := dbtest.NewDefaultFakeDatabase()
+	defer db.Close()
+	db.On(`^SELECT \* FROM users WHERE id = \?$`).WithArgs(1).ReturnRows(map[string]any{"id": 1, "name": "alice"})
+	db.On(`^SELECT \* FROM users`).ReturnRows()
+
+	users, err := database.QueryInto[user](db.SyncQ(), "SELECT * FROM users WHERE id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Id != 1 || users[0].Name != "alice" {
+		t.Errorf("unexpected users %v", users)
+	}
+	rows, err := qb.NewSyncQB(db.SyncQ()).SelectFrom("*", "users").Where(qb.Compare("id", qb.EQUAL, 2)).Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("expected no rows, got %v", rows)
+	}
+	db.AssertCalled(t, "FROM users WHERE id", 1)
+	db.AssertCallCount(t, "FROM users", 2)
+	db.AssertNotCalled(t, "DELETE")
+	db.AssertRulesUsed(t)
+}
+
+func TestExecAndErrors(t *testing.T) {
+	db := dbtest.NewDefaultFakeDatabase()
+	defer db.Close()
+	errDuplicate := errors.New("duplicate")
+	db.On("^INSERT INTO users").Once().ReturnResult(7, 1)
+	db.On("^INSERT INTO users").ReturnError(errDuplicate)
+
+	res, err := db.SyncQ().Exec("INSERT INTO users (name) VALUES (?)", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["insertID"] != int64(7) || res["rowsAffected"] != int64(1) {
+		t.Errorf("unexpected result %v", res)
+	}
+	if _, err := db.SyncQ().Exec("INSERT INTO users (name) VALUES (?)", "bob"); !errors.Is(err, errDuplicate) {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+	_, err = db.SyncQ().Query("SELECT 1")
+	if !errors.As(err, &dbtest.ErrUnexpectedQuery{}) {
+		t.Errorf("expected ErrUnexpectedQuery, got %v", err)
+	}
+	calls := db.Calls()
+	if len(calls) != 3 || !calls[0].Exec || calls[0].Args[0] != "bob" || calls[2].Exec {
+		t.Errorf("unexpected calls %v", calls)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	db := dbtest.NewDefaultFakeDatabase()
+	defer db.Close()
+	db.On(regexp.QuoteMeta("UPDATE users SET name = ?")).ReturnResult(0, 1)
+
+	err := database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+		if _, err := tx.SyncQ().Exec("UPDATE users SET name = ?", "carol"); err != nil {
+			return err
+		}
+		return database.WithSavepoint(tx, func() error {
+			return errors.New("inner")
+		})
+	})
+	if err == nil || err.Error() != "inner" {
+		t.Fatalf("expected inner error, got %v", err)
+	}
+	db.AssertRolledBack(t)
+	db.AssertCalled(t, "^ROLLBACK TO SAVEPOINT sp_1$")
+	if calls := db.Calls(); !calls[0].Tx {
+		t.Error("the query must be executed in the transaction")
+	}
+
+	db.Reset()
+	db.On("^DELETE").ReturnResult(0, 1)
+	if err := database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+		_, err := tx.SyncQ().Exec("DELETE FROM users")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.AssertCommitted(t)
+
+	errCommit := errors.New("commit failed")
+	db.FailCommit(errCommit)
+	if err := database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error { return nil }); !errors.Is(err, errCommit) {
+		t.Errorf("expected commit error, got %v", err)
+	}
+}
+
+func TestAsyncQueries(t *testing.T) {
+	db := dbtest.NewDefaultFakeDatabase()
+	defer db.Close()
+	db.On("FROM users").ReturnRows(map[string]any{"id": 1, "name": "alice"}, map[string]any{"id": 2, "name": "bob"})
+
+	asyncQ, err := db.NewAsyncQ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	asyncQ.Query("users", "SELECT * FROM users")
+	asyncQ.Query("count", "SELECT COUNT(*) FROM posts")
+	if err := asyncQ.(interfaces.AsyncErrQuery).WaitErr(); !errors.As(err, &dbtest.ErrUnexpectedQuery{}) {
+		t.Errorf("expected ErrUnexpectedQuery, got %v", err)
+	}
+	var users []user
+	if err := database.LoadInto(asyncQ, "users", &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[1].Name != "bob" {
+		t.Errorf("unexpected users %v", users)
+	}
+}
@@ -0,0 +1,280 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/database/dbtest"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+var connRefused = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+func newPool(t *testing.T) (*database.DatabasePool, *dbtest.FakeDatabase) {
+	fake := dbtest.NewDefaultFakeDatabase()
+	t.Cleanup(func() { fake.Close() })
+	syncQ := database.NewSyncQueries()
+	sqlite := database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, database.NewAsyncQueries(syncQ))
+	if err := sqlite.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	pool := database.NewDatabasePool()
+	if err := pool.AddConnection("main", fake); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.AddConnection("local", sqlite); err != nil {
+		t.Fatal(err)
+	}
+	pool.Lock()
+	return pool, fake
+}
+
+func TestPoolNames(t *testing.T) {
+	pool, _ := newPool(t)
+	names := pool.Names()
+	if len(names) != 2 || names[0] != "local" || names[1] != "main" {
+		t.Errorf("unexpected names %v", names)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	pool, fake := newPool(t)
+	checker := database.NewHealthChecker(pool, time.Second)
+	status := checker.Check()
+	if !status.Ready || len(status.Connections) != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	local, ok := status.Connection("local")
+	if !ok || !local.Healthy || local.OpenConnections != 1 || local.Error != "" {
+		t.Errorf("unexpected local connection %+v", local)
+	}
+
+	fake.FailPing(errors.New("server has gone away"))
+	status = checker.Check()
+	if status.Ready {
+		t.Error("expected the pool not to be ready")
+	}
+	main, _ := status.Connection("main")
+	if main.Healthy || main.Error != "server has gone away" {
+		t.Errorf("unexpected main connection %+v", main)
+	}
+	if !checker.Status().CheckedAt.Equal(status.CheckedAt) || checker.Ready() {
+		t.Error("expected the last status to be saved")
+	}
+	fake.FailPing(nil)
+	if !checker.Check().Ready {
+		t.Error("expected the pool to be ready after the connection is restored")
+	}
+}
+
+type unnamedPool struct {
+	interfaces.DatabasePool
+}
+
+func TestHealthCheckUnnamedPool(t *testing.T) {
+	pool, _ := newPool(t)
+	status := database.NewHealthChecker(unnamedPool{pool}, time.Second).Check()
+	if status.Ready || len(status.Connections) != 0 {
+		t.Errorf("expected the pool with unknown names not to be ready, got %+v", status)
+	}
+	status = database.NewHealthChecker(unnamedPool{pool}, time.Second, "main").Check()
+	if !status.Ready || len(status.Connections) != 1 || status.Connections[0].Name != "main" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestHealthCheckNotLockedPool(t *testing.T) {
+	pool := database.NewDatabasePool()
+	pool.AddConnection("main", dbtest.NewDefaultFakeDatabase())
+	status := database.NewHealthChecker(pool, 0).Check()
+	if status.Ready || status.Connections[0].Error == "" {
+		t.Errorf("expected an error of the not locked pool, got %+v", status)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	pool, fake := newPool(t)
+	checker := database.NewHealthChecker(pool, time.Second)
+
+	w := httptest.NewRecorder()
+	if err := checker.Handler(w, httptest.NewRequest(http.MethodGet, "/ready", nil), nil); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	var status database.HealthStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Ready || len(status.Connections) != 2 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	var raw struct {
+		Connections []map[string]any `json:"connections"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw.Connections[0]["latency_ms"].(float64); !ok {
+		t.Errorf("expected the latency in milliseconds, got %v", raw.Connections[0])
+	}
+	if _, ok := raw.Connections[0]["latency"]; ok {
+		t.Errorf("unexpected latency in nanoseconds %v", raw.Connections[0])
+	}
+
+	fake.FailPing(errors.New("down"))
+	w = httptest.NewRecorder()
+	checker.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
+
+func TestHealthCheckStart(t *testing.T) {
+	pool, fake := newPool(t)
+	checker := database.NewHealthChecker(pool, time.Second)
+	checker.Start(10 * time.Millisecond)
+	defer checker.Stop()
+	if !checker.Ready() {
+		t.Fatal("expected the first check to be performed by Start")
+	}
+	fake.FailPing(errors.New("down"))
+	deadline := time.Now().Add(time.Second)
+	for checker.Ready() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if checker.Ready() {
+		t.Error("expected the periodic check to find the unhealthy connection")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		checker.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ready", nil))
+	}()
+	checker.Stop()
+	<-done
+}
+
+func TestIsTransientError(t *testing.T) {
+	if !database.IsTransientError(connRefused) {
+		t.Error("expected connection refused to be transient")
+	}
+	if database.IsTransientError(errors.New("syntax error")) {
+		t.Error("expected a query error not to be transient")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	contextErrors := []error{
+		context.Canceled,
+		context.DeadlineExceeded,
+		ctx.Err(),
+		&net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded},
+	}
+	for _, err := range contextErrors {
+		if database.IsTransientError(err) {
+			t.Errorf("expected %v not to be transient", err)
+		}
+	}
+}
+
+func TestRetryRead(t *testing.T) {
+	fake := dbtest.NewDefaultFakeDatabase()
+	defer fake.Close()
+	fake.SyncQ().(*database.SyncQueries).SetRetry(database.RetryOptions{MaxRetries: 2, BaseDelay: time.Millisecond})
+	fake.On(`^SELECT`).Times(2).ReturnError(connRefused)
+	fake.On(`^SELECT`).ReturnRows(map[string]any{"id": 1})
+
+	res, err := fake.SyncQ().Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Errorf("unexpected result %v", res)
+	}
+	fake.AssertCallCount(t, "^SELECT", 3)
+}
+
+func TestRetryReadLimit(t *testing.T) {
+	fake := dbtest.NewDefaultFakeDatabase()
+	defer fake.Close()
+	fake.SyncQ().(*database.SyncQueries).SetRetry(database.RetryOptions{MaxRetries: 1, BaseDelay: time.Millisecond})
+	fake.On(`^SELECT`).ReturnError(connRefused)
+
+	if _, err := fake.SyncQ().Query("SELECT id FROM users"); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected connection refused, got %v", err)
+	}
+	fake.AssertCallCount(t, "^SELECT", 2)
+}
+
+func TestRetryReadQueries(t *testing.T) {
+	queries := map[string]int{
+		"WITH ids AS (SELECT id FROM users) SELECT * FROM ids":                                        3,
+		"WITH RECURSIVE tree(id) AS (SELECT 1 UNION ALL SELECT id + 1 FROM tree) SELECT id FROM tree": 3,
+		"SELECT id FROM logs WHERE action = 'DELETE' OR note = 'it\\'s FOR UPDATE'":                   3,
+		"WITH old AS (SELECT id FROM users) DELETE FROM users WHERE id IN (SELECT id FROM old)":       1,
+		"WITH moved AS (DELETE FROM users RETURNING *) SELECT * FROM moved":                           1,
+		"WITH ids AS (SELECT id FROM users) UPDATE users SET active = 0":                              1,
+		"SELECT id FROM users WHERE id = 1 FOR UPDATE":                                                1,
+		"SELECT id FROM users WHERE id = 1 FOR NO KEY UPDATE":                                         1,
+		"SELECT id FROM users WHERE id = 1 FOR SHARE":                                                 1,
+		"SELECT id FROM users WHERE id = 1 LOCK IN SHARE MODE":                                        1,
+		"SELECT id INTO backup FROM users":                                                            1,
+	}
+	for query, calls := range queries {
+		fake := dbtest.NewDefaultFakeDatabase()
+		fake.SyncQ().(*database.SyncQueries).SetRetry(database.RetryOptions{MaxRetries: 2, BaseDelay: time.Millisecond})
+		fake.On(`.`).ReturnError(connRefused)
+		if _, err := fake.SyncQ().Query(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+		if n := len(fake.Calls()); n != calls {
+			t.Errorf("%s: expected %d calls, got %d", query, calls, n)
+		}
+		fake.Close()
+	}
+}
+
+func TestRetryNotIdempotent(t *testing.T) {
+	fake := dbtest.NewDefaultFakeDatabase()
+	defer fake.Close()
+	fake.SyncQ().(*database.SyncQueries).SetRetry(database.RetryOptions{MaxRetries: 3, BaseDelay: time.Millisecond})
+	fake.On(`^INSERT`).ReturnError(connRefused)
+	fake.On(`^SELECT`).ReturnError(errors.New("syntax error"))
+
+	if _, err := fake.SyncQ().Exec("INSERT INTO users (id) VALUES (1)"); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := fake.SyncQ().Query("SELECT id FROM users"); err == nil {
+		t.Error("expected an error")
+	}
+	fake.AssertCallCount(t, "^INSERT", 1)
+	fake.AssertCallCount(t, "^SELECT", 1)
+
+	tx, err := fake.NewTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	fake.Reset()
+	fake.On(`^SELECT`).ReturnError(connRefused)
+	if _, err := tx.SyncQ().Query("SELECT id FROM users"); err == nil {
+		t.Error("expected an error")
+	}
+	fake.AssertCallCount(t, "^SELECT", 1)
+	tx.RollBackTransaction()
+}
@@ -0,0 +1,269 @@
+package migrate_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/database/migrate"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+func newDb(t *testing.T) *database.SqliteDatabase {
+	syncQ := database.NewSyncQueries()
+	db := database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, database.NewAsyncQueries(syncQ))
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db interfaces.DatabaseInteraction, name string) bool {
+	res, err := db.SyncQ().Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(res) == 1
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := newDb(t)
+	m := migrate.NewMigrator(db, qb.SQLITE)
+	err := m.Add(
+		migrate.Migration{
+			Version: 2, Name: "posts",
+			Up:   migrate.SQLFunc("CREATE TABLE posts (id INTEGER);"),
+			Down: migrate.SQLFunc("DROP TABLE posts;"),
+		},
+		migrate.Migration{
+			Version: 1, Name: "users",
+			Up:   migrate.SQLFunc("CREATE TABLE users (id INTEGER); CREATE TABLE roles (id INTEGER);"),
+			Down: migrate.SQLFunc("DROP TABLE users; DROP TABLE roles;"),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Version != 1 {
+		t.Fatalf("unexpected applied migrations %v", applied)
+	}
+	if !tableExists(t, db, "users") || !tableExists(t, db, "roles") || !tableExists(t, db, "posts") {
+		t.Error("tables must be created")
+	}
+	applied, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Error("migrations must not be applied twice")
+	}
+	rolledBack, err := m.Down(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rolledBack) != 1 || rolledBack[0].Version != 2 || tableExists(t, db, "posts") {
+		t.Error("the last migration must be rolled back")
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status[0].Applied || status[1].Applied {
+		t.Errorf("unexpected status %v", status)
+	}
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	db := newDb(t)
+	m := migrate.NewMigrator(db, qb.SQLITE)
+	if err := m.Add(migrate.Migration{Version: 1, Name: "broken", Up: migrate.SQLFunc("CREATE TABLE a (id INTEGER); NOT SQL;")}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.Up()
+	var failed migrate.ErrMigrationFailed
+	if !errors.As(err, &failed) {
+		t.Fatalf("expected ErrMigrationFailed, got %v", err)
+	}
+	if tableExists(t, db, "a") {
+		t.Error("the migration must be rolled back")
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status[0].Applied {
+		t.Error("the failed migration must not be applied")
+	}
+}
+
+func TestLock(t *testing.T) {
+	db := newDb(t)
+	m := migrate.NewMigrator(db, qb.SQLITE)
+	if err := m.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	var locked migrate.ErrMigrationsLocked
+	if _, err := m.Up(); !errors.As(err, &locked) {
+		t.Fatalf("expected ErrMigrationsLocked, got %v", err)
+	}
+	if err := m.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	upPath, downPath, err := migrate.NewSQLFiles(dir, "create items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(upPath, []byte("CREATE TABLE items (name TEXT DEFAULT 'a;b');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(downPath, []byte("DROP TABLE items;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db := newDb(t)
+	m := migrate.NewMigrator(db, qb.SQLITE)
+	if err := m.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Migrations()) != 1 || m.Migrations()[0].Name != "create_items" {
+		t.Fatalf("unexpected migrations %v", m.Migrations())
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if !tableExists(t, db, "items") {
+		t.Error("the table must be created")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	res := migrate.SplitStatements("-- comment; here\nINSERT INTO a VALUES (';');\n\nSELECT 1")
+	if len(res) != 2 || res[0] != "INSERT INTO a VALUES (';')" || res[1] != "SELECT 1" {
+		t.Errorf("unexpected statements %q", res)
+	}
+}
+
+func TestSplitStatementsBlockComment(t *testing.T) {
+	res := migrate.SplitStatements("/* create; the table */\nCREATE TABLE a (id INT /* the id; */);\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\nSELECT /*+ MAX_EXECUTION_TIME(1); */ 1;\n/* end; */")
+	expected := []string{"CREATE TABLE a (id INT )", "/*!40101 SET NAMES utf8mb4 */", "SELECT /*+ MAX_EXECUTION_TIME(1); */ 1"}
+	if strings.Join(res, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected statements %q", res)
+	}
+}
+
+func TestSplitStatementsBackslashEscape(t *testing.T) {
+	res := migrate.SplitStatements(`INSERT INTO a VALUES ('it\'s; ok', "say \"hi;\"", 'c:\\');SELECT 1`)
+	expected := []string{`INSERT INTO a VALUES ('it\'s; ok', "say \"hi;\"", 'c:\\')`, "SELECT 1"}
+	if strings.Join(res, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected statements %q", res)
+	}
+}
+
+func TestSplitStatementsBeginEnd(t *testing.T) {
+	res := migrate.SplitStatements("CREATE TRIGGER t AFTER INSERT ON a BEGIN\n" +
+		"UPDATE b SET n = CASE WHEN n > 0 THEN n + 1 ELSE 1 END; DELETE FROM c;\nEND;\n" +
+		"CREATE PROCEDURE p() BEGIN\nIF 1 THEN SELECT 1; END IF;\nBEGIN SELECT 2; END;\nEND;\n" +
+		"BEGIN TRANSACTION; SELECT ended_at FROM d; BEGIN; END")
+	expected := []string{
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN\nUPDATE b SET n = CASE WHEN n > 0 THEN n + 1 ELSE 1 END; DELETE FROM c;\nEND",
+		"CREATE PROCEDURE p() BEGIN\nIF 1 THEN SELECT 1; END IF;\nBEGIN SELECT 2; END;\nEND",
+		"BEGIN TRANSACTION", "SELECT ended_at FROM d", "BEGIN", "END",
+	}
+	if strings.Join(res, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected statements %q", res)
+	}
+}
+
+func TestTriggerMigration(t *testing.T) {
+	db := newDb(t)
+	m := migrate.NewMigrator(db, qb.SQLITE)
+	err := m.Add(migrate.Migration{
+		Version: 1, Name: "trigger",
+		Up: migrate.SQLFunc("CREATE TABLE a (id INTEGER); CREATE TABLE b (n INTEGER);\n" +
+			"CREATE TRIGGER a_insert AFTER INSERT ON a BEGIN\nINSERT INTO b VALUES (1);\nINSERT INTO b VALUES (2);\nEND;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec("INSERT INTO a VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := db.SyncQ().Query("SELECT n FROM b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Errorf("the trigger must insert two rows, got %v", res)
+	}
+}
+
+func TestVersionColumn(t *testing.T) {
+	db := newDb(t)
+	m := migrate.NewMigrator(db, qb.SQLITE)
+	if err := m.Add(migrate.Migration{Version: 20240101120000, Name: "a", Up: migrate.SQLFunc("SELECT 1")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := db.SyncQ().Query("SELECT typeof(version) AS type FROM " + migrate.MIGRATIONS_TABLE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0]["type"] != "integer" {
+		t.Errorf("the version must be stored as a number, got %v", res)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 1 || !status[0].Applied {
+		t.Errorf("unexpected status %v", status)
+	}
+}
+
+func TestTextVersionColumn(t *testing.T) {
+	db := newDb(t)
+	if _, err := db.SyncQ().Exec("CREATE TABLE " + migrate.MIGRATIONS_TABLE + " (version VARCHAR(32) PRIMARY KEY, name VARCHAR(255))"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec("INSERT INTO "+migrate.MIGRATIONS_TABLE+" VALUES (?, ?)", "1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	m := migrate.NewMigrator(db, qb.SQLITE)
+	if err := m.Add(migrate.Migration{Version: 1, Name: "a", Up: migrate.SQLFunc("SELECT 1")}); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 1 || !status[0].Applied {
+		t.Errorf("unexpected status %v", status)
+	}
+}
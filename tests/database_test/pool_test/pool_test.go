@@ -0,0 +1,96 @@
+package pool_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/config"
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/router/manager"
+)
+
+const configYaml = `GeneratedDefault: true
+GeneratedAdditionally: true
+Config:
+    Database:
+        MainConnectionPoolName: main
+        Connections:
+            main:
+                Driver: sqlite
+                DatabaseName: ":memory:"
+                StmtCacheSize: 10
+            reports:
+                Driver: sqlite
+                DatabaseName: "%s"
+                MaxOpenConns: 4
+                ConnMaxLifetime: 5m
+Additionally: {}
+`
+
+func TestDSN(t *testing.T) {
+	args := database.DbArgs{
+		Username:     "root",
+		Password:     "1111",
+		Host:         "localhost",
+		Port:         "3306",
+		DatabaseName: "foozy",
+		Charset:      "utf8mb4",
+		ParseTime:    true,
+		TLS:          "skip-verify",
+		Timeout:      5 * time.Second,
+	}
+	expected := "root:1111@tcp(localhost:3306)/foozy?parseTime=true&timeout=5s&tls=skip-verify&charset=utf8mb4"
+	if dsn := args.DSN(); dsn != expected {
+		t.Errorf("expected %s, got %s", expected, dsn)
+	}
+	bare := database.DbArgs{Username: "root", Password: "1111", Host: "localhost", Port: "3306", DatabaseName: "foozy"}
+	if dsn := bare.DSN(); dsn != "root:1111@tcp(localhost:3306)/foozy" {
+		t.Errorf("unexpected bare DSN %s", dsn)
+	}
+}
+
+func TestNewDatabaseFromConfigUnknownDriver(t *testing.T) {
+	_, err := database.NewDatabaseFromConfig(&config.ConnectionConfig{Driver: "oracle"})
+	if !errors.As(err, &database.ErrUnknownDriver{}) {
+		t.Errorf("expected ErrUnknownDriver, got %v", err)
+	}
+}
+
+func TestInitDatabasePoolFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	data := []byte(fmt.Sprintf(configYaml, filepath.Join(dir, "reports.db")))
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.Cnf().SetLoadPath(configPath)
+
+	pool := database.NewDatabasePool()
+	m := manager.NewManager(manager.NewOneTimeData(), nil, pool)
+	if err := database.InitDatabasePoolFromConfig(m); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"main", "reports"} {
+		db, err := pool.ConnectionPool(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.SyncQ().Query("SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.(interface{ Close() error }).Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	main, _ := pool.ConnectionPool("main")
+	if main.(*database.SqliteDatabase).StmtCache() == nil {
+		t.Error("the statement cache of the main connection must be enabled")
+	}
+	if err := pool.AddConnection("other", main); err == nil {
+		t.Error("the pool must be locked")
+	}
+}
@@ -0,0 +1,417 @@
+package qb_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+)
+
+func TestPostgresPlaceholders(t *testing.T) {
+	q := qb.NewNoDbQB().SetDialect(qb.POSTGRES).SelectFrom("*", "users").Where(
+		qb.Compare("id", qb.EQUAL, 1), qb.AND, qb.Compare("name", qb.NOT_EQUAL, "a?b"),
+	).Limit(10)
+	q.Merge()
+	expected := "SELECT * FROM users WHERE id = $1 AND name != $2 LIMIT 10"
+	if q.String() != expected {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	if !reflect.DeepEqual(q.Args(), []any{1, "a?b"}) {
+		t.Errorf("unexpected args: %v", q.Args())
+	}
+}
+
+func TestRebindSkipsQuotedValues(t *testing.T) {
+	res := qb.Rebind(qb.POSTGRES, "SELECT '?' FROM t WHERE a = ? AND \"b?\" = ?")
+	if res != "SELECT '?' FROM t WHERE a = $1 AND \"b?\" = $2" {
+		t.Errorf("unexpected query: %s", res)
+	}
+}
+
+func TestSubqueryPlaceholders(t *testing.T) {
+	sq := qb.SQ(true, qb.NewNoDbQB().SelectFrom("id", "orders").Where(qb.Compare("total", qb.GREATER, 5)))
+	q := qb.NewNoDbQB().SetDialect(qb.POSTGRES).SelectFrom("*", "users").Where(
+		qb.Compare("name", qb.EQUAL, "n"), qb.AND, qb.Compare("id", qb.IN, sq),
+	)
+	q.Merge()
+	expected := "SELECT * FROM users WHERE name = $1 AND id IN (SELECT id FROM orders WHERE total > $2)"
+	if q.String() != expected {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	if qb.MYSQL.QuoteIdent("users.name") != "`users`.`name`" {
+		t.Error("wrong mysql quoting")
+	}
+	if qb.POSTGRES.QuoteIdent(`a"b`) != `"a""b"` {
+		t.Error("wrong postgres quoting")
+	}
+}
+
+func TestRandDialects(t *testing.T) {
+	if qb.Rand() != "RAND()" || qb.Rand(qb.MYSQL) != "RAND()" {
+		t.Errorf("unexpected mysql function %s", qb.Rand())
+	}
+	if qb.Rand(qb.SQLITE) != "RANDOM()" || qb.Rand(qb.POSTGRES) != "RANDOM()" {
+		t.Errorf("unexpected functions %s %s", qb.Rand(qb.SQLITE), qb.Rand(qb.POSTGRES))
+	}
+	q := qb.NewNoDbQB().SetDialect(qb.SQLITE).SelectFrom("*", "users").OrderBy(qb.Rand(qb.SQLITE))
+	q.Merge()
+	if q.String() != "SELECT * FROM users ORDER BY RANDOM()" {
+		t.Errorf("unexpected query %s", q.String())
+	}
+}
+
+func TestTableDialects(t *testing.T) {
+	cols := []qb.Col{
+		{Name: "id", Type: qb.T{}.Int(11), AI: true, PK: true},
+		{Name: "name", Type: qb.T{}.Varchar(200), Null: true},
+	}
+	expected := map[qb.Dialect]string{
+		qb.MYSQL:    "CREATE TABLE IF NOT EXISTS users (id INT(11) NOT NULL AUTO_INCREMENT PRIMARY KEY, name VARCHAR(200) NULL );",
+		qb.POSTGRES: "CREATE TABLE IF NOT EXISTS users (id INTEGER NOT NULL GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, name VARCHAR(200) NULL );",
+		qb.SQLITE:   "CREATE TABLE IF NOT EXISTS users (id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, name VARCHAR(200) NULL );",
+	}
+	for dialect, query := range expected {
+		res := qb.NewTableQB().SetDialect(dialect).Create("users", cols).Build().String()
+		if res != query {
+			t.Errorf("%s: unexpected query: %s", dialect.Name(), res)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if res := qb.NewTableQB().SetDialect(qb.SQLITE).Truncate("a", "b").Build().String(); res != "DELETE FROM a; DELETE FROM b;" {
+		t.Errorf("unexpected query: %s", res)
+	}
+	if res := qb.NewTableQB().SetDialect(qb.POSTGRES).Truncate("a", "b").Build().String(); res != "TRUNCATE TABLE a, b;" {
+		t.Errorf("unexpected query: %s", res)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	if res, err := qb.MYSQL.Upsert([]string{"id"}, []string{"name"}); err != nil || res != "ON DUPLICATE KEY UPDATE name = VALUES(name)" {
+		t.Errorf("unexpected mysql upsert: %s %v", res, err)
+	}
+	if res, err := qb.POSTGRES.Upsert([]string{"id"}, []string{"name"}); err != nil || res != "ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name" {
+		t.Errorf("unexpected postgres upsert: %s %v", res, err)
+	}
+	if _, err := qb.MYSQL.Upsert(nil, []string{"name"}); err != nil {
+		t.Errorf("mysql does not use conflict columns, got %v", err)
+	}
+	for _, dialect := range []qb.Dialect{qb.POSTGRES, qb.SQLITE} {
+		if _, err := dialect.Upsert(nil, []string{"name"}); !errors.As(err, &qb.ErrNoConflictColumns{}) {
+			t.Errorf("%s: expected ErrNoConflictColumns, got %v", dialect.Name(), err)
+		}
+		q := qb.NewNoDbQB().SetDialect(dialect).InsertMany("users", []string{"id", "name"}, [][]any{{1, "a"}}).Upsert(nil, []string{"name"})
+		if _, err := q.Exec(); !errors.As(err, &qb.ErrNoConflictColumns{}) {
+			t.Errorf("%s: expected the query to return ErrNoConflictColumns, got %v", dialect.Name(), err)
+		}
+	}
+}
+
+func TestUpsertWithoutUpdateColumns(t *testing.T) {
+	if res, err := qb.POSTGRES.Upsert([]string{"id"}, nil); err != nil || res != "ON CONFLICT (id) DO NOTHING" {
+		t.Errorf("unexpected postgres upsert: %s %v", res, err)
+	}
+	if res, err := qb.SQLITE.Upsert([]string{"id"}, []string{}); err != nil || res != "ON CONFLICT (id) DO NOTHING" {
+		t.Errorf("unexpected sqlite upsert: %s %v", res, err)
+	}
+	if res, err := qb.MYSQL.Upsert([]string{"id"}, nil); err != nil || res != "ON DUPLICATE KEY UPDATE id = id" {
+		t.Errorf("unexpected mysql upsert: %s %v", res, err)
+	}
+	if _, err := qb.MYSQL.Upsert(nil, nil); !errors.As(err, &qb.ErrNoConflictColumns{}) {
+		t.Errorf("expected ErrNoConflictColumns, got %v", err)
+	}
+}
+
+func TestCreateWithConstraints(t *testing.T) {
+	cols := []qb.Col{
+		{Name: "user_id", Type: qb.T{}.BigInt()},
+		{Name: "role", Type: qb.T{}.Enum("admin", "user")},
+		{Name: "price", Type: qb.T{}.Decimal(10, 2)},
+	}
+	res := qb.NewTableQB().Create("user_roles", cols,
+		qb.PrimaryKey("user_id", "role"),
+		qb.Check("chk_price", "price >= 0"),
+	).Build().String()
+	expected := "CREATE TABLE IF NOT EXISTS user_roles (user_id BIGINT NOT NULL, role ENUM('admin', 'user') NOT NULL, " +
+		"price DECIMAL(10, 2) NOT NULL, PRIMARY KEY (user_id, role), CONSTRAINT chk_price CHECK (price >= 0) );"
+	if res != expected {
+		t.Errorf("unexpected query: %s", res)
+	}
+}
+
+func TestTypeNames(t *testing.T) {
+	types := []qb.T{qb.T{}.DateTime(), qb.T{}.Timestamp(), qb.T{}.JSON(), qb.T{}.Blob(), qb.T{}.UUID(), qb.T{}.Enum("a", "bcd")}
+	expected := map[qb.Dialect][]string{
+		qb.MYSQL:    {"DATETIME", "TIMESTAMP", "JSON", "BLOB", "CHAR(36)", "ENUM('a', 'bcd')"},
+		qb.POSTGRES: {"TIMESTAMP", "TIMESTAMP", "JSON", "BYTEA", "UUID", "VARCHAR(3)"},
+		qb.SQLITE:   {"DATETIME", "TIMESTAMP", "TEXT", "BLOB", "TEXT", "TEXT"},
+	}
+	for dialect, names := range expected {
+		for i := 0; i < len(types); i++ {
+			if res := dialect.TypeName(types[i]); res != names[i] {
+				t.Errorf("%s: expected %s, got %s", dialect.Name(), names[i], res)
+			}
+		}
+	}
+}
+
+func TestAlterTable(t *testing.T) {
+	col := qb.Col{Name: "name", Type: qb.T{}.Varchar(100), Null: true}
+	res := qb.NewTableQB().
+		ModifyColumn("users", col).
+		RenameColumn("users", "name", "full_name").
+		RenameTable("users", "people").
+		AddConstraint("people", qb.Unique("uq_people_email", "email")).
+		DropConstraint("people", "uq_people_email").
+		DropIndex("people", "idx_people_age").
+		Build().String()
+	expected := "ALTER TABLE users MODIFY COLUMN name VARCHAR(100) NULL; " +
+		"ALTER TABLE users RENAME COLUMN name TO full_name; " +
+		"ALTER TABLE users RENAME TO people; " +
+		"ALTER TABLE people ADD CONSTRAINT uq_people_email UNIQUE (email); " +
+		"ALTER TABLE people DROP CONSTRAINT uq_people_email; " +
+		"DROP INDEX idx_people_age ON people;"
+	if res != expected {
+		t.Errorf("unexpected query: %s", res)
+	}
+	res = qb.NewTableQB().SetDialect(qb.POSTGRES).ModifyColumn("users", col).Build().String()
+	if res != "ALTER TABLE users ALTER COLUMN name TYPE VARCHAR(100), ALTER COLUMN name DROP NOT NULL;" {
+		t.Errorf("unexpected query: %s", res)
+	}
+}
+
+func TestSqliteModifyColumnError(t *testing.T) {
+	tableQB := qb.NewTableQB().SetDialect(qb.SQLITE).
+		ModifyColumn("users", qb.Col{Name: "name", Type: qb.T{}.Text()}).
+		RenameColumn("users", "name", "title").Build()
+	if _, ok := tableQB.Err().(qb.ErrNotSupported); !ok {
+		t.Errorf("expected ErrNotSupported, got %v", tableQB.Err())
+	}
+	if tableQB.String() != "ALTER TABLE users RENAME COLUMN name TO title;" {
+		t.Errorf("unexpected query: %s", tableQB.String())
+	}
+	if err := qb.NewTableQB().SetDialect(qb.POSTGRES).ModifyColumn("users", qb.Col{Name: "name", Type: qb.T{}.Text()}).Err(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestInsertColumnOrder(t *testing.T) {
+	q := qb.NewNoDbQB().Insert("users", map[string]any{"name": "a", "age": 1, "email": "e"})
+	q.Merge()
+	if q.String() != "INSERT INTO `users` ( `age`, `email`, `name` ) VALUES ( ?, ?, ? )" {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	if !reflect.DeepEqual(q.Args(), []any{1, "e", "a"}) {
+		t.Errorf("unexpected args: %v", q.Args())
+	}
+}
+
+func TestInsertManyUpsert(t *testing.T) {
+	rows := [][]any{{1, "a"}, {2, "b"}}
+	q := qb.NewNoDbQB().SetDialect(qb.POSTGRES).InsertMany("users", []string{"id", "name"}, rows).Upsert([]string{"id"}, []string{"name"})
+	q.Merge()
+	expected := `INSERT INTO "users" ( "id", "name" ) VALUES ( $1, $2 ), ( $3, $4 ) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`
+	if q.String() != expected {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	if !reflect.DeepEqual(q.Args(), []any{1, "a", 2, "b"}) {
+		t.Errorf("unexpected args: %v", q.Args())
+	}
+}
+
+func TestInsertManyErrors(t *testing.T) {
+	q := qb.NewNoDbQB().InsertMany("users", []string{"id", "name"}, [][]any{{1, "a"}, {2}})
+	if _, err := q.Exec(); !errors.As(err, &qb.ErrRowLength{}) {
+		t.Errorf("expected ErrRowLength, got %v", err)
+	}
+	q = qb.NewNoDbQB().InsertMany("users", []string{"id", "name"}, nil)
+	if _, err := q.Exec(); !errors.As(err, &qb.ErrEmptyInsert{}) {
+		t.Errorf("expected ErrEmptyInsert, got %v", err)
+	}
+	q = qb.NewNoDbQB().InsertMany("users", nil, [][]any{{1}})
+	if _, ok := q.Err().(qb.ErrEmptyInsert); !ok {
+		t.Errorf("expected ErrEmptyInsert, got %v", q.Err())
+	}
+}
+
+func TestSubqueryErr(t *testing.T) {
+	invalid := func() *qb.QB {
+		return qb.NewNoDbQB().SelectFrom("id", qb.Ident("bad table"))
+	}
+	queries := map[string]*qb.QB{
+		"from":   qb.NewNoDbQB().SelectFrom("*", qb.SQ(true, invalid())).As("t"),
+		"where":  qb.NewNoDbQB().SelectFrom("*", "users").Where(qb.Compare("id", qb.IN, qb.SQ(true, invalid()))),
+		"exists": qb.NewNoDbQB().SelectFrom("*", "users").Where(qb.Exists(qb.SQ(false, invalid()))),
+		"with":   qb.NewNoDbQB().With("t", qb.SQ(false, invalid())).SelectFrom("*", "t"),
+	}
+	for name, q := range queries {
+		if _, err := q.Exec(); !errors.As(err, &qb.ErrInvalidIdent{}) {
+			t.Errorf("%s: expected ErrInvalidIdent, got %v", name, err)
+		}
+	}
+}
+
+func TestChunkRows(t *testing.T) {
+	rows := [][]any{{1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 5}}
+	chunks := qb.ChunkRows(2, rows, 5)
+	if len(chunks) != 3 || len(chunks[0]) != 2 || len(chunks[2]) != 1 {
+		t.Errorf("unexpected chunks: %v", chunks)
+	}
+}
+
+func TestWithCTE(t *testing.T) {
+	active := qb.SQ(false, qb.NewNoDbQB().SelectFrom("id", "users").Where(qb.Compare("active", qb.EQUAL, true)))
+	big := qb.SQ(false, qb.NewNoDbQB().SelectFrom("user_id", "orders").Where(qb.Compare("total", qb.GREATER, 100)))
+	q := qb.NewNoDbQB().SetDialect(qb.POSTGRES).With("active_users", active).With("big_orders", big).
+		SelectFrom("*", "active_users").Where(qb.Compare("id", qb.NOT_EQUAL, 5))
+	q.Merge()
+	expected := "WITH active_users AS (SELECT id FROM users WHERE active = $1), big_orders AS (SELECT user_id FROM orders WHERE total > $2) SELECT * FROM active_users WHERE id != $3"
+	if q.String() != expected {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	if !reflect.DeepEqual(q.Args(), []any{true, 100, 5}) {
+		t.Errorf("unexpected args: %v", q.Args())
+	}
+}
+
+func TestWithRecursive(t *testing.T) {
+	base := qb.SQ(false, qb.NewNoDbQB().SelectFrom("1", "dual"))
+	tree := qb.SQ(false, qb.NewNoDbQB().SelectFrom("id, parent_id", "categories").Where(qb.Compare("parent_id", qb.EQUAL, 1)))
+	q := qb.NewNoDbQB().With("base", base).WithRecursive("tree(id, parent_id)", tree).SelectFrom("*", "tree")
+	q.Merge()
+	expected := "WITH RECURSIVE base AS (SELECT 1 FROM dual), tree(id, parent_id) AS (SELECT id, parent_id FROM categories WHERE parent_id = ?) SELECT * FROM tree"
+	if q.String() != expected {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+}
+
+func TestWindowFunctions(t *testing.T) {
+	q := qb.NewNoDbQB().SetDialect(qb.POSTGRES).Select(
+		"id,",
+		qb.RowNumber().Over(qb.PartitionBy("category"), qb.OrderBy(qb.DESC("price"))).As("rn"), ",",
+		qb.Lag("price", 1, 0).Over(qb.OrderBy("id")).As("prev_price"), ",",
+		qb.Sum("price").Over().As("total"),
+	).Custom("FROM items").Where(qb.Compare("price", qb.GREATER, 10))
+	q.Merge()
+	expected := "SELECT id, ROW_NUMBER() OVER (PARTITION BY category ORDER BY price DESC) AS rn , LAG(price, 1, $1) OVER (ORDER BY id) AS prev_price , SUM(price) OVER () AS total FROM items WHERE price > $2"
+	if q.String() != expected {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	if !reflect.DeepEqual(q.Args(), []any{0, 10}) {
+		t.Errorf("unexpected args: %v", q.Args())
+	}
+}
+
+func TestValidIdent(t *testing.T) {
+	valid := []string{"users", "users.name", "schema.users.id", "users.*", "_tmp$1"}
+	for i := 0; i < len(valid); i++ {
+		if !qb.ValidIdent(valid[i]) {
+			t.Errorf("%s must be valid", valid[i])
+		}
+	}
+	invalid := []string{"", "*", "1users", "users; DROP TABLE users", "name DESC", "users.", "a-b", "`users`"}
+	for i := 0; i < len(invalid); i++ {
+		if qb.ValidIdent(invalid[i]) {
+			t.Errorf("%s must be invalid", invalid[i])
+		}
+	}
+}
+
+func TestInvalidIdentError(t *testing.T) {
+	queries := []*qb.QB{
+		qb.NewNoDbQB().Update("users", map[string]any{"name = 'x', admin": 1}).Where(qb.Compare("id", qb.EQUAL, 1)),
+		qb.NewNoDbQB().Insert("users; DROP TABLE users", map[string]any{"name": "a"}),
+		qb.NewNoDbQB().Delete("users u"),
+		qb.NewNoDbQB().InsertMany("users", []string{"id", "name DESC"}, [][]any{{1, "a"}}),
+		qb.NewNoDbQB().Insert("users", map[string]any{"name": "a"}).Upsert([]string{"id"}, []string{"name, admin"}),
+	}
+	for i := 0; i < len(queries); i++ {
+		if _, ok := queries[i].Err().(qb.ErrInvalidIdent); !ok {
+			t.Errorf("query %d: expected ErrInvalidIdent, got %v", i, queries[i].Err())
+		}
+		if _, err := queries[i].Exec(); !errors.As(err, &qb.ErrInvalidIdent{}) {
+			t.Errorf("query %d: expected Exec to return ErrInvalidIdent, got %v", i, err)
+		}
+		if _, err := queries[i].Query(); !errors.As(err, &qb.ErrInvalidIdent{}) {
+			t.Errorf("query %d: expected Query to return ErrInvalidIdent, got %v", i, err)
+		}
+	}
+	selects := []*qb.QB{
+		qb.NewNoDbQB().Select(qb.Ident("name; DROP TABLE users")).Custom("FROM users"),
+		qb.NewNoDbQB().SelectFrom("*", qb.Ident("users u")),
+	}
+	for i := 0; i < len(selects); i++ {
+		if _, err := selects[i].Query(); !errors.As(err, &qb.ErrInvalidIdent{}) {
+			t.Errorf("select %d: expected ErrInvalidIdent, got %v", i, err)
+		}
+	}
+	union := qb.Union(qb.NewNoDbQB().Delete("a b"), qb.NewNoDbQB().SelectFrom("*", "users"))
+	if _, ok := union.Err().(qb.ErrInvalidIdent); !ok {
+		t.Errorf("expected the error to be passed to the union, got %v", union.Err())
+	}
+}
+
+func TestDataOperationQuoting(t *testing.T) {
+	q := qb.NewNoDbQB().SetDialect(qb.POSTGRES).Update("users", map[string]any{"name": "a", "age": 1}).Where(qb.Compare("id", qb.EQUAL, 2))
+	q.Merge()
+	if q.String() != `UPDATE "users" SET "age" = $1, "name" = $2 WHERE id = $3` {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	q = qb.NewNoDbQB().Delete("main.users")
+	q.Merge()
+	if q.String() != "DELETE FROM `main`.`users`" {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+}
+
+func TestIdentQuoting(t *testing.T) {
+	q := qb.NewNoDbQB().SetDialect(qb.POSTGRES).Select(qb.Ident("users.name")).Custom("FROM").Custom(qb.Ident("users").Quote(qb.POSTGRES))
+	q.Merge()
+	if q.String() != `SELECT "users"."name" FROM "users"` {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	q = qb.NewNoDbQB().SetDialect(qb.MYSQL).SelectFrom(qb.Ident("users.*"), qb.Ident("users"))
+	q.Merge()
+	if q.String() != "SELECT `users`.* FROM `users`" {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+}
+
+func TestSortAllowlist(t *testing.T) {
+	allowlist := qb.NewSortAllowlist("name", "price").Alias("created", "users.created_at").Default("-created")
+	sorts, err := allowlist.Parse("-price, name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := qb.NewNoDbQB().SelectFrom("*", "users").OrderBy(sorts...)
+	q.Merge()
+	if q.String() != "SELECT * FROM users ORDER BY price DESC, name ASC" {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	sorts, err = allowlist.Parse("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sorts, []string{"users.created_at DESC"}) {
+		t.Errorf("unexpected default sort: %v", sorts)
+	}
+	if _, err := allowlist.Parse("name,password; DROP TABLE users"); err == nil {
+		t.Error("expected ErrSortNotAllowed")
+	}
+}
+
+func TestCompareInArray(t *testing.T) {
+	q := qb.NewNoDbQB().SetDialect(qb.POSTGRES).SelectFrom("*", "users").Where(qb.Compare("id", qb.IN, qb.Array(1, 2, 3)))
+	q.Merge()
+	if q.String() != "SELECT * FROM users WHERE id IN ($1, $2, $3)" {
+		t.Errorf("unexpected query: %s", q.String())
+	}
+	if !reflect.DeepEqual(q.Args(), []any{1, 2, 3}) {
+		t.Errorf("unexpected args: %v", q.Args())
+	}
+}
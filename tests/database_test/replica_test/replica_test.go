@@ -0,0 +1,171 @@
+package replica_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/database/dbtest"
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+func newSqlite(t *testing.T, name string) *database.SqliteDatabase {
+	syncQ := database.NewSyncQueries()
+	db := database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, database.NewAsyncQueries(syncQ))
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec("CREATE TABLE node (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec("INSERT INTO node (name) VALUES (?)", name); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newGroup(t *testing.T, strategy database.ReplicaStrategy) (*database.ReplicaGroup, []*database.SqliteDatabase) {
+	primary := newSqlite(t, "primary")
+	r1 := newSqlite(t, "r1")
+	r2 := newSqlite(t, "r2")
+	group := database.NewReplicaGroup(primary, []interfaces.Database{r1, r2}, strategy)
+	return group, []*database.SqliteDatabase{primary, r1, r2}
+}
+
+func nodeName(t *testing.T, syncQ interfaces.SyncQ) string {
+	rows, err := syncQ.Query("SELECT name FROM node LIMIT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbutils.ParseString(rows[0]["name"])
+}
+
+func TestRoundRobin(t *testing.T) {
+	group, _ := newGroup(t, database.ROUND_ROBIN)
+	defer group.Close()
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		seen[nodeName(t, group.SyncQ())]++
+	}
+	if seen["r1"] != 2 || seen["r2"] != 2 {
+		t.Errorf("queries must be distributed between replicas, got %v", seen)
+	}
+}
+
+func TestExecGoesToPrimary(t *testing.T) {
+	group, dbs := newGroup(t, database.ROUND_ROBIN)
+	defer group.Close()
+	if _, err := group.SyncQ().Exec("INSERT INTO node (name) VALUES (?)", "written"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := dbs[0].SyncQ().Query("SELECT * FROM node WHERE name = ?", "written")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Error("exec must be executed by the primary database")
+	}
+}
+
+func TestWritingQueryGoesToPrimary(t *testing.T) {
+	group, dbs := newGroup(t, database.ROUND_ROBIN)
+	defer group.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := group.SyncQ().Query("INSERT INTO node (name) VALUES (?) RETURNING name", "written"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, db := range dbs {
+		rows, err := db.SyncQ().Query("SELECT * FROM node WHERE name = ?", "written")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := 0
+		if i == 0 {
+			expected = 2
+		}
+		if len(rows) != expected {
+			t.Errorf("database %d: expected %d written rows, got %d", i, expected, len(rows))
+		}
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	group, _ := newGroup(t, database.ROUND_ROBIN)
+	defer group.Close()
+	if name := nodeName(t, database.UsePrimary(group).SyncQ()); name != "primary" {
+		t.Errorf("UsePrimary must read from the primary database, got %s", name)
+	}
+	cursor, err := group.SyncQ().(interfaces.IterQuery).QueryIter(database.WithPrimary(context.Background()), "SELECT name FROM node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+	var name string
+	if !cursor.Next() {
+		t.Fatal(cursor.Err())
+	}
+	if err := cursor.Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "primary" {
+		t.Errorf("WithPrimary must read from the primary database, got %s", name)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	group, dbs := newGroup(t, database.LEAST_LATENCY)
+	defer group.Close()
+	if err := dbs[1].Close(); err != nil {
+		t.Fatal(err)
+	}
+	group.CheckHealth()
+	if group.HealthyReplicas() != 1 {
+		t.Fatalf("expected 1 healthy replica, got %d", group.HealthyReplicas())
+	}
+	if name := nodeName(t, group.SyncQ()); name != "r2" {
+		t.Errorf("expected r2, got %s", name)
+	}
+	if err := dbs[2].Close(); err != nil {
+		t.Fatal(err)
+	}
+	group.CheckHealth()
+	if name := nodeName(t, group.SyncQ()); name != "primary" {
+		t.Errorf("without healthy replicas, the primary database must be used, got %s", name)
+	}
+}
+
+func TestReplicaRetryDelay(t *testing.T) {
+	primary := newSqlite(t, "primary")
+	fake := dbtest.NewDefaultFakeDatabase()
+	group := database.NewReplicaGroup(primary, []interfaces.Database{fake}, database.ROUND_ROBIN)
+	defer group.Close()
+	group.SetRetryDelay(50 * time.Millisecond)
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	fake.On(`^SELECT`).Once().ReturnError(connRefused)
+	fake.On(`^SELECT`).ReturnRows(map[string]any{"name": "replica"})
+
+	if name := nodeName(t, group.SyncQ()); name != "primary" {
+		t.Errorf("after a connection error, the primary database must be used, got %s", name)
+	}
+	if group.HealthyReplicas() != 0 {
+		t.Fatal("expected the replica to be unhealthy")
+	}
+	if name := nodeName(t, group.SyncQ()); name != "primary" {
+		t.Errorf("the replica must not be used before the delay, got %s", name)
+	}
+	fake.AssertCallCount(t, "^SELECT", 1)
+
+	time.Sleep(60 * time.Millisecond)
+	if name := nodeName(t, group.SyncQ()); name != "replica" {
+		t.Errorf("the replica must be tried after the delay, got %s", name)
+	}
+	if group.HealthyReplicas() != 1 {
+		t.Error("expected the replica to be restored without the health check")
+	}
+}
@@ -0,0 +1,258 @@
+package schema_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/database/dbtest"
+	"github.com/uwine4850/foozy/pkg/database/migrate"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/database/schema"
+)
+
+type User struct {
+	Id    int    `db:"id" dbpk:"true" dbai:"true"`
+	Email string `db:"email" dbsize:"100" dbunique:"uq_users_email"`
+	Name  string `db:"name" dbtype:"text" dbnull:"true"`
+	Age   int    `db:"age" dbdefault:"0" dbindex:"idx_users_age_active"`
+	Admin bool   `db:"admin" dbindex:"idx_users_age_active"`
+	Skip  string
+}
+
+type Post struct {
+	Id     int `db:"id" dbpk:"true" dbai:"true"`
+	UserId int `db:"user_id" dbfk:"users.id"`
+}
+
+func TestFromStruct(t *testing.T) {
+	table, err := schema.FromStruct("users", &User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "CREATE TABLE IF NOT EXISTS users (id INT(11) NOT NULL AUTO_INCREMENT PRIMARY KEY, " +
+		"email VARCHAR(100) NOT NULL, name TEXT NULL, age INT(11) NOT NULL DEFAULT 0, admin BOOLEAN NOT NULL );" +
+		" CREATE UNIQUE INDEX uq_users_email ON users (email);" +
+		" CREATE INDEX idx_users_age_active ON users (age, admin);"
+	if res := table.TableQB(qb.MYSQL).String(); res != expected {
+		t.Errorf("unexpected query: %s", res)
+	}
+}
+
+func TestFromStructFK(t *testing.T) {
+	table, err := schema.FromStruct("posts", Post{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.ForeignKeys) != 1 || table.ForeignKeys[0].RefTable != "users" || table.ForeignKeys[0].RefColumn != "id" {
+		t.Fatalf("unexpected foreign keys %v", table.ForeignKeys)
+	}
+	expected := "CREATE TABLE IF NOT EXISTS posts (id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, user_id INTEGER NOT NULL, " +
+		"CONSTRAINT fk_posts_user_id FOREIGN KEY (user_id) REFERENCES users(id) );"
+	if res := table.TableQB(qb.SQLITE).String(); res != expected {
+		t.Errorf("unexpected query: %s", res)
+	}
+
+	db := newSqliteDb(t)
+	if _, err := db.SyncQ().Exec("CREATE TABLE users (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec(table.TableQB(qb.SQLITE).String()); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := schema.ReadForeignKeys(db.SyncQ(), qb.SQLITE, "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0] != "users" {
+		t.Errorf("the foreign key was not created, got %v", refs)
+	}
+}
+
+func TestTypeInference(t *testing.T) {
+	type Model struct {
+		Big     int64           `db:"big"`
+		Created time.Time       `db:"created"`
+		Data    []byte          `db:"data"`
+		Meta    map[string]any  `db:"meta"`
+		Price   decimal.Decimal `db:"price" dbsize:"8,3"`
+		Status  string          `db:"status" dbtype:"enum(new, done)"`
+		Uid     string          `db:"uid" dbtype:"uuid"`
+	}
+	table, err := schema.FromStruct("models", &Model{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"BIGINT", "DATETIME", "BLOB", "JSON", "DECIMAL(8, 3)", "ENUM('new', 'done')", "CHAR(36)"}
+	for i := 0; i < len(expected); i++ {
+		if res := qb.MYSQL.TypeName(table.Cols[i].Type); res != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], res)
+		}
+	}
+}
+
+func TestNullableTypeInference(t *testing.T) {
+	type Model struct {
+		Parent   *int64              `db:"parent"`
+		Name     sql.NullString      `db:"name"`
+		Count    sql.NullInt32       `db:"count"`
+		Active   sql.NullBool        `db:"active"`
+		Deleted  *time.Time          `db:"deleted"`
+		Updated  sql.NullTime        `db:"updated"`
+		Price    decimal.NullDecimal `db:"price"`
+		Required int                 `db:"required"`
+	}
+	table, err := schema.FromStruct("models", &Model{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"BIGINT", "VARCHAR(255)", "INT(11)", "BOOLEAN", "DATETIME", "DATETIME", "DECIMAL(10, 2)", "INT(11)"}
+	for i := 0; i < len(expected); i++ {
+		if res := qb.MYSQL.TypeName(table.Cols[i].Type); res != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], res)
+		}
+		if nullable := i != len(expected)-1; table.Cols[i].Null != nullable {
+			t.Errorf("the %s column must have Null %v", table.Cols[i].Name, nullable)
+		}
+	}
+}
+
+func TestInvalidTag(t *testing.T) {
+	type Bad struct {
+		Price float64 `db:"price"`
+	}
+	if _, err := schema.FromStruct("bad", &Bad{}); err == nil {
+		t.Error("expected an error for the unknown type")
+	}
+}
+
+func newSqliteDb(t *testing.T) *database.SqliteDatabase {
+	syncQ := database.NewSyncQueries()
+	db := database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, database.NewAsyncQueries(syncQ))
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDiff(t *testing.T) {
+	db := newSqliteDb(t)
+	table, err := schema.FromStruct("users", &User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := schema.Diff(db.SyncQ(), qb.SQLITE, table, schema.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Up) != 1 || !strings.HasPrefix(changes.Up[0], "CREATE TABLE") {
+		t.Fatalf("unexpected changes %v", changes.Up)
+	}
+	dir := t.TempDir()
+	if _, _, err := changes.WriteMigration(dir, "create_users"); err != nil {
+		t.Fatal(err)
+	}
+	m := migrate.NewMigrator(db, qb.SQLITE)
+	if err := m.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err = schema.Diff(db.SyncQ(), qb.SQLITE, table, schema.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Empty() {
+		t.Errorf("the schema must be up to date, got %v", changes.Up)
+	}
+
+	if _, err := db.SyncQ().Exec("ALTER TABLE users ADD COLUMN old TEXT"); err != nil {
+		t.Fatal(err)
+	}
+	table.Cols = append(table.Cols, qb.Col{Name: "city", Type: qb.T{}.Varchar(50), Null: true})
+	changes, err = schema.Diff(db.SyncQ(), qb.SQLITE, table, schema.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Up) != 1 || changes.Up[0] != "ALTER TABLE users ADD COLUMN city VARCHAR(50) NULL;" {
+		t.Errorf("extra columns must not be removed by default, got %v", changes.Up)
+	}
+	if len(changes.Down) != 1 || len(changes.Extra) != 1 || changes.Extra[0] != "old" {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+
+	changes, err = schema.Diff(db.SyncQ(), qb.SQLITE, table, schema.DiffOptions{DropExtra: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedUp := []string{"ALTER TABLE users ADD COLUMN city VARCHAR(50) NULL;", "ALTER TABLE users DROP COLUMN old;"}
+	if strings.Join(changes.Up, "|") != strings.Join(expectedUp, "|") {
+		t.Errorf("unexpected up changes %v", changes.Up)
+	}
+	if !strings.HasPrefix(changes.Down[0], "--") || changes.Down[1] != "ALTER TABLE users DROP COLUMN city;" {
+		t.Errorf("unexpected down changes %v", changes.Down)
+	}
+	for i := 0; i < len(changes.Up); i++ {
+		if _, err := db.SyncQ().Exec(changes.Up[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiffModifiedColumns(t *testing.T) {
+	db := newSqliteDb(t)
+	if _, err := db.SyncQ().Exec("CREATE TABLE users (id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, " +
+		"email VARCHAR(50) NOT NULL, name TEXT NOT NULL, age INTEGER NOT NULL DEFAULT 0, admin BOOLEAN NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	table, err := schema.FromStruct("users", &User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := schema.Diff(db.SyncQ(), qb.SQLITE, table, schema.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sqlite cannot change columns, so they are only listed.
+	if !changes.Empty() || strings.Join(changes.Modified, ",") != "email,name" {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+}
+
+func TestDiffModifiedColumnsMysql(t *testing.T) {
+	fake := dbtest.NewDefaultFakeDatabase()
+	defer fake.Close()
+	fake.On(`information_schema\.columns`).ReturnRows(
+		map[string]any{"name": []byte("id"), "type": []byte("int"), "nullable": []byte("NO")},
+		map[string]any{"name": []byte("email"), "type": []byte("varchar(100)"), "nullable": []byte("NO")},
+		map[string]any{"name": []byte("name"), "type": []byte("varchar(255)"), "nullable": []byte("YES")},
+		map[string]any{"name": []byte("age"), "type": []byte("int(11)"), "nullable": []byte("YES")},
+		map[string]any{"name": []byte("admin"), "type": []byte("tinyint(1)"), "nullable": []byte("NO")},
+	)
+	table, err := schema.FromStruct("users", &User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := schema.Diff(fake.SyncQ(), qb.MYSQL, table, schema.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedUp := []string{
+		"ALTER TABLE users MODIFY COLUMN name TEXT NULL;",
+		"ALTER TABLE users MODIFY COLUMN age INT(11) NOT NULL DEFAULT 0;",
+	}
+	if strings.Join(changes.Up, "|") != strings.Join(expectedUp, "|") {
+		t.Errorf("unexpected up changes %q", changes.Up)
+	}
+	if strings.Join(changes.Modified, ",") != "name,age" || len(changes.Down) != 2 || !strings.HasPrefix(changes.Down[0], "--") {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+}
@@ -0,0 +1,233 @@
+package seed_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/database/dbtest"
+	"github.com/uwine4850/foozy/pkg/database/dbutils"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/database/seed"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+func newDb(t *testing.T) *database.SqliteDatabase {
+	syncQ := database.NewSyncQueries()
+	db := database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, database.NewAsyncQueries(syncQ))
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, query := range []string{
+		"CREATE TABLE posts (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER NOT NULL REFERENCES users (id), title TEXT NOT NULL)",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, email TEXT)",
+	} {
+		if _, err := db.SyncQ().Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func count(t *testing.T, syncQ interfaces.SyncQ, table string) int {
+	res, err := syncQ.Query("SELECT COUNT(*) AS total FROM " + table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := dbutils.ParseInt(res[0]["total"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// The posts are written before the users, the loader must insert the users first.
+const fixturesYAML = `
+posts:
+  - id: 1
+    user_id: 2
+    title: first
+  - id: 2
+    user_id: 2
+    title: second
+users:
+  - id: 1
+    name: alice
+  - id: 2
+    name: bob
+    email: bob@example.com
+`
+
+func TestLoadFixtures(t *testing.T) {
+	db := newDb(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte(fixturesYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"users": [{"id": 3, "name": "carol"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := seed.LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 3 || fixtures[0].Table != "posts" || fixtures[2].Table != "users" {
+		t.Fatalf("unexpected fixtures %v", fixtures)
+	}
+	loader := seed.NewLoader(db, qb.SQLITE).ResetAutoIncrement()
+	if err := loader.Load(fixtures...); err != nil {
+		t.Fatal(err)
+	}
+	if count(t, db.SyncQ(), "users") != 3 || count(t, db.SyncQ(), "posts") != 2 {
+		t.Fatal("fixtures are not loaded")
+	}
+	// Loading again replaces the rows.
+	if err := loader.Load(fixtures...); err != nil {
+		t.Fatal(err)
+	}
+	if count(t, db.SyncQ(), "users") != 3 {
+		t.Error("the table must be cleared before loading")
+	}
+	res, err := db.SyncQ().Exec("INSERT INTO users (name) VALUES (?)", "dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["insertID"] != int64(4) {
+		t.Errorf("expected id 4 after the fixtures, got %v", res["insertID"])
+	}
+
+	if err := loader.Truncate("users", "posts"); err != nil {
+		t.Fatal(err)
+	}
+	res, err = db.SyncQ().Exec("INSERT INTO users (name) VALUES (?)", "erin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["insertID"] != int64(1) {
+		t.Errorf("expected the auto increment to be reset, got %v", res["insertID"])
+	}
+}
+
+func TestLoadInRolledBackTransaction(t *testing.T) {
+	db := newDb(t)
+	fixtures, err := seed.ParseFixtures([]byte(fixturesYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec("INSERT INTO users (id, name) VALUES (?, ?)", 10, "existing"); err != nil {
+		t.Fatal(err)
+	}
+	errRollback := errors.New("rollback")
+	err = database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+		if err := seed.NewLoader(tx, qb.SQLITE).ResetAutoIncrement().Load(fixtures...); err != nil {
+			return err
+		}
+		if count(t, tx.SyncQ(), "posts") != 2 {
+			t.Error("fixtures must be visible inside the transaction")
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	if count(t, db.SyncQ(), "users") != 1 || count(t, db.SyncQ(), "posts") != 0 {
+		t.Error("fixtures must be rolled back")
+	}
+}
+
+func newMysqlFake(t *testing.T) *dbtest.FakeDatabase {
+	fake := dbtest.NewDefaultFakeDatabase()
+	t.Cleanup(func() { fake.Close() })
+	fake.On(`^SELECT`).ReturnRows()
+	fake.On(`^(DELETE|INSERT|ALTER)`).ReturnResult(0, 1)
+	return fake
+}
+
+func TestMysqlResetInTransaction(t *testing.T) {
+	fixtures, err := seed.ParseFixtures([]byte(fixturesYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := newMysqlFake(t)
+	errRollback := errors.New("rollback")
+	err = database.WithTransaction(fake, func(tx interfaces.DatabaseTransaction) error {
+		if err := seed.NewLoader(tx, qb.MYSQL).ResetAutoIncrement().Load(fixtures...); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	// ALTER TABLE would commit the transaction of the caller.
+	fake.AssertCallCount(t, `^ALTER`, 0)
+	if fake.Rollbacks() != 1 || fake.Commits() != 0 {
+		t.Errorf("expected the loading to be rolled back, got %d rollbacks and %d commits", fake.Rollbacks(), fake.Commits())
+	}
+
+	fake = newMysqlFake(t)
+	if err := seed.NewLoader(fake, qb.MYSQL).Load(fixtures...); err != nil {
+		t.Fatal(err)
+	}
+	fake.AssertCallCount(t, `^ALTER`, 0)
+	if err := seed.NewLoader(fake, qb.MYSQL).ResetAutoIncrement().Load(fixtures...); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	for i := 0; i < len(calls); i++ {
+		if strings.HasPrefix(calls[i].Query, "ALTER") && calls[i].Tx {
+			t.Errorf("ALTER TABLE must be executed after the commit: %s", calls[i].Query)
+		}
+	}
+	fake.AssertCallCount(t, `^ALTER`, 2)
+}
+
+func TestInvalidFixtures(t *testing.T) {
+	if _, err := seed.ParseFixtures([]byte("- a\n- b")); !errors.As(err, &seed.ErrInvalidFixture{}) {
+		t.Errorf("expected ErrInvalidFixture, got %v", err)
+	}
+	if _, err := seed.ParseFixtures([]byte("users:\n  - name; DROP TABLE users: x")); !errors.As(err, &qb.ErrInvalidIdent{}) {
+		t.Errorf("expected ErrInvalidIdent, got %v", err)
+	}
+	if _, err := seed.LoadFile("fixtures.txt"); !errors.As(err, &seed.ErrInvalidFixture{}) {
+		t.Errorf("expected ErrInvalidFixture, got %v", err)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	db := newDb(t)
+	runner := seed.NewRunner(db, qb.SQLITE)
+	errFailed := errors.New("failed")
+	err := runner.Add(
+		seed.Seeder{Name: "users", Run: func(tx interfaces.DatabaseTransaction) error {
+			_, err := tx.SyncQ().Exec("INSERT INTO users (name) VALUES (?)", "alice")
+			return err
+		}},
+		seed.Seeder{Name: "broken", Run: func(tx interfaces.DatabaseTransaction) error {
+			if _, err := tx.SyncQ().Exec("INSERT INTO users (name) VALUES (?)", "bob"); err != nil {
+				return err
+			}
+			return errFailed
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Add(seed.Seeder{Name: "users"}); !errors.As(err, &seed.ErrDuplicateSeeder{}) {
+		t.Errorf("expected ErrDuplicateSeeder, got %v", err)
+	}
+	done, err := runner.Run()
+	if !errors.Is(err, errFailed) || len(done) != 1 || done[0] != "users" {
+		t.Fatalf("unexpected result %v %v", done, err)
+	}
+	if count(t, db.SyncQ(), "users") != 1 {
+		t.Error("the failed seeder must be rolled back")
+	}
+	if _, err := runner.Run("unknown"); !errors.As(err, &seed.ErrSeederNotFound{}) {
+		t.Errorf("expected ErrSeederNotFound, got %v", err)
+	}
+}
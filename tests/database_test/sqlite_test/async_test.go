@@ -0,0 +1,98 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+)
+
+func newAsyncQueries(t *testing.T) *database.AsyncQueries {
+	asyncQ, err := db.NewAsyncQ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asyncQ.(*database.AsyncQueries)
+}
+
+func TestAsyncLoadInto(t *testing.T) {
+	if _, err := db.SyncQ().Exec("INSERT INTO items (name, price, ok) VALUES (?, ?, ?), (?, ?, ?)", "async1", 1, true, "async2", 2, false); err != nil {
+		t.Fatal(err)
+	}
+	asyncQ := newAsyncQueries(t).SetLimit(1)
+	asyncQ.Query("items", "SELECT id, name, price, ok FROM items WHERE name LIKE ? ORDER BY id", "async%")
+	asyncQ.Query("count", "SELECT COUNT(*) AS total FROM items")
+	if err := asyncQ.WaitErr(); err != nil {
+		t.Fatal(err)
+	}
+	var items []scanItem
+	if err := database.LoadInto(asyncQ, "items", &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Name != "async1" || items[1].Price != 2 {
+		t.Errorf("unexpected items %v", items)
+	}
+	if err := database.LoadInto(asyncQ, "unknown", &items); !errors.As(err, &database.ErrAsyncKeyNotFound{}) {
+		t.Errorf("expected ErrAsyncKeyNotFound, got %v", err)
+	}
+}
+
+func TestAsyncWaitErr(t *testing.T) {
+	asyncQ := newAsyncQueries(t)
+	asyncQ.Query("ok", "SELECT 1")
+	asyncQ.Query("bad", "SELECT * FROM unknown_table")
+	err := asyncQ.WaitErr()
+	var queryErr database.ErrAsyncQuery
+	if !errors.As(err, &queryErr) || queryErr.Key != "bad" {
+		t.Fatalf("expected ErrAsyncQuery of the bad key, got %v", err)
+	}
+	if len(asyncQ.Errs()) != 1 {
+		t.Errorf("expected 1 error, got %v", asyncQ.Errs())
+	}
+	var items []scanItem
+	if err := database.LoadInto(asyncQ, "bad", &items); !errors.As(err, &database.ErrAsyncQuery{}) {
+		t.Errorf("expected ErrAsyncQuery, got %v", err)
+	}
+	asyncQ.Clear()
+	asyncQ.Query("ok", "SELECT 1")
+	if err := asyncQ.WaitErr(); err != nil {
+		t.Errorf("expected no error after Clear, got %v", err)
+	}
+}
+
+func TestAsyncCancelOnError(t *testing.T) {
+	asyncQ := newAsyncQueries(t).CancelOnError()
+	asyncQ.Exec("bad", "INSERT INTO unknown_table (id) VALUES (1)")
+	if err := asyncQ.WaitErr(); err == nil {
+		t.Fatal("expected error")
+	}
+	asyncQ.Exec("insert", "INSERT INTO items (name) VALUES (?)", "canceled")
+	asyncQ.Wait()
+	res, ok := asyncQ.LoadAsyncRes("insert")
+	if !ok || !errors.As(res.Error, &database.ErrAsyncCanceled{}) || !errors.Is(res.Error, context.Canceled) {
+		t.Fatalf("expected ErrAsyncCanceled, got %v", res)
+	}
+	rows, err := db.SyncQ().Query("SELECT id FROM items WHERE name = ?", "canceled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Error("canceled query must not be executed")
+	}
+}
+
+func TestAsyncWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	asyncQ := newAsyncQueries(t).SetLimit(2).WithContext(ctx)
+	for _, key := range []string{"a", "b", "c"} {
+		asyncQ.Query(key, "SELECT 1")
+	}
+	if err := asyncQ.WaitErr(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(asyncQ.Errs()) != 3 {
+		t.Errorf("expected 3 errors, got %d", len(asyncQ.Errs()))
+	}
+}
@@ -0,0 +1,68 @@
+package sqlite_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+)
+
+func TestBulkInsertUpsert(t *testing.T) {
+	if _, err := db.SyncQ().Exec("CREATE TABLE bulk_items (code TEXT PRIMARY KEY, price INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	var rows [][]any
+	for i := 0; i < 25; i++ {
+		rows = append(rows, []any{fmt.Sprintf("code%d", i), i})
+	}
+	opts := qb.BulkOptions{Dialect: qb.SQLITE, MaxPlaceholders: 10}
+	n, err := qb.BulkInsert(db.SyncQ(), "bulk_items", []string{"code", "price"}, rows, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 25 {
+		t.Errorf("expected 25 inserted rows, got %d", n)
+	}
+
+	opts.ConflictColumns = []string{"code"}
+	opts.UpdateColumns = []string{"price"}
+	update := [][]any{{"code0", 100}, {"code_new", 5}}
+	if _, err := qb.BulkInsert(db.SyncQ(), "bulk_items", []string{"code", "price"}, update, opts); err != nil {
+		t.Fatal(err)
+	}
+	type bulkItem struct {
+		Code  string `db:"code"`
+		Price int    `db:"price"`
+	}
+	items, err := database.QueryInto[bulkItem](db.SyncQ(), "SELECT * FROM bulk_items WHERE code IN (?, ?)", "code0", "code_new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	for _, item := range items {
+		if item.Code == "code0" && item.Price != 100 {
+			t.Errorf("the existing row must be updated, got %v", item)
+		}
+	}
+
+	// Without the update columns the existing rows are skipped.
+	opts.UpdateColumns = nil
+	update = [][]any{{"code0", 200}, {"code_skip", 7}}
+	n, err = qb.BulkInsert(db.SyncQ(), "bulk_items", []string{"code", "price"}, update, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 inserted row, got %d", n)
+	}
+	items, err = database.QueryInto[bulkItem](db.SyncQ(), "SELECT * FROM bulk_items WHERE code = ?", "code0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Price != 100 {
+		t.Errorf("the existing row must not be changed, got %v", items)
+	}
+}
@@ -0,0 +1,117 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+	"github.com/uwine4850/foozy/pkg/mapper"
+)
+
+func insertCursorItems(t *testing.T) {
+	if _, err := db.SyncQ().Exec("INSERT INTO items (name, price, ok) VALUES (?, ?, ?), (?, ?, ?), (?, ?, ?)",
+		"cursor1", 1, true, "cursor2", 2, false, "cursor3", 3, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryIter(t *testing.T) {
+	insertCursorItems(t)
+	cursor, err := db.SyncQ().(interfaces.IterQuery).QueryIter(context.Background(), "SELECT name, price FROM items WHERE name LIKE ? ORDER BY id", "cursor%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+	sum := 0
+	count := 0
+	for cursor.Next() {
+		var name string
+		var price int
+		if err := cursor.Scan(&name, &price); err != nil {
+			t.Fatal(err)
+		}
+		sum += price
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || sum != 6 {
+		t.Errorf("expected 3 rows with sum 6, got %d rows with sum %d", count, sum)
+	}
+}
+
+func TestQueryIterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cursor, err := db.SyncQ().(interfaces.IterQuery).QueryIter(ctx, "SELECT id FROM items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+	if !cursor.Next() {
+		t.Fatal("expected at least one row")
+	}
+	cancel()
+	if cursor.Next() {
+		t.Error("the cursor must stop after the context is canceled")
+	}
+	if !errors.Is(cursor.Err(), context.Canceled) {
+		t.Errorf("expected context.Canceled error, got %v", cursor.Err())
+	}
+	// The connection must be released, otherwise the in-memory database will be blocked.
+	if _, err := db.SyncQ().Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQBQueryIterEach(t *testing.T) {
+	cursor, err := qb.NewSyncQB(db.SyncQ()).SelectFrom("*", "items").Where(qb.Compare("name", qb.EQUAL, "cursor2")).QueryIter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []Item
+	if err := cursor.Each(func(row map[string]interface{}) error {
+		var item Item
+		if err := mapper.FillStructFromDb(&item, &row); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Price != 2 {
+		t.Errorf("unexpected items %v", items)
+	}
+}
+
+func TestTransactionQueryIter(t *testing.T) {
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	defer tx.RollBackTransaction()
+	if _, err := tx.SyncQ().Exec("INSERT INTO items (name) VALUES (?)", "cursor_tx"); err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := tx.SyncQ().(interfaces.IterQuery).QueryIter(context.Background(), "SELECT * FROM items WHERE name = ?", "cursor_tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+	count := 0
+	if err := mapper.ScanRowsInto(cursor.Rows(), func(item *Item) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 row, got %d", count)
+	}
+}
@@ -0,0 +1,205 @@
+package sqlite_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/config"
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+func TestQueryHooks(t *testing.T) {
+	var events []database.QueryEvent
+	hook := database.AfterQueryFunc(func(ctx context.Context, event *database.QueryEvent) {
+		events = append(events, *event)
+	})
+	hooked, err := database.WithHooks(db, hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hooked.SyncQ().Exec("INSERT INTO items (name) VALUES (?)", "hook"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hooked.SyncQ().Query("SELECT * FROM items WHERE name = ?", "hook"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hooked.SyncQ().Query("SELECT * FROM not_exists"); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if !events[0].Exec || events[0].Rows != 1 || events[0].Args[0] != "hook" {
+		t.Errorf("unexpected exec event %+v", events[0])
+	}
+	if events[1].Exec || events[1].Rows != 1 || events[1].Duration <= 0 {
+		t.Errorf("unexpected query event %+v", events[1])
+	}
+	if events[2].Err == nil {
+		t.Error("the error must be passed to the hook")
+	}
+	// The original database does not call the hooks.
+	if _, err := db.SyncQ().Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Error("the hooks must not be added to the original database")
+	}
+}
+
+func TestQueryCounter(t *testing.T) {
+	counter := database.NewQueryCounter()
+	hooked, err := database.WithHooks(db, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := hooked.SyncQ().Query("SELECT * FROM items WHERE id = ?", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.WithTransaction(hooked, func(tx interfaces.DatabaseTransaction) error {
+		_, err := tx.SyncQ().Query("SELECT 1")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if counter.Count() != 4 {
+		t.Errorf("expected 4 queries, got %d", counter.Count())
+	}
+	expected := map[string]int{"SELECT * FROM items WHERE id = ?": 3}
+	if repeated := counter.Repeated(2); !reflect.DeepEqual(repeated, expected) {
+		t.Errorf("expected %v, got %v", expected, repeated)
+	}
+}
+
+func TestLogHookRedaction(t *testing.T) {
+	var messages []string
+	hooked, err := database.WithHooks(db, &database.LogHook{Write: func(message string) {
+		messages = append(messages, message)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hooked.SyncQ().Exec("INSERT INTO auth (username, password) VALUES (?, ?)", "hook_user", "secret_pass"); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if strings.Contains(messages[0], "secret_pass") || !strings.Contains(messages[0], "hook_user") {
+		t.Errorf("the password must be redacted: %s", messages[0])
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	args := database.RedactArgs("SELECT * FROM auth WHERE username = ? AND `password` = ? AND note = 'a?'", []any{"user", "pass"}, database.DEFAULT_SENSITIVE_COLUMNS)
+	if args[0] != "user" || args[1] != database.REDACTED {
+		t.Errorf("unexpected args %v", args)
+	}
+	args = database.RedactArgs("UPDATE auth SET api_token = ? WHERE id = ?", []any{"tok", 1}, database.DEFAULT_SENSITIVE_COLUMNS)
+	if args[0] != database.REDACTED || args[1] != 1 {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestRedactArgsLists(t *testing.T) {
+	r := database.REDACTED
+	cases := []struct {
+		query    string
+		args     []any
+		expected []any
+	}{
+		{
+			"SELECT * FROM auth WHERE password IN (?, ?, ?) AND id = ?",
+			[]any{"p1", "p2", "p3", 1}, []any{r, r, r, 1},
+		},
+		{
+			"SELECT * FROM auth WHERE a.password NOT IN (?, ?) OR name NOT LIKE ?",
+			[]any{"p1", "p2", "n"}, []any{r, r, "n"},
+		},
+		{
+			"UPDATE auth SET password = LOWER(?), name = ? WHERE token = CONCAT(?, ?) AND id IN (?, ?)",
+			[]any{"p", "n", "t1", "t2", 1, 2}, []any{r, "n", r, r, 1, 2},
+		},
+		{
+			"SELECT * FROM auth WHERE password = hash AND id > ? AND `secret_key` <> ?",
+			[]any{1, "s"}, []any{1, r},
+		},
+		{
+			"SELECT * FROM api WHERE key = ? AND monkey = ?",
+			[]any{"k", "m"}, []any{"k", "m"},
+		},
+	}
+	for i := 0; i < len(cases); i++ {
+		args := database.RedactArgs(cases[i].query, cases[i].args, database.DEFAULT_SENSITIVE_COLUMNS)
+		if !reflect.DeepEqual(args, cases[i].expected) {
+			t.Errorf("%s: expected %v, got %v", cases[i].query, cases[i].expected, args)
+		}
+	}
+}
+
+func TestRedactArgsInsert(t *testing.T) {
+	r := database.REDACTED
+	cases := []struct {
+		query    string
+		args     []any
+		expected []any
+	}{
+		{
+			"INSERT INTO auth (username, password) VALUES (?, ?), (?, ?)",
+			[]any{"a", "p1", "b", "p2"}, []any{"a", r, "b", r},
+		},
+		{
+			"INSERT INTO auth (username, role, password) VALUES (?, 'user', ?), (LOWER(?), 'admin', ?)",
+			[]any{"a", "p1", "b", "p2"}, []any{"a", r, "b", r},
+		},
+		{
+			"INSERT INTO auth (username, password) VALUES (?, ?) ON DUPLICATE KEY UPDATE password = ?, username = ?",
+			[]any{"a", "p1", "p2", "b"}, []any{"a", r, r, "b"},
+		},
+		{
+			"INSERT INTO auth (username, password) VALUES (?, ?, ?), (?, ?)",
+			[]any{"a", "p1", "x", "b", "p2"}, []any{r, r, r, "b", r},
+		},
+		{
+			"INSERT INTO auth VALUES (?, ?)",
+			[]any{"a", "p1"}, []any{r, r},
+		},
+		{
+			"INSERT INTO auth (username, password) SELECT ?, ?",
+			[]any{"a", "p1"}, []any{r, r},
+		},
+	}
+	for i := 0; i < len(cases); i++ {
+		args := database.RedactArgs(cases[i].query, cases[i].args, database.DEFAULT_SENSITIVE_COLUMNS)
+		if !reflect.DeepEqual(args, cases[i].expected) {
+			t.Errorf("%s: expected %v, got %v", cases[i].query, cases[i].expected, args)
+		}
+	}
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	config.Cnf().SetLoadPath("../../common/cnf/config.yaml")
+	logPath := filepath.Join(t.TempDir(), "slow.log")
+	hooked, err := database.WithHooks(db, &database.SlowQueryHook{Threshold: time.Nanosecond, LogPath: logPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hooked.SyncQ().Query("SELECT * FROM items WHERE name = ?", "slow_secret"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "slow query") || strings.Contains(string(data), "slow_secret") {
+		t.Errorf("unexpected slow query log: %s", data)
+	}
+}
@@ -0,0 +1,270 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+type Note struct {
+	_         struct{}  `dbtable:"notes"`
+	Id        int       `db:"id"`
+	Text      string    `db:"text"`
+	CreatedAt time.Time `db:"created_at" dbauto:"created"`
+	UpdatedAt time.Time `db:"updated_at" dbauto:"updated"`
+	DeletedAt time.Time `db:"deleted_at" dbauto:"deleted"`
+	Version   int       `db:"version" dbauto:"version"`
+}
+
+func newNoteRepository(t *testing.T) *database.Repository[Note] {
+	if _, err := db.SyncQ().Exec("CREATE TABLE IF NOT EXISTS notes (id INTEGER PRIMARY KEY AUTOINCREMENT, text TEXT, " +
+		"created_at DATETIME, updated_at DATETIME, deleted_at DATETIME NULL, version INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec("DELETE FROM notes"); err != nil {
+		t.Fatal(err)
+	}
+	repository, err := database.NewRepository[Note](db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repository.SetDialect(qb.SQLITE)
+}
+
+func TestTimestamps(t *testing.T) {
+	notes := newNoteRepository(t)
+	note := Note{Text: "a"}
+	if err := notes.Create(&note); err != nil {
+		t.Fatal(err)
+	}
+	if note.CreatedAt.IsZero() || note.UpdatedAt.IsZero() || note.Version != 1 {
+		t.Fatalf("auto fields must be set, got %+v", note)
+	}
+	created := note.UpdatedAt
+	time.Sleep(10 * time.Millisecond)
+	note.Text = "b"
+	if err := notes.Update(&note, "text"); err != nil {
+		t.Fatal(err)
+	}
+	found, err := notes.Get(note.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Text != "b" || found.Version != 2 || !found.UpdatedAt.After(created) || !found.CreatedAt.Equal(note.CreatedAt) {
+		t.Errorf("unexpected note %+v", found)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	notes := newNoteRepository(t)
+	kept := Note{Text: "kept"}
+	deleted := Note{Text: "deleted"}
+	if err := notes.Create(&kept); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.Create(&deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.Delete(deleted.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notes.Get(deleted.Id); !errors.As(err, &database.ErrNotFound{}) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := notes.Delete(deleted.Id); !errors.As(err, &database.ErrNotFound{}) {
+		t.Errorf("expected ErrNotFound on the second delete, got %v", err)
+	}
+	items, err := notes.Find(qb.Compare("text", qb.EQUAL, "kept"), qb.OR, qb.Compare("text", qb.EQUAL, "deleted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Id != kept.Id {
+		t.Errorf("the deleted note must be skipped, got %+v", items)
+	}
+	all, err := notes.WithTrashed().Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trashed, err := notes.OnlyTrashed().Find()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all != 2 || len(trashed) != 1 || trashed[0].DeletedAt.IsZero() {
+		t.Errorf("unexpected trashed notes %d %+v", all, trashed)
+	}
+	if err := notes.Restore(deleted.Id); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := notes.Count(); count != 2 {
+		t.Errorf("the note must be restored, count %d", count)
+	}
+	if err := notes.ForceDelete(deleted.Id); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := notes.WithTrashed().Count(); count != 1 {
+		t.Errorf("the note must be deleted, count %d", count)
+	}
+}
+
+func TestUpdateSoftDeleted(t *testing.T) {
+	notes := newNoteRepository(t)
+	note := Note{Text: "a"}
+	if err := notes.Create(&note); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.Delete(note.Id); err != nil {
+		t.Fatal(err)
+	}
+	note.Text = "b"
+	if err := notes.Update(&note, "text"); !errors.As(err, &database.ErrNotFound{}) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if note.Version != 1 {
+		t.Errorf("the version must be restored, got %d", note.Version)
+	}
+	found, err := notes.WithTrashed().Get(note.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Text != "a" {
+		t.Errorf("the soft deleted note must not be updated, got %+v", found)
+	}
+	if err := notes.WithTrashed().Update(&note, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := notes.WithTrashed().Get(note.Id); found.Text != "b" {
+		t.Errorf("WithTrashed must update the soft deleted note, got %+v", found)
+	}
+}
+
+func TestVersionConflict(t *testing.T) {
+	notes := newNoteRepository(t)
+	note := Note{Text: "a"}
+	if err := notes.Create(&note); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := notes.Get(note.Id)
+	second, _ := notes.Get(note.Id)
+	first.Text = "first"
+	if err := notes.Update(first); err != nil {
+		t.Fatal(err)
+	}
+	second.Text = "second"
+	err := notes.Update(second)
+	if !errors.As(err, &database.ErrVersionConflict{}) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if second.Version != 1 {
+		t.Errorf("the version must be restored after the conflict, got %d", second.Version)
+	}
+	found, _ := notes.Get(note.Id)
+	if found.Text != "first" || found.Version != 2 {
+		t.Errorf("unexpected note %+v", found)
+	}
+}
+
+func TestVersionConflictInTransaction(t *testing.T) {
+	notes := newNoteRepository(t)
+	note := Note{Text: "a"}
+	if err := notes.Create(&note); err != nil {
+		t.Fatal(err)
+	}
+	stale, _ := notes.Get(note.Id)
+	err := database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+		txNotes := notes.WithTx(tx)
+		fresh, err := txNotes.Get(note.Id)
+		if err != nil {
+			return err
+		}
+		fresh.Text = "fresh"
+		if err := txNotes.Update(fresh); err != nil {
+			return err
+		}
+		stale.Text = "stale"
+		return txNotes.Update(stale)
+	})
+	if !errors.As(err, &database.ErrVersionConflict{}) {
+		t.Fatalf("expected ErrVersionConflict in a transaction, got %v", err)
+	}
+	found, _ := notes.Get(note.Id)
+	if found.Text != "a" || found.Version != 1 {
+		t.Errorf("the transaction must be rolled back, got %+v", found)
+	}
+}
+
+type NullableNote struct {
+	_         struct{}     `dbtable:"notes"`
+	Id        int          `db:"id"`
+	Text      string       `db:"text"`
+	CreatedAt *time.Time   `db:"created_at" dbauto:"created"`
+	UpdatedAt sql.NullTime `db:"updated_at" dbauto:"updated"`
+	DeletedAt *time.Time   `db:"deleted_at" dbauto:"deleted"`
+}
+
+func TestNullableAutoFields(t *testing.T) {
+	newNoteRepository(t)
+	notes, err := database.NewRepository[NullableNote](db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes.SetDialect(qb.SQLITE)
+	note := NullableNote{Text: "a"}
+	if err := notes.Create(&note); err != nil {
+		t.Fatal(err)
+	}
+	if note.CreatedAt == nil || !note.UpdatedAt.Valid || note.DeletedAt != nil {
+		t.Fatalf("unexpected times after Create %+v", note)
+	}
+	found, err := notes.Get(note.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.CreatedAt == nil || !found.UpdatedAt.Valid || found.DeletedAt != nil {
+		t.Errorf("unexpected times of the stored note %+v", found)
+	}
+	created := *found.CreatedAt
+	updated := found.UpdatedAt.Time
+	time.Sleep(10 * time.Millisecond)
+	if err := notes.Update(found); err != nil {
+		t.Fatal(err)
+	}
+	if !found.CreatedAt.Equal(created) || !found.UpdatedAt.Time.After(updated) {
+		t.Errorf("only the update time must change, got %+v", found)
+	}
+	if err := notes.Delete(note.Id); err != nil {
+		t.Fatal(err)
+	}
+	trashed, err := notes.OnlyTrashed().Find()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 1 || trashed[0].DeletedAt == nil {
+		t.Errorf("unexpected trashed notes %+v", trashed)
+	}
+	if err := notes.Restore(note.Id); err != nil {
+		t.Fatal(err)
+	}
+	found, err = notes.Get(note.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.DeletedAt != nil {
+		t.Errorf("the deletion time must be NULL after Restore, got %v", found.DeletedAt)
+	}
+}
+
+func TestInvalidAutoField(t *testing.T) {
+	type BadNote struct {
+		_       struct{} `dbtable:"notes"`
+		Id      int      `db:"id"`
+		Version string   `db:"version" dbauto:"version"`
+	}
+	if _, err := database.NewRepository[BadNote](db); !errors.As(err, &database.ErrInvalidAutoField{}) {
+		t.Errorf("expected ErrInvalidAutoField, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/mapper"
+	"github.com/uwine4850/foozy/pkg/typeopr"
+)
+
+// Null has the same form as the generic sql.Null[T].
+type Null[T any] struct {
+	V     T
+	Valid bool
+}
+
+func (n *Null[T]) Scan(value any) error {
+	n.V, n.Valid = value.(T)
+	return nil
+}
+
+func (n Null[T]) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.V, nil
+}
+
+type nullableItem struct {
+	Id        int            `db:"id"`
+	Count     *int           `db:"count"`
+	Title     *string        `db:"title"`
+	CreatedAt *time.Time     `db:"created_at"`
+	Note      sql.NullString `db:"note"`
+	Total     sql.NullInt64  `db:"total"`
+	UpdatedAt sql.NullTime   `db:"updated_at"`
+	Score     Null[float64]  `db:"score"`
+}
+
+func createNullableTable(t *testing.T) {
+	if _, err := db.SyncQ().Exec("CREATE TABLE IF NOT EXISTS nullable_items (id INTEGER PRIMARY KEY AUTOINCREMENT, " +
+		"count INTEGER, title TEXT, created_at DATETIME, note TEXT, total INTEGER, updated_at DATETIME, score REAL)"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.SyncQ().Exec("DROP TABLE nullable_items")
+	})
+}
+
+func TestNullableFields(t *testing.T) {
+	createNullableTable(t)
+	count, title := 3, "title"
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	full := nullableItem{
+		Count:     &count,
+		Title:     &title,
+		CreatedAt: &created,
+		Note:      sql.NullString{String: "note", Valid: true},
+		Total:     sql.NullInt64{Int64: 7, Valid: true},
+		UpdatedAt: sql.NullTime{Time: created, Valid: true},
+		Score:     Null[float64]{V: 1.5, Valid: true},
+	}
+	for _, item := range []*nullableItem{&full, {}} {
+		params, err := mapper.ParamsValueFromDbStruct(typeopr.Ptr{}.New(item), []string{"id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := qb.NewSyncQB(db.SyncQ()).Insert("nullable_items", params).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := db.SyncQ().Query("SELECT * FROM nullable_items ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[1]["count"] != nil || rows[1]["note"] != nil || rows[1]["score"] != nil {
+		t.Fatalf("expected NULL values, got %v", rows[1])
+	}
+	filled := make([]nullableItem, len(rows))
+	if err := mapper.FillStructSliceFromDb(&filled, &rows); err != nil {
+		t.Fatal(err)
+	}
+	scanned, err := database.QueryInto[nullableItem](db.SyncQ(), "SELECT * FROM nullable_items ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, items := range [][]nullableItem{filled, scanned} {
+		got := items[0]
+		if got.Count == nil || *got.Count != 3 || got.Title == nil || *got.Title != "title" ||
+			got.CreatedAt == nil || !got.CreatedAt.Equal(created) {
+			t.Errorf("unexpected pointer fields %+v", got)
+		}
+		if got.Note != full.Note || got.Total != full.Total || !got.UpdatedAt.Valid ||
+			!got.UpdatedAt.Time.Equal(created) || got.Score != full.Score {
+			t.Errorf("unexpected null fields %+v", got)
+		}
+		empty := items[1]
+		if empty.Count != nil || empty.Title != nil || empty.CreatedAt != nil ||
+			empty.Note.Valid || empty.Total.Valid || empty.UpdatedAt.Valid || empty.Score.Valid {
+			t.Errorf("expected NULL fields, got %+v", empty)
+		}
+	}
+}
+
+func TestNullableFieldReset(t *testing.T) {
+	count := 1
+	item := nullableItem{Count: &count, Note: sql.NullString{String: "old", Valid: true}}
+	row := map[string]interface{}{"count": nil, "note": nil}
+	if err := mapper.FillStructFromDb(&item, &row); err != nil {
+		t.Fatal(err)
+	}
+	if item.Count != nil || item.Note.Valid || item.Note.String != "" {
+		t.Errorf("expected the fields to be reset, got %+v", item)
+	}
+}
+
+func TestNullableEmptyTag(t *testing.T) {
+	type emptyItem struct {
+		Title *string `db:"title" empty:"none"`
+	}
+	var item emptyItem
+	row := map[string]interface{}{"title": nil}
+	if err := mapper.FillStructFromDb(&item, &row); err != nil {
+		t.Fatal(err)
+	}
+	if item.Title == nil || *item.Title != "none" {
+		t.Errorf("expected the empty value to be set, got %v", item.Title)
+	}
+}
+
+func TestParamsValueNilPointer(t *testing.T) {
+	title := "title"
+	params, err := mapper.ParamsValueFromDbStruct(typeopr.Ptr{}.New(&nullableItem{Title: &title}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params["count"] != nil || params["created_at"] != nil {
+		t.Errorf("expected NULL for nil pointers, got %v", params)
+	}
+	if params["title"] != "title" {
+		t.Errorf("expected the value of the pointer, got %v", params["title"])
+	}
+}
@@ -0,0 +1,154 @@
+package sqlite_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+)
+
+type Product struct {
+	Id       int    `db:"id"`
+	Category string `db:"category"`
+	Price    int    `db:"price"`
+}
+
+func createProducts(t *testing.T, table string) {
+	if _, err := db.SyncQ().Exec("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY AUTOINCREMENT, category TEXT, price INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]any{}
+	for i := 1; i <= 7; i++ {
+		category := "a"
+		if i%2 == 0 {
+			category = "b"
+		}
+		rows = append(rows, []any{category, i * 10 % 40})
+	}
+	if _, err := qb.NewSyncQB(db.SyncQ()).InsertMany(table, []string{"category", "price"}, rows).Exec(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPaginateOffset(t *testing.T) {
+	createProducts(t, "products_offset")
+	base := qb.NewNoDbQB().SelectFrom("*", "products_offset").Where(qb.Compare("category", qb.EQUAL, "a"))
+	paginator := database.NewPaginator(db.SyncQ(), base, 3).OrderBy(qb.ASC("id"))
+	page, err := database.PaginateOffset[Product](paginator, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 3 || page.Items[0].Id != 1 || page.Items[2].Id != 5 {
+		t.Errorf("unexpected items %v", page.Items)
+	}
+	if page.Total != 4 || page.TotalPages != 2 || !page.HasNext || page.HasPrev {
+		t.Errorf("unexpected page %+v", page)
+	}
+	page, err = database.PaginateOffset[Product](paginator, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Id != 7 || page.HasNext || !page.HasPrev {
+		t.Errorf("unexpected page %+v", page)
+	}
+	if len(page.Pages()) != 2 || page.PrevPage() != 1 {
+		t.Errorf("unexpected page numbers %v", page.Pages())
+	}
+}
+
+func TestPaginateKeyset(t *testing.T) {
+	createProducts(t, "products_keyset")
+	base := qb.NewNoDbQB().SelectFrom("*", "products_keyset")
+	paginator := database.NewPaginator(db.SyncQ(), base, 3).Keyset(
+		database.KeysetColumn{Name: "price", Desc: true},
+		database.KeysetColumn{Name: "id"},
+	)
+	var ids []int
+	cursor := ""
+	for {
+		page, err := database.PaginateKeyset[Product](paginator, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 7 || page.HasPrev != (cursor != "") {
+			t.Errorf("unexpected page %+v", page)
+		}
+		for i := 0; i < len(page.Items); i++ {
+			ids = append(ids, page.Items[i].Id)
+		}
+		if !page.HasNext {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	// Prices: 1:10 2:20 3:30 4:0 5:10 6:20 7:30.
+	expected := []int{3, 7, 2, 6, 1, 5, 4}
+	if len(ids) != len(expected) {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+	for i := 0; i < len(expected); i++ {
+		if ids[i] != expected[i] {
+			t.Fatalf("unexpected ids %v", ids)
+		}
+	}
+}
+
+func TestPaginateInvalidCursor(t *testing.T) {
+	paginator := database.NewPaginator(db.SyncQ(), qb.NewNoDbQB().SelectFrom("*", "items"), 3).
+		Keyset(database.KeysetColumn{Name: "id"})
+	if _, err := database.PaginateKeyset[Item](paginator, "not a cursor"); err == nil {
+		t.Error("expected ErrInvalidCursor")
+	}
+}
+
+func TestPaginatorErrors(t *testing.T) {
+	base := qb.NewNoDbQB().SelectFrom("*", "items")
+	paginator := database.NewPaginator(db.SyncQ(), base, 0).OrderBy(qb.ASC("id"))
+	if _, err := database.PaginateOffset[Item](paginator, 1); !errors.As(err, &database.ErrPerPage{}) {
+		t.Errorf("expected ErrPerPage, got %v", err)
+	}
+	paginator = database.NewPaginator(db.SyncQ(), base, 3).Keyset(database.KeysetColumn{Name: "id; DROP TABLE items"})
+	if _, err := database.PaginateKeyset[Item](paginator, ""); !errors.As(err, &qb.ErrInvalidIdent{}) {
+		t.Errorf("expected ErrInvalidIdent, got %v", err)
+	}
+	invalidBase := qb.NewNoDbQB().SelectFrom("*", qb.Ident("bad table"))
+	paginator = database.NewPaginator(db.SyncQ(), invalidBase, 3).OrderBy(qb.ASC("id"))
+	if !errors.As(paginator.Err(), &qb.ErrInvalidIdent{}) {
+		t.Errorf("expected the error of the base query, got %v", paginator.Err())
+	}
+	if _, err := paginator.CountQuery().Query(); !errors.As(err, &qb.ErrInvalidIdent{}) {
+		t.Errorf("expected the error of the base query in the count query, got %v", err)
+	}
+}
+
+func TestPageJSON(t *testing.T) {
+	page := database.Page[Product]{Items: []Product{{Id: 1}}, PerPage: 10, Total: 1, TotalPages: 1}
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]any
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res["per_page"].(float64) != 10 || res["total_pages"].(float64) != 1 || len(res["items"].([]any)) != 1 {
+		t.Errorf("unexpected json %s", data)
+	}
+}
+
+func TestPaginatorQueries(t *testing.T) {
+	base := qb.NewNoDbQB().SetDialect(qb.POSTGRES).SelectFrom("*", "products").Where(qb.Compare("category", qb.EQUAL, "a"))
+	paginator := database.NewPaginator(db.SyncQ(), base, 10)
+	count := paginator.CountQuery()
+	count.Merge()
+	if count.String() != "SELECT COUNT(*) AS total FROM (SELECT * FROM products WHERE category = $1) AS paginated" {
+		t.Errorf("unexpected count query: %s", count.String())
+	}
+	page := paginator.OrderBy(qb.DESC("price")).OffsetQuery(3)
+	page.Merge()
+	if page.String() != "SELECT * FROM (SELECT * FROM products WHERE category = $1) AS paginated ORDER BY price DESC LIMIT 11 OFFSET 20" {
+		t.Errorf("unexpected page query: %s", page.String())
+	}
+}
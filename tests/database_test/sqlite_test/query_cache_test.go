@@ -0,0 +1,200 @@
+package sqlite_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+func newCachedDb(t *testing.T, ttl time.Duration) (*database.SqliteDatabase, *database.QueryCache, *database.QueryCounter) {
+	syncQ := database.NewSyncQueries()
+	counter := database.NewQueryCounter()
+	syncQ.AddHook(counter)
+	cache := database.NewQueryCache(database.NewLRUCacheStore(2), ttl)
+	syncQ.SetCache(cache)
+	cachedDb := database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, database.NewAsyncQueries(syncQ))
+	if err := cachedDb.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cachedDb.Close() })
+	if _, err := cachedDb.SyncQ().Exec("CREATE TABLE products (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cachedDb.SyncQ().Exec("INSERT INTO products (name) VALUES ('first')"); err != nil {
+		t.Fatal(err)
+	}
+	return cachedDb, cache, counter
+}
+
+func TestQueryCacheHitAndInvalidation(t *testing.T) {
+	cachedDb, cache, counter := newCachedDb(t, time.Minute)
+	start := counter.Count()
+	for i := 0; i < 3; i++ {
+		rows, err := cachedDb.SyncQ().Query("SELECT name FROM products WHERE id = ?", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0]["name"] != "first" {
+			t.Fatalf("unexpected rows %v", rows)
+		}
+		rows[0]["name"] = "changed"
+	}
+	if counter.Count()-start != 1 {
+		t.Errorf("expected 1 executed query, got %d", counter.Count()-start)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if _, err := cachedDb.SyncQ().Exec("UPDATE products SET name = ? WHERE id = ?", "second", 1); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := cachedDb.SyncQ().Query("SELECT name FROM products WHERE id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0]["name"] != "second" {
+		t.Errorf("expected a fresh result after the update, got %v", rows)
+	}
+	if cache.Stats().Invalidations == 0 {
+		t.Error("expected invalidation")
+	}
+}
+
+func TestQueryCachePointerArgs(t *testing.T) {
+	cachedDb, cache, counter := newCachedDb(t, time.Minute)
+	if _, err := cachedDb.SyncQ().Exec("INSERT INTO products (name) VALUES ('second')"); err != nil {
+		t.Fatal(err)
+	}
+	start := counter.Count()
+	id := 1
+	for _, arg := range []any{&id, 1, int64(1)} {
+		rows, err := cachedDb.SyncQ().Query("SELECT name FROM products WHERE id = ?", arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0]["name"] != "first" {
+			t.Fatalf("unexpected rows %v", rows)
+		}
+	}
+	// The same pointer with a different value must not return the cached result.
+	id = 2
+	rows, err := cachedDb.SyncQ().Query("SELECT name FROM products WHERE id = ?", &id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["name"] != "second" {
+		t.Errorf("expected the row of the new value, got %v", rows)
+	}
+	if counter.Count()-start != 2 {
+		t.Errorf("expected 2 executed queries, got %d", counter.Count()-start)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestQueryCacheTTL(t *testing.T) {
+	cachedDb, _, counter := newCachedDb(t, 0)
+	start := counter.Count()
+	// Without the default ttl only the selected queries are cached.
+	for i := 0; i < 2; i++ {
+		if _, err := cachedDb.SyncQ().Query("SELECT * FROM products"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counter.Count()-start != 2 {
+		t.Errorf("expected 2 executed queries, got %d", counter.Count()-start)
+	}
+	start = counter.Count()
+	for i := 0; i < 2; i++ {
+		if _, err := qb.NewSyncQB(cachedDb.SyncQ()).SelectFrom("*", "products").Cache(20 * time.Millisecond).Query(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counter.Count()-start != 1 {
+		t.Errorf("expected 1 executed query, got %d", counter.Count()-start)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := qb.NewSyncQB(cachedDb.SyncQ()).SelectFrom("*", "products").Cache(time.Minute).Query(); err != nil {
+		t.Fatal(err)
+	}
+	if counter.Count()-start != 2 {
+		t.Error("the expired result must not be used")
+	}
+}
+
+func TestQueryCacheTransaction(t *testing.T) {
+	cachedDb, _, counter := newCachedDb(t, time.Minute)
+	if _, err := cachedDb.SyncQ().Query("SELECT COUNT(*) AS total FROM products"); err != nil {
+		t.Fatal(err)
+	}
+	errRollback := errors.New("rollback")
+	err := database.WithTransaction(cachedDb, func(tx interfaces.DatabaseTransaction) error {
+		if _, err := tx.SyncQ().Exec("INSERT INTO products (name) VALUES (?)", "tx"); err != nil {
+			return err
+		}
+		start := counter.Count()
+		for i := 0; i < 2; i++ {
+			if _, err := tx.SyncQ().Query("SELECT COUNT(*) AS total FROM products"); err != nil {
+				return err
+			}
+		}
+		if counter.Count()-start != 2 {
+			t.Error("queries inside the transaction must not be cached")
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	rows, err := cachedDb.SyncQ().Query("SELECT COUNT(*) AS total FROM products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0]["total"] != int64(1) {
+		t.Errorf("expected 1 product after the rollback, got %v", rows[0]["total"])
+	}
+}
+
+func TestQueryCacheWriteCTE(t *testing.T) {
+	cachedDb, cache, _ := newCachedDb(t, time.Minute)
+	if _, err := cachedDb.SyncQ().Query("WITH first AS (SELECT id FROM products WHERE name = 'first') " +
+		"DELETE FROM products WHERE id IN (SELECT id FROM first)"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cache.Stats(); stats.Size != 0 || stats.Misses != 0 {
+		t.Errorf("a query that deletes rows must not be cached, got %+v", stats)
+	}
+	rows, err := cachedDb.SyncQ().Query("WITH names AS (SELECT name FROM products) SELECT COUNT(*) AS total FROM names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0]["total"] != int64(0) {
+		t.Errorf("expected the product to be deleted, got %v", rows[0]["total"])
+	}
+	if stats := cache.Stats(); stats.Size != 1 {
+		t.Errorf("a reading WITH query must be cached, got %+v", stats)
+	}
+}
+
+func TestQueryTables(t *testing.T) {
+	cases := map[string][]string{
+		"SELECT * FROM users u JOIN posts p ON p.user_id = u.id WHERE u.id IN (SELECT user_id FROM `likes`)": {"users", "posts", "likes"},
+		"SELECT * FROM a, b AS c, main.d":        {"a", "b", "d"},
+		"INSERT INTO Users (name) VALUES (?)":    {"users"},
+		"UPDATE items SET name = ? WHERE id = ?": {"items"},
+		"DELETE FROM \"orders\" WHERE id = ?":    {"orders"},
+		"SELECT 1":                               {},
+	}
+	for query, expected := range cases {
+		if tables := database.QueryTables(query); !reflect.DeepEqual(tables, expected) {
+			t.Errorf("%s: expected %v, got %v", query, expected, tables)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+)
+
+type RelAuthor struct {
+	_    struct{} `dbtable:"rel_authors"`
+	Id   int      `db:"id"`
+	Name string   `db:"name"`
+}
+
+type RelReview struct {
+	_      struct{} `dbtable:"rel_reviews"`
+	Id     int      `db:"id"`
+	PostId int      `db:"post_id"`
+	Text   string   `db:"text"`
+}
+
+type RelTag struct {
+	_    struct{} `dbtable:"rel_tags"`
+	Id   int      `db:"id"`
+	Name string   `db:"name"`
+}
+
+type RelPost struct {
+	_        struct{}    `dbtable:"rel_posts"`
+	Id       int         `db:"id"`
+	AuthorId int         `db:"author_id"`
+	Title    string      `db:"title"`
+	Author   *RelAuthor  `dbrel:"belongs_to:author_id"`
+	Reviews  []RelReview `dbrel:"has_many:post_id"`
+	Tags     []*RelTag   `dbrel:"many_to_many:rel_post_tags,post_id,tag_id"`
+	Invalid  []RelTag    `dbrel:"many_to_many:rel_post_tags"`
+}
+
+type RelEditor struct {
+	_    struct{} `dbtable:"rel_editors"`
+	Id   int64    `db:"id"`
+	Name string   `db:"name"`
+}
+
+type RelDraft struct {
+	_          struct{}      `dbtable:"rel_drafts"`
+	Id         int           `db:"id"`
+	EditorId   *int64        `db:"editor_id"`
+	ReviewerId sql.NullInt64 `db:"reviewer_id"`
+	Editor     *RelEditor    `dbrel:"belongs_to:editor_id"`
+	Reviewer   RelEditor     `dbrel:"belongs_to:reviewer_id"`
+}
+
+func createRelations(t *testing.T) {
+	queries := []string{
+		"CREATE TABLE rel_authors (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE rel_posts (id INTEGER PRIMARY KEY, author_id INTEGER, title TEXT)",
+		"CREATE TABLE rel_reviews (id INTEGER PRIMARY KEY, post_id INTEGER, text TEXT)",
+		"CREATE TABLE rel_tags (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE rel_post_tags (post_id INTEGER, tag_id INTEGER)",
+		"INSERT INTO rel_authors (id, name) VALUES (1, 'ann'), (2, 'bob')",
+		"INSERT INTO rel_posts (id, author_id, title) VALUES (1, 1, 'p1'), (2, 2, 'p2'), (3, 1, 'p3')",
+		"INSERT INTO rel_reviews (id, post_id, text) VALUES (1, 1, 'r1'), (2, 1, 'r2'), (3, 2, 'r3')",
+		"INSERT INTO rel_tags (id, name) VALUES (1, 'go'), (2, 'sql')",
+		"INSERT INTO rel_post_tags (post_id, tag_id) VALUES (1, 1), (1, 2), (3, 2)",
+	}
+	for i := 0; i < len(queries); i++ {
+		if _, err := db.SyncQ().Exec(queries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPreload(t *testing.T) {
+	createRelations(t)
+	counter := database.NewQueryCounter()
+	hooked, err := database.WithHooks(db, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := database.NewRepository[RelPost](hooked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := posts.SetDialect(qb.SQLITE).Preload("Author", "Reviews", "Tags").Find()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 posts, got %d", len(items))
+	}
+	// One query for the posts and one for each relation.
+	if counter.Count() != 4 {
+		t.Errorf("expected 4 queries, got %d", counter.Count())
+	}
+	if items[0].Author == nil || items[0].Author.Name != "ann" || items[1].Author.Name != "bob" || items[2].Author.Name != "ann" {
+		t.Errorf("unexpected authors %v %v %v", items[0].Author, items[1].Author, items[2].Author)
+	}
+	if len(items[0].Reviews) != 2 || len(items[1].Reviews) != 1 || len(items[2].Reviews) != 0 {
+		t.Errorf("unexpected reviews %v", items)
+	}
+	if len(items[0].Tags) != 2 || len(items[1].Tags) != 0 || len(items[2].Tags) != 1 || items[2].Tags[0].Name != "sql" {
+		t.Errorf("unexpected tags %v", items)
+	}
+
+	post, err := posts.Preload("Reviews").Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(post.Reviews) != 1 || post.Reviews[0].Text != "r3" || post.Author != nil {
+		t.Errorf("unexpected post %v", post)
+	}
+}
+
+func TestPreloadErrors(t *testing.T) {
+	posts := []RelPost{{Id: 1}}
+	if err := database.Preload(db, qb.SQLITE, posts, "Missing"); !errors.As(err, &database.ErrRelationNotFound{}) {
+		t.Errorf("expected ErrRelationNotFound, got %v", err)
+	}
+	if err := database.Preload(db, qb.SQLITE, posts, "Invalid"); !errors.As(err, &database.ErrInvalidRelation{}) {
+		t.Errorf("expected ErrInvalidRelation, got %v", err)
+	}
+}
+
+func TestPreloadNullableForeignKey(t *testing.T) {
+	queries := []string{
+		"CREATE TABLE rel_editors (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO rel_editors (id, name) VALUES (1, 'ann'), (2, 'bob')",
+	}
+	for i := 0; i < len(queries); i++ {
+		if _, err := db.SyncQ().Exec(queries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ann, bob := int64(1), int64(2)
+	var none *int64
+	drafts := []RelDraft{
+		{Id: 1, EditorId: &ann, ReviewerId: sql.NullInt64{Int64: 2, Valid: true}},
+		{Id: 2, EditorId: &bob, ReviewerId: sql.NullInt64{Int64: 1, Valid: false}},
+		{Id: 3, EditorId: none},
+	}
+	if err := database.Preload(db, qb.SQLITE, drafts, "Editor", "Reviewer"); err != nil {
+		t.Fatal(err)
+	}
+	if drafts[0].Editor == nil || drafts[0].Editor.Name != "ann" || drafts[1].Editor == nil || drafts[1].Editor.Name != "bob" {
+		t.Errorf("unexpected editors %v %v", drafts[0].Editor, drafts[1].Editor)
+	}
+	if drafts[2].Editor != nil {
+		t.Errorf("a NULL key must not load the editor, got %v", drafts[2].Editor)
+	}
+	if drafts[0].Reviewer.Name != "bob" || drafts[1].Reviewer.Name != "" || drafts[2].Reviewer.Name != "" {
+		t.Errorf("unexpected reviewers %v %v %v", drafts[0].Reviewer, drafts[1].Reviewer, drafts[2].Reviewer)
+	}
+}
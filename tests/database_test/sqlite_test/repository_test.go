@@ -0,0 +1,163 @@
+package sqlite_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+type Book struct {
+	_      struct{} `dbtable:"books"`
+	Id     int      `db:"id"`
+	Title  string   `db:"title"`
+	Author string   `db:"author"`
+	Pages  int      `db:"pages"`
+}
+
+func newBookRepository(t *testing.T) *database.Repository[Book] {
+	if _, err := db.SyncQ().Exec("CREATE TABLE IF NOT EXISTS books (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT, author TEXT, pages INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec("DELETE FROM books"); err != nil {
+		t.Fatal(err)
+	}
+	repository, err := database.NewRepository[Book](db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repository.SetDialect(qb.SQLITE)
+}
+
+func TestRepositoryCRUD(t *testing.T) {
+	repository := newBookRepository(t)
+	book := Book{Title: "Dune", Author: "Herbert", Pages: 412}
+	if err := repository.Create(&book); err != nil {
+		t.Fatal(err)
+	}
+	if book.Id == 0 {
+		t.Fatal("id must be set after Create")
+	}
+	found, err := repository.Get(book.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Title != "Dune" || found.Pages != 412 {
+		t.Errorf("unexpected book %v", found)
+	}
+
+	book.Title = "Dune Messiah"
+	book.Pages = 1
+	if err := repository.Update(&book, "title"); err != nil {
+		t.Fatal(err)
+	}
+	found, err = repository.Get(book.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Title != "Dune Messiah" || found.Pages != 412 {
+		t.Errorf("only the title must be updated, got %v", found)
+	}
+	if err := repository.Update(&book, "missing"); !errors.As(err, &database.ErrUnknownColumn{}) {
+		t.Errorf("expected ErrUnknownColumn, got %v", err)
+	}
+
+	if err := repository.Delete(book.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.Get(book.Id); !errors.As(err, &database.ErrNotFound{}) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := repository.Delete(book.Id); !errors.As(err, &database.ErrNotFound{}) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRepositoryFindAndCount(t *testing.T) {
+	repository := newBookRepository(t)
+	books := []Book{{Title: "a", Author: "x"}, {Title: "b", Author: "x"}, {Title: "c", Author: "y"}}
+	for i := 0; i < len(books); i++ {
+		if err := repository.Create(&books[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, err := repository.Find(qb.Compare("author", qb.EQUAL, "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Errorf("expected 2 books, got %v", found)
+	}
+	count, err := repository.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 books, got %d", count)
+	}
+}
+
+var errRollbackBook = errors.New("rollback book")
+
+func TestRepositoryTransaction(t *testing.T) {
+	repository := newBookRepository(t)
+	err := database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+		if err := repository.WithTx(tx).Create(&Book{Title: "tx"}); err != nil {
+			return err
+		}
+		return errRollbackBook
+	})
+	if !errors.Is(err, errRollbackBook) {
+		t.Fatalf("expected errRollbackBook, got %v", err)
+	}
+	count, err := repository.Count(qb.Compare("title", qb.EQUAL, "tx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Error("the book must be rolled back")
+	}
+}
+
+func TestRepositoryTransactionCRUD(t *testing.T) {
+	repository := newBookRepository(t)
+	err := database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+		txRepository := repository.WithTx(tx)
+		book := Book{Title: "tx", Pages: 10}
+		if err := txRepository.Create(&book); err != nil {
+			return err
+		}
+		if book.Id == 0 {
+			t.Error("id must be set after Create in a transaction")
+		}
+		book.Pages = 20
+		if err := txRepository.Update(&book); err != nil {
+			return err
+		}
+		found, err := txRepository.Get(book.Id)
+		if err != nil {
+			return err
+		}
+		if found.Pages != 20 {
+			t.Errorf("expected the updated book, got %v", found)
+		}
+		if err := txRepository.Delete(book.Id); err != nil {
+			return err
+		}
+		if err := txRepository.Delete(book.Id); !errors.As(err, &database.ErrNotFound{}) {
+			t.Errorf("expected ErrNotFound in a transaction, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepositoryWithoutTable(t *testing.T) {
+	if _, err := database.NewRepository[Item](db); !errors.As(err, &database.ErrRepositoryTable{}) {
+		t.Errorf("expected ErrRepositoryTable, got %v", err)
+	}
+}
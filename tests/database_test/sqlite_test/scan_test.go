@@ -0,0 +1,122 @@
+package sqlite_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/uwine4850/foozy/pkg/database"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+	"github.com/uwine4850/foozy/pkg/mapper"
+)
+
+type scanItem struct {
+	Id    int     `db:"id"`
+	Name  string  `db:"name"`
+	Price float64 `db:"price"`
+	Ok    bool    `db:"ok"`
+	Note  string  `db:"note" empty:"-"`
+}
+
+func TestQueryInto(t *testing.T) {
+	if _, err := db.SyncQ().Exec("INSERT INTO items (name, price, ok) VALUES (?, ?, ?), (?, NULL, ?)", "scan1", 5, true, "scan2", false); err != nil {
+		t.Fatal(err)
+	}
+	items, err := database.QueryInto[scanItem](db.SyncQ(), "SELECT id, name, price, ok, NULL AS note, 1 AS unused FROM items WHERE name LIKE ? ORDER BY id", "scan%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	if items[0].Name != "scan1" || items[0].Price != 5 || !items[0].Ok || items[0].Id == 0 {
+		t.Errorf("unexpected item %v", items[0])
+	}
+	if items[1].Name != "scan2" || items[1].Price != 0 || items[1].Ok {
+		t.Errorf("unexpected item %v", items[1])
+	}
+	if items[0].Note != "-" {
+		t.Errorf("expected empty value \"-\", got %s", items[0].Note)
+	}
+}
+
+func TestQueryIntoNotStruct(t *testing.T) {
+	if _, err := database.QueryInto[int](db.SyncQ(), "SELECT id FROM items"); err == nil {
+		t.Error("expected error for a non-struct type")
+	}
+}
+
+func TestQueryIntoIntegers(t *testing.T) {
+	type ints struct {
+		Big   uint64  `db:"big"`
+		Small int8    `db:"small"`
+		Null  uint32  `db:"null_value"`
+		Ptr   *uint64 `db:"unused"`
+	}
+	items, err := database.QueryInto[ints](db.SyncQ(), "SELECT '18446744073709551615' AS big, -5 AS small, NULL AS null_value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Big != math.MaxUint64 || items[0].Small != -5 || items[0].Null != 0 {
+		t.Errorf("unexpected items %v", items)
+	}
+	if _, err := database.QueryInto[ints](db.SyncQ(), "SELECT 300 AS small"); !errors.As(err, &mapper.ErrValueOverflow{}) {
+		t.Errorf("expected ErrValueOverflow for int8, got %v", err)
+	}
+	type small struct {
+		Value uint8 `db:"value"`
+	}
+	if _, err := database.QueryInto[small](db.SyncQ(), "SELECT 256 AS value"); !errors.As(err, &mapper.ErrValueOverflow{}) {
+		t.Errorf("expected ErrValueOverflow for uint8, got %v", err)
+	}
+}
+
+// plainSyncQ implements only the methods of the interfaces.SyncQ interface.
+type plainSyncQ struct {
+	interfaces.SyncQ
+}
+
+func TestQueryIntoWithoutRowsQuery(t *testing.T) {
+	if _, err := database.QueryInto[scanItem](plainSyncQ{db.SyncQ()}, "SELECT id FROM items"); !errors.As(err, &database.ErrRowsQueryNotSupported{}) {
+		t.Errorf("expected ErrRowsQueryNotSupported, got %v", err)
+	}
+}
+
+func prepareBenchItems(b *testing.B) {
+	if _, err := db.SyncQ().Exec("CREATE TABLE IF NOT EXISTS bench_items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, price INTEGER, ok BOOLEAN)"); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := db.SyncQ().Exec("DELETE FROM bench_items"); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err := db.SyncQ().Exec("INSERT INTO bench_items (name, price, ok) VALUES (?, ?, ?)", fmt.Sprintf("item%d", i), i, i%2 == 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+}
+
+func BenchmarkQueryInto(b *testing.B) {
+	prepareBenchItems(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := database.QueryInto[Item](db.SyncQ(), "SELECT * FROM bench_items"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkQueryFillStructSlice(b *testing.B) {
+	prepareBenchItems(b)
+	for i := 0; i < b.N; i++ {
+		rows, err := db.SyncQ().Query("SELECT * FROM bench_items")
+		if err != nil {
+			b.Fatal(err)
+		}
+		items := make([]Item, len(rows))
+		if err := mapper.FillStructSliceFromDb(&items, &rows); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
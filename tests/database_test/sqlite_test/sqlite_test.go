@@ -0,0 +1,165 @@
+package sqlite_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/builtin/auth"
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/mapper"
+)
+
+var db *database.SqliteDatabase
+
+type Item struct {
+	Id    int    `db:"id"`
+	Name  string `db:"name"`
+	Price int    `db:"price"`
+	Ok    bool   `db:"ok"`
+}
+
+func TestMain(m *testing.M) {
+	syncQ := database.NewSyncQueries()
+	asyncQ := database.NewAsyncQueries(syncQ)
+	db = database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, asyncQ)
+	if err := db.Open(); err != nil {
+		panic(err)
+	}
+	if _, err := db.SyncQ().Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, price INTEGER, ok BOOLEAN)"); err != nil {
+		panic(err)
+	}
+	if _, err := db.SyncQ().Exec("CREATE TABLE auth (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT NOT NULL, password TEXT NOT NULL)"); err != nil {
+		panic(err)
+	}
+	exitCode := m.Run()
+	if err := db.Close(); err != nil {
+		panic(err)
+	}
+	os.Exit(exitCode)
+}
+
+func TestQBInsertAndSelect(t *testing.T) {
+	res, err := qb.NewSyncQB(db.SyncQ()).Insert("items", map[string]any{"name": "item1", "price": 10, "ok": true}).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["insertID"].(int64) == 0 {
+		t.Error("insertID must be set")
+	}
+	rows, err := qb.NewSyncQB(db.SyncQ()).SelectFrom("*", "items").Where(qb.Compare("name", qb.EQUAL, "item1")).Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	var item Item
+	if err := mapper.FillStructFromDb(&item, &rows[0]); err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "item1" || item.Price != 10 || !item.Ok {
+		t.Errorf("unexpected item %v", item)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := qb.NewSyncQB(tx.SyncQ()).Insert("items", map[string]any{"name": "rollback"}).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.RollBackTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	exists, err := qb.SelectExists(qb.NewSyncQB(db.SyncQ()), "items", qb.Compare("name", qb.EQUAL, "rollback"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("the row must be rolled back")
+	}
+}
+
+func TestAsyncQueries(t *testing.T) {
+	asyncQ, err := db.NewAsyncQ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	asyncQ.Exec("ins", "INSERT INTO items (name) VALUES (?)", "async")
+	asyncQ.Wait()
+	asyncQ.Query("sel", "SELECT name FROM items WHERE name = ?", "async")
+	asyncQ.Wait()
+	if err := database.AsyncResError([]string{"ins", "sel"}, asyncQ); err != nil {
+		t.Fatal(err)
+	}
+	res, _ := asyncQ.LoadAsyncRes("sel")
+	if len(res.Res) != 1 {
+		t.Error("the inserted row was not found")
+	}
+}
+
+func TestAuthQuery(t *testing.T) {
+	authQuery := auth.NewMysqlAuthQuery(db, "auth")
+	hash, err := auth.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authQuery.CreateNewUser("user", hash); err != nil {
+		t.Fatal(err)
+	}
+	user, err := authQuery.UserByUsername("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.Username != "user" {
+		t.Fatal("user not found")
+	}
+	if err := auth.ComparePassword(user.Password, "password"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryPoolSettings(t *testing.T) {
+	syncQ := database.NewSyncQueries()
+	memDb := database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, database.NewAsyncQueries(syncQ))
+	memDb.SetPool(database.PoolArgs{MaxOpenConns: 10, ConnMaxLifetime: time.Millisecond, ConnMaxIdleTime: time.Millisecond})
+	if err := memDb.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer memDb.Close()
+	if max := memDb.Stats().MaxOpenConnections; max != 1 {
+		t.Errorf("expected one open connection, got %d", max)
+	}
+	if _, err := memDb.SyncQ().Exec("CREATE TABLE pool_items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := memDb.SyncQ().Exec("INSERT INTO pool_items (id) VALUES (1)"); err != nil {
+		t.Errorf("expected the in-memory data to be kept, got %v", err)
+	}
+}
+
+func TestFilePathParams(t *testing.T) {
+	syncQ := database.NewSyncQueries()
+	path := filepath.Join(t.TempDir(), "params.db") + "?_busy_timeout=1000"
+	fileDb := database.NewSqliteDatabase(path, syncQ, database.NewAsyncQueries(syncQ))
+	if err := fileDb.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer fileDb.Close()
+	res, err := fileDb.SyncQ().Query("PRAGMA foreign_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0]["foreign_keys"] != int64(1) {
+		t.Errorf("expected foreign keys to be enabled, got %v", res)
+	}
+}
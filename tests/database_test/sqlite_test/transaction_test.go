@@ -0,0 +1,173 @@
+package sqlite_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+	"github.com/uwine4850/foozy/pkg/database"
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+	"github.com/uwine4850/foozy/pkg/interfaces"
+)
+
+func itemExists(t *testing.T, name string) bool {
+	exists, err := qb.SelectExists(qb.NewSyncQB(db.SyncQ()), "items", qb.Compare("name", qb.EQUAL, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
+
+func insertItem(syncQ interfaces.SyncQ, name string) error {
+	_, err := syncQ.Exec("INSERT INTO items (name) VALUES (?)", name)
+	return err
+}
+
+func TestNestedTransaction(t *testing.T) {
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	if err := insertItem(tx.SyncQ(), "nested_outer"); err != nil {
+		t.Fatal(err)
+	}
+	// The nested transaction is rolled back.
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	if tx.(*database.MysqlTransaction).Depth() != 1 {
+		t.Errorf("expected depth 1, got %d", tx.(*database.MysqlTransaction).Depth())
+	}
+	if err := insertItem(tx.SyncQ(), "nested_rollback"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.RollBackTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	// The nested transaction is committed.
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	if err := insertItem(tx.SyncQ(), "nested_commit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CommitTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CommitTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	if !itemExists(t, "nested_outer") || !itemExists(t, "nested_commit") {
+		t.Error("the committed items must exist")
+	}
+	if itemExists(t, "nested_rollback") {
+		t.Error("the item of the rolled back savepoint must not exist")
+	}
+}
+
+func TestWithTransaction(t *testing.T) {
+	if err := database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+		if err := insertItem(tx.SyncQ(), "with_tx"); err != nil {
+			return err
+		}
+		return database.WithSavepoint(tx, func() error {
+			if err := insertItem(tx.SyncQ(), "with_tx_savepoint"); err != nil {
+				return err
+			}
+			return errors.New("savepoint error")
+		})
+	}); err == nil {
+		t.Fatal("expected savepoint error")
+	}
+	if itemExists(t, "with_tx") || itemExists(t, "with_tx_savepoint") {
+		t.Error("the transaction must be rolled back")
+	}
+
+	if err := database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+		return insertItem(tx.SyncQ(), "with_tx_commit")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !itemExists(t, "with_tx_commit") {
+		t.Error("the transaction must be committed")
+	}
+}
+
+func TestWithTransactionPanic(t *testing.T) {
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic must be passed on")
+			}
+		}()
+		database.WithTransaction(db, func(tx interfaces.DatabaseTransaction) error {
+			if err := insertItem(tx.SyncQ(), "with_tx_panic"); err != nil {
+				return err
+			}
+			panic("panic in transaction")
+		})
+	}()
+	if itemExists(t, "with_tx_panic") {
+		t.Error("the transaction must be rolled back after panic")
+	}
+}
+
+func TestWithTransactionRetry(t *testing.T) {
+	attempts := 0
+	opts := database.TxOptions{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	if err := database.WithTransactionOptions(db, opts, func(tx interfaces.DatabaseTransaction) error {
+		attempts++
+		if attempts < 3 {
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+
+	attempts = 0
+	if err := database.WithTransactionOptions(db, opts, func(tx interfaces.DatabaseTransaction) error {
+		attempts++
+		return errors.New("not retryable")
+	}); err == nil || attempts != 1 {
+		t.Errorf("a non-retryable error must not be repeated, attempts: %d", attempts)
+	}
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	if !database.IsRetryableTxError(&mysql.MySQLError{Number: 1213}) {
+		t.Error("mysql deadlock must be retryable")
+	}
+	if database.IsRetryableTxError(&mysql.MySQLError{Number: 1062}) {
+		t.Error("mysql duplicate entry must not be retryable")
+	}
+}
+
+func TestTransactionExecKeys(t *testing.T) {
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	defer tx.RollBackTransaction()
+	res, err := tx.SyncQ().Exec("INSERT INTO items (name) VALUES (?)", "tx_exec_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["insertID"].(int64) == 0 || res["id"] != res["insertID"] {
+		t.Errorf("unexpected insert id in %v", res)
+	}
+	if res["rowsAffected"] != int64(1) || res["rows"] != res["rowsAffected"] {
+		t.Errorf("unexpected affected rows in %v", res)
+	}
+}
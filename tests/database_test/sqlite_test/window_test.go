@@ -0,0 +1,45 @@
+package sqlite_test
+
+import (
+	"testing"
+
+	qb "github.com/uwine4850/foozy/pkg/database/querybuld"
+)
+
+func TestRecursiveCTE(t *testing.T) {
+	counter := qb.SQ(false, qb.NewNoDbQB().Select("1").Custom("UNION ALL").
+		SelectFrom("n + 1", "counter").Where(qb.Compare("n", qb.LESS, 5)))
+	rows, err := qb.NewSyncQB(db.SyncQ()).SetDialect(qb.SQLITE).WithRecursive("counter(n)", counter).
+		SelectFrom("n", "counter").Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[4]["n"].(int64) != 5 {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
+
+func TestWindowRank(t *testing.T) {
+	if _, err := db.SyncQ().Exec("CREATE TABLE scores (id INTEGER PRIMARY KEY AUTOINCREMENT, team TEXT, points INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]any{{"a", 10}, {"a", 30}, {"b", 20}, {"b", 5}}
+	if _, err := qb.NewSyncQB(db.SyncQ()).InsertMany("scores", []string{"team", "points"}, rows).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	teamA := qb.SQ(false, qb.NewNoDbQB().SelectFrom("*", "scores").Where(qb.Compare("team", qb.EQUAL, "a")))
+	res, err := qb.NewSyncQB(db.SyncQ()).SetDialect(qb.SQLITE).With("team_a", teamA).Select(
+		"points,",
+		qb.RowNumber().Over(qb.OrderBy(qb.DESC("points"))).As("rn"), ",",
+		qb.Lead("points", 1, -1).Over(qb.OrderBy(qb.DESC("points"))).As("next_points"),
+	).Custom("FROM team_a").OrderBy("rn").Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res))
+	}
+	if res[0]["points"].(int64) != 30 || res[0]["next_points"].(int64) != 10 || res[1]["next_points"].(int64) != -1 {
+		t.Errorf("unexpected rows %v", res)
+	}
+}
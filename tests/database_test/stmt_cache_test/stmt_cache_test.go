@@ -0,0 +1,159 @@
+package stmtcache_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/uwine4850/foozy/pkg/database"
+)
+
+var db *database.SqliteDatabase
+
+func TestMain(m *testing.M) {
+	syncQ := database.NewSyncQueries()
+	asyncQ := database.NewAsyncQueries(syncQ)
+	db = database.NewSqliteDatabase(database.SQLITE_MEMORY, syncQ, asyncQ)
+	db.EnableStmtCache(2)
+	if err := db.Open(); err != nil {
+		panic(err)
+	}
+	if _, err := db.SyncQ().Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)"); err != nil {
+		panic(err)
+	}
+	exitCode := m.Run()
+	if err := db.Close(); err != nil {
+		panic(err)
+	}
+	os.Exit(exitCode)
+}
+
+func TestStmtCacheHits(t *testing.T) {
+	db.StmtCache().Clear()
+	before := db.StmtCache().Stats()
+	for i := 0; i < 3; i++ {
+		if _, err := db.SyncQ().Exec("INSERT INTO items (name) VALUES (?)", "item"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := db.SyncQ().Query("SELECT * FROM items WHERE name = ?", "item")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) < 3 {
+		t.Errorf("expected at least 3 rows, got %d", len(rows))
+	}
+	stats := db.StmtCache().Stats()
+	if stats.Misses-before.Misses != 2 {
+		t.Errorf("expected 2 misses, got %d", stats.Misses-before.Misses)
+	}
+	if stats.Hits-before.Hits != 2 {
+		t.Errorf("expected 2 hits, got %d", stats.Hits-before.Hits)
+	}
+	if stats.Size != 2 || stats.Capacity != 2 {
+		t.Errorf("unexpected size %d and capacity %d", stats.Size, stats.Capacity)
+	}
+}
+
+func TestStmtCacheEviction(t *testing.T) {
+	db.StmtCache().Clear()
+	before := db.StmtCache().Stats()
+	queries := []string{"SELECT 1", "SELECT 2", "SELECT 3", "SELECT 1"}
+	for i := 0; i < len(queries); i++ {
+		if _, err := db.SyncQ().Query(queries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := db.StmtCache().Stats()
+	if stats.Evictions-before.Evictions != 2 {
+		t.Errorf("expected 2 evictions, got %d", stats.Evictions-before.Evictions)
+	}
+	if stats.Size != 2 {
+		t.Errorf("expected size 2, got %d", stats.Size)
+	}
+}
+
+func TestStmtCacheInvalidate(t *testing.T) {
+	if _, err := db.SyncQ().Query("SELECT 10"); err != nil {
+		t.Fatal(err)
+	}
+	before := db.StmtCache().Stats()
+	db.StmtCache().Invalidate("SELECT 10")
+	stats := db.StmtCache().Stats()
+	if stats.Invalidations-before.Invalidations != 1 || stats.Size != before.Size-1 {
+		t.Errorf("the statement is not invalidated: %+v", stats)
+	}
+	if _, err := db.SyncQ().Query("SELECT 10"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStmtCacheTransaction(t *testing.T) {
+	db.StmtCache().Clear()
+	if _, err := db.SyncQ().Exec("INSERT INTO items (name) VALUES (?)", "tx"); err != nil {
+		t.Fatal(err)
+	}
+	before := db.StmtCache().Stats()
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := tx.SyncQ().Exec("INSERT INTO items (name) VALUES (?)", "tx"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.RollBackTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	stats := db.StmtCache().Stats()
+	if stats.Hits-before.Hits != 1 {
+		t.Errorf("the transaction must reuse the cached statement, hits: %d", stats.Hits-before.Hits)
+	}
+	rows, err := db.SyncQ().Query("SELECT * FROM items WHERE name = ?", "tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Errorf("expected 1 row after rollback, got %d", len(rows))
+	}
+}
+
+func TestStmtCacheTransactionMiss(t *testing.T) {
+	db.StmtCache().Clear()
+	before := db.StmtCache().Stats()
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.BeginTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		// The in-memory database has one connection, which is held by the transaction.
+		_, err := tx.SyncQ().Exec("INSERT INTO items (name) VALUES (?)", "miss")
+		if err == nil {
+			_, err = tx.SyncQ().Query("SELECT * FROM items WHERE name = ?", "miss")
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the query of the transaction is blocked")
+	}
+	if err := tx.CommitTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	stats := db.StmtCache().Stats()
+	if stats.Size != 0 || stats.Misses-before.Misses != 2 {
+		t.Errorf("the statements of the transaction must not be cached: %+v", stats)
+	}
+}